@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -41,6 +42,10 @@ func main() {
 	rootCmd.AddCommand(cmdServe())
 	rootCmd.AddCommand(cmdUpdate())
 	rootCmd.AddCommand(cmdMigrate())
+	rootCmd.AddCommand(cmdCleanup())
+	rootCmd.AddCommand(cmdGC())
+	rootCmd.AddCommand(cmdExport())
+	rootCmd.AddCommand(cmdImport())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -138,3 +143,107 @@ func cmdMigrate() *cobra.Command {
 
 	return cmd
 }
+
+func cmdCleanup() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Removing expired sessions and expiring stale carts",
+		Run: func(serveCmd *cobra.Command, args []string) {
+			report, err := app.Cleanup()
+			if err != nil {
+				fmt.Print(err)
+				os.Exit(1)
+			}
+			fmt.Printf("Expired sessions removed: %d\n", report.Sessions)
+			fmt.Printf("Stale carts expired: %d\n", report.ExpiredCarts)
+			fmt.Printf("Licence keys released: %d\n", report.ReleasedKeys)
+		},
+	}
+
+	return cmd
+}
+
+func cmdGC() *cobra.Command {
+	var dryRun bool
+	var grace time.Duration
+	cmd := &cobra.Command{
+		Use:   "gc [flags]",
+		Short: "Removing uploads and digital files no product refers to",
+		Run: func(serveCmd *cobra.Command, args []string) {
+			report, err := app.GarbageCollect(grace, dryRun)
+			if err != nil {
+				fmt.Print(err)
+				os.Exit(1)
+			}
+			for _, orphan := range report.Orphans {
+				fmt.Printf("%s\t%d\t%s\n", orphan.Key, orphan.Size, time.Unix(orphan.Modified, 0).Format(time.DateTime))
+			}
+			fmt.Printf("Orphaned files: %d (%d bytes)\n", len(report.Orphans), report.Size)
+			if dryRun {
+				fmt.Print("Dry run, nothing was removed\n")
+			} else {
+				fmt.Printf("Files removed: %d\n", report.Removed)
+			}
+		},
+	}
+
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "only report the orphaned files")
+	cmd.PersistentFlags().DurationVar(&grace, "grace", 0, "keep files changed within this period (default from the maintenance settings)")
+
+	return cmd
+}
+
+func cmdExport() *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "export <file.zip> [flags]",
+		Short: "Exporting the products with their images and files",
+		Args:  cobra.ExactArgs(1),
+		Run: func(serveCmd *cobra.Command, args []string) {
+			if format != "json" && format != "csv" {
+				fmt.Print("format must be json or csv\n")
+				os.Exit(1)
+			}
+			if err := app.ExportProducts(args[0], format); err != nil {
+				fmt.Print(err)
+				os.Exit(1)
+			}
+			fmt.Printf("Products exported to %s\n", args[0])
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&format, "format", "json", "list the products as json or csv")
+
+	return cmd
+}
+
+func cmdImport() *cobra.Command {
+	var upsert, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import <file.zip|file.json|file.csv> [flags]",
+		Short: "Importing products exported from another shop",
+		Args:  cobra.ExactArgs(1),
+		Run: func(serveCmd *cobra.Command, args []string) {
+			report, err := app.ImportProducts(args[0], upsert, dryRun)
+			if err != nil {
+				fmt.Print(err)
+				os.Exit(1)
+			}
+			for _, invalid := range report.Invalid {
+				fmt.Printf("%d\t%s\t%s\n", invalid.Row, invalid.Slug, invalid.Error)
+			}
+			fmt.Printf("Products added: %d\n", report.Inserted)
+			fmt.Printf("Products updated: %d\n", report.Updated)
+			fmt.Printf("Products skipped, the slug is taken: %d\n", len(report.Skipped))
+			fmt.Printf("Invalid products: %d\n", len(report.Invalid))
+			if dryRun {
+				fmt.Print("Dry run, nothing was imported\n")
+			}
+		},
+	}
+
+	cmd.PersistentFlags().BoolVar(&upsert, "upsert", false, "update the products whose slug is taken")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "only check the products")
+
+	return cmd
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/template/html/v2"
 
+	handlers "github.com/shurco/litecart/internal/handlers/public"
+	"github.com/shurco/litecart/internal/jobs"
 	"github.com/shurco/litecart/internal/middleware"
 	"github.com/shurco/litecart/internal/queries"
 	"github.com/shurco/litecart/internal/routes"
@@ -82,7 +84,9 @@ func NewApp(httpAddr, httpsAddr string, noSite, appDev bool) error {
 		log.Err(err).Send()
 		os.Exit(1)
 	}
-	app.Static("/uploads", "./lc_uploads")
+	jobs.Start(ctx)
+
+	app.Get("/uploads/:name", handlers.Upload)
 	app.Use(InstallCheck)
 	routes.AdminRoutes(app)
 	routes.ApiPrivateRoutes(app)
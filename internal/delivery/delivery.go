@@ -0,0 +1,182 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+const (
+	// MaxAttempts is the number of calls after which a delivery is marked as failed.
+	MaxAttempts = 8
+	// maxBackoff caps the delay between two attempts.
+	maxBackoff = 6 * time.Hour
+	// maxContent limits the size of the response stored against the cart.
+	maxContent = 64 << 10
+	// claimTimeout is the time after which a claimed delivery whose outcome was
+	// never stored is attempted again.
+	claimTimeout = 10 * time.Minute
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Run attempts the due deliveries of a cart, or of all carts when cartID is empty,
+// and returns the IDs of the carts that received a delivery.
+func Run(ctx context.Context, cartID string, actor models.CartActor) ([]string, error) {
+	db := queries.DB()
+
+	if cartID == "" {
+		if _, err := db.ReleaseCartDeliveries(ctx, claimTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	deliveries, err := db.PendingDeliveries(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivered := []string{}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		// the job, the admin and the payment may run at the same time,
+		// only the caller that claims the delivery calls the endpoint
+		claimed, err := db.ClaimCartDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := attempt(ctx, delivery); err != nil {
+			return delivered, err
+		}
+
+		err = db.AddCartEvent(ctx, &models.CartEvent{
+			CartID: delivery.CartID,
+			Event:  models.CartEventDelivery,
+			Actor:  actor,
+			Payload: map[string]any{
+				"product_id": delivery.ProductID,
+				"status":     delivery.Status,
+				"attempt":    delivery.Attempts,
+				"error":      delivery.Error,
+			},
+		})
+		if err != nil {
+			return delivered, err
+		}
+
+		if delivery.Status == models.CartDeliveryDelivered {
+			delivered = append(delivered, delivery.CartID)
+		}
+	}
+
+	return delivered, nil
+}
+
+// attempt calls the endpoint of the product once for a claimed delivery and stores
+// the outcome. Failed calls are rescheduled with an exponential backoff.
+func attempt(ctx context.Context, delivery *models.CartDelivery) error {
+	db := queries.DB()
+
+	api, request, err := db.DeliveryRequest(ctx, delivery)
+	if err != nil {
+		if err != errors.ErrNotFound {
+			return err
+		}
+		// the endpoint was removed from the product after the payment
+		delivery.Status = models.CartDeliveryFailed
+		delivery.Error = "delivery endpoint is not configured"
+		delivery.NextAttempt = time.Now().Unix()
+		return db.UpdateCartDelivery(ctx, delivery)
+	}
+
+	content, err := call(ctx, api, request)
+	if err != nil {
+		delivery.Error = err.Error()
+		delivery.Status = models.CartDeliveryPending
+		delivery.NextAttempt = time.Now().Add(backoff(delivery.Attempts)).Unix()
+		if delivery.Attempts >= MaxAttempts {
+			delivery.Status = models.CartDeliveryFailed
+		}
+	} else {
+		delivery.Status = models.CartDeliveryDelivered
+		delivery.Content = content
+		delivery.Error = ""
+		delivery.NextAttempt = time.Now().Unix()
+	}
+
+	return db.UpdateCartDelivery(ctx, delivery)
+}
+
+// call posts the signed payload to the endpoint. The signature is the hex encoded
+// HMAC-SHA256 of the body with the secret of the product in the X-Litecart-Signature header.
+// The endpoint answers with the content for the buyer, either as plain text or as a
+// JSON object with a "content" field.
+func call(ctx context.Context, api *models.DigitalAPI, request *models.CartDeliveryRequest) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Litecart-Signature", "sha256="+security.SignBody(api.Secret, body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxContent))
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		response := struct {
+			Content string `json:"content"`
+		}{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return "", fmt.Errorf("invalid response: %w", err)
+		}
+		content = strings.TrimSpace(response.Content)
+	}
+
+	if content == "" {
+		return "", fmt.Errorf("endpoint responded without content")
+	}
+
+	return content, nil
+}
+
+// backoff returns the delay before the next attempt, doubling from one minute.
+func backoff(attempts int) time.Duration {
+	delay := time.Minute << (attempts - 1)
+	if attempts > 16 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/delivery"
+	"github.com/shurco/litecart/internal/invoice"
 	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/logging"
 	"github.com/shurco/litecart/pkg/webutil"
 )
@@ -23,13 +30,273 @@ func Carts(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Carts", products)
 }
 
+// Cart is ...
+// [get] /api/_/carts/:cart_id
+func Cart(c *fiber.Ctx) error {
+	cartID := c.Params("cart_id")
+	db := queries.DB()
+	log := logging.New()
+
+	cart, err := db.Cart(c.Context(), cartID)
+	if err != nil {
+		if err == errors.ErrCartNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	events, err := db.CartEvents(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	invoiceInfo, err := db.Invoice(c.Context(), cartID)
+	if err != nil && err != errors.ErrInvoiceNotFound {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	downloads, err := db.CartDownloads(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	deliveries, err := db.CartDeliveries(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	activations, err := db.CartActivations(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Cart", map[string]any{
+		"cart":        cart,
+		"events":      events,
+		"invoice":     invoiceInfo,
+		"downloads":   downloads,
+		"deliveries":  deliveries,
+		"activations": activations,
+	})
+}
+
+// CartInvoice is ...
+// [get] /api/_/carts/:cart_id/invoice
+func CartInvoice(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	invoiceInfo, err := db.Invoice(c.Context(), c.Params("cart_id"))
+	if err != nil {
+		if err == errors.ErrInvoiceNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	pdf, err := invoice.PDF(invoiceInfo)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.pdf"`, invoiceInfo.Code))
+	return c.Send(pdf)
+}
+
+// UpdateCartStatus is ...
+// [patch] /api/_/carts/:cart_id/status
+func UpdateCartStatus(c *fiber.Ctx) error {
+	cartID := c.Params("cart_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.CartStatus)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if _, err := db.Cart(c.Context(), cartID); err != nil {
+		if err == errors.ErrCartNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	err := db.UpdateCart(c.Context(), &models.Cart{
+		Core: models.Core{
+			ID: cartID,
+		},
+		PaymentStatus: request.Status,
+	}, models.CartActorAdmin)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	err = db.AddCartEvent(c.Context(), &models.CartEvent{
+		CartID: cartID,
+		Event:  models.CartEventAdminAction,
+		Actor:  models.CartActorAdmin,
+		Payload: map[string]any{
+			"action": "update_status",
+			"status": request.Status,
+			"note":   request.Note,
+		},
+	})
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Cart status updated", nil)
+}
+
+// ResetCartDownloads is ...
+// [delete] /api/_/carts/:cart_id/downloads
+func ResetCartDownloads(c *fiber.Ctx) error {
+	cartID := c.Params("cart_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if _, err := db.Cart(c.Context(), cartID); err != nil {
+		if err == errors.ErrCartNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	reset, err := db.ResetDownloads(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	err = db.AddCartEvent(c.Context(), &models.CartEvent{
+		CartID: cartID,
+		Event:  models.CartEventAdminAction,
+		Actor:  models.CartActorAdmin,
+		Payload: map[string]any{
+			"action": "reset_downloads",
+			"reset":  reset,
+		},
+	})
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Downloads reset", nil)
+}
+
+// ResetCartActivations is ...
+// [delete] /api/_/carts/:cart_id/activations
+func ResetCartActivations(c *fiber.Ctx) error {
+	cartID := c.Params("cart_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if _, err := db.Cart(c.Context(), cartID); err != nil {
+		if err == errors.ErrCartNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	reset, err := db.ResetActivations(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	err = db.AddCartEvent(c.Context(), &models.CartEvent{
+		CartID: cartID,
+		Event:  models.CartEventAdminAction,
+		Actor:  models.CartActorAdmin,
+		Payload: map[string]any{
+			"action": "reset_activations",
+			"reset":  reset,
+		},
+	})
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Activations reset", nil)
+}
+
+// RetryCartDelivery is ...
+// [post] /api/_/carts/:cart_id/delivery
+func RetryCartDelivery(c *fiber.Ctx) error {
+	cartID := c.Params("cart_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if _, err := db.RetryCartDeliveries(c.Context(), cartID); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	delivered, err := delivery.Run(c.Context(), cartID, models.CartActorAdmin)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if len(delivered) > 0 {
+		if err := mailer.SendCartLetter(cartID, models.CartActorAdmin); err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+	}
+
+	deliveries, err := db.CartDeliveries(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Delivery retried", deliveries)
+}
+
 // CartSendMail
 // [post] /api/_/carts/:cart_id/mail
 func CartSendMail(c *fiber.Ctx) error {
 	cartID := c.Params("cart_id")
+	db := queries.DB()
 	log := logging.New()
 
-	if err := mailer.SendCartLetter(cartID); err != nil {
+	if err := mailer.SendCartLetter(cartID, models.CartActorAdmin); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	err := db.AddCartEvent(c.Context(), &models.CartEvent{
+		CartID: cartID,
+		Event:  models.CartEventAdminAction,
+		Actor:  models.CartActorAdmin,
+		Payload: map[string]any{
+			"action": "resend_mail",
+		},
+	})
+	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Categories is ...
+// [get] /api/_/categories
+func Categories(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	categories, err := db.ListCategories(c.Context(), true)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Categories", categories)
+}
+
+// Category is ...
+// [get] /api/_/categories/:category_id
+func Category(c *fiber.Ctx) error {
+	categoryID := c.Params("category_id")
+	db := queries.DB()
+	log := logging.New()
+
+	category, err := db.Category(c.Context(), true, categoryID)
+	if err != nil {
+		if err == errors.ErrCategoryNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category info", category)
+}
+
+// AddCategory is ...
+// [post] /api/_/categories
+func AddCategory(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.Category)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	category, err := db.AddCategory(c.Context(), request)
+	if err != nil {
+		if err == errors.ErrCategoryExists || err == errors.ErrCategoryNotFound {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category added", category)
+}
+
+// UpdateCategory is ...
+// [patch] /api/_/categories/:category_id
+func UpdateCategory(c *fiber.Ctx) error {
+	categoryID := c.Params("category_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.Category)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+	request.ID = categoryID
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateCategory(c.Context(), request); err != nil {
+		switch err {
+		case errors.ErrCategoryExists, errors.ErrCategoryLoop, errors.ErrCategoryNotFound:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category updated", nil)
+}
+
+// DeleteCategory is ...
+// [delete] /api/_/categories/:category_id
+func DeleteCategory(c *fiber.Ctx) error {
+	categoryID := c.Params("category_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.DeleteCategory(c.Context(), categoryID); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category deleted", nil)
+}
+
+// UpdateCategoryActive is ...
+// [patch] /api/_/categories/:category_id/active
+func UpdateCategoryActive(c *fiber.Ctx) error {
+	categoryID := c.Params("category_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.UpdateCategoryActive(c.Context(), categoryID); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category active updated", nil)
+}
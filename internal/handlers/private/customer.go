@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Customers is ...
+// [get] /api/_/customers
+func Customers(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	customers, err := db.Customers(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Customers", customers)
+}
+
+// Customer is ...
+// [get] /api/_/customers/:customer_id
+func Customer(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	db := queries.DB()
+	log := logging.New()
+
+	customer, err := db.Customer(c.Context(), customerID)
+	if err != nil {
+		if err == errors.ErrCustomerNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	purchases, err := db.CustomerPurchases(c.Context(), customerID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Customer info", map[string]any{
+		"customer":  customer,
+		"purchases": purchases,
+	})
+}
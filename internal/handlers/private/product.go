@@ -1,7 +1,14 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/disintegration/imaging"
 	"github.com/gofiber/fiber/v2"
@@ -11,22 +18,49 @@ import (
 	"github.com/shurco/litecart/internal/queries"
 	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/fsutil"
+	"github.com/shurco/litecart/pkg/license"
 	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/storage"
 	"github.com/shurco/litecart/pkg/webutil"
 )
 
 // Products is ...
-// [get] /api/_/products
+// [get] /api/_/products?category=:category_slug&q=:query&sort=:sort&order=:order&page=:page&limit=:limit&fields=:fields&trash=:trash
 func Products(c *fiber.Ctx) error {
 	db := queries.DB()
 	log := logging.New()
+	filter := &models.ProductFilter{
+		Page:  1,
+		Limit: 20,
+	}
+
+	if err := c.QueryParser(filter); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if fields := c.Query("fields"); fields != "" {
+		filter.Fields = strings.Split(fields, ",")
+	}
+
+	if err := filter.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
 
-	products, err := db.ListProducts(c.Context(), true)
+	products, err := db.ListProducts(c.Context(), true, *filter)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
 
+	if len(filter.Fields) > 0 {
+		selected, err := products.Select(filter.Fields)
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		return webutil.Response(c, fiber.StatusOK, "Products", selected)
+	}
+
 	return webutil.Response(c, fiber.StatusOK, "Products", products)
 }
 
@@ -51,6 +85,98 @@ func AddProduct(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Product added", product)
 }
 
+// ExportProducts is ...
+// [get] /api/_/products/export?format=:format
+func ExportProducts(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return webutil.StatusBadRequest(c, "format: must be json or csv.")
+	}
+
+	file, err := os.CreateTemp("", "litecart-export-*.zip")
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if err := db.ExportCatalog(c.Context(), file, format); err != nil {
+		os.Remove(file.Name())
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	// the archive is sent after the handler returns, it is removed once the stream is closed
+	archive, err := os.Open(file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+	info, err := archive.Stat()
+	if err != nil {
+		tempFile{archive}.Close()
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="products.zip"`)
+	return c.SendStream(tempFile{archive}, int(info.Size()))
+}
+
+// tempFile is a temporary file that is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+// Close is ...
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// ImportProducts is ...
+// [post] /api/_/products/import?upsert=:upsert&dry_run=:dry_run
+func ImportProducts(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	fileTmp, err := c.FormFile("document")
+	if err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	dir, err := os.MkdirTemp("", "litecart-import-*")
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "catalog."+strings.ToLower(fsutil.ExtName(fileTmp.Filename)))
+	if err := c.SaveFile(fileTmp, src); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	report, err := db.ImportCatalog(c.Context(), src, c.QueryBool("upsert"), c.QueryBool("dry_run"))
+	if err != nil {
+		if err == errors.ErrCatalogInvalid {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Products imported", report)
+}
+
 // GetProduct is ...
 // [get] /api/_/products/:product_id
 func Product(c *fiber.Ctx) error {
@@ -81,7 +207,16 @@ func UpdateProduct(c *fiber.Ctx) error {
 		return webutil.StatusBadRequest(c, err.Error())
 	}
 
+	if request.Sale != nil {
+		if err := request.Sale.Validate(); err != nil {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+	}
+
 	if err := db.UpdateProduct(c.Context(), request); err != nil {
+		if err == errors.ErrVariantSale {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -97,6 +232,9 @@ func DeleteProduct(c *fiber.Ctx) error {
 	log := logging.New()
 
 	if err := db.DeleteProduct(c.Context(), productID); err != nil {
+		if err == errors.ErrProductNotFound {
+			return webutil.StatusNotFound(c)
+		}
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -104,6 +242,83 @@ func DeleteProduct(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Product deleted", nil)
 }
 
+// RestoreProduct is ...
+// [post] /api/_/products/:product_id/restore
+func RestoreProduct(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.RestoreProduct(c.Context(), productID); err != nil {
+		if err == errors.ErrProductNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Product restored", nil)
+}
+
+// PurgeProduct is ...
+// [delete] /api/_/products/:product_id/purge
+func PurgeProduct(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.PurgeProduct(c.Context(), productID); err != nil {
+		switch err {
+		case errors.ErrProductNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrProductSold:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Product purged", nil)
+}
+
+// EmptyProductTrash is ...
+// [delete] /api/_/products/trash
+func EmptyProductTrash(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	purged, kept, err := db.EmptyTrash(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	message := "Trash emptied"
+	if kept > 0 {
+		message = fmt.Sprintf("Trash emptied, products in paid or pending carts were kept: %d", kept)
+	}
+	return webutil.Response(c, fiber.StatusOK, message, map[string]int{"purged": purged, "kept": kept})
+}
+
+// DuplicateProduct is ...
+// [post] /api/_/products/:product_id/duplicate
+func DuplicateProduct(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	product, err := db.DuplicateProduct(c.Context(), productID)
+	if err != nil {
+		if err == errors.ErrProductNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Product duplicated", product)
+}
+
 // UpdateProductActive is ...
 // [patch] /api/_/products/:product_id/active
 func UpdateProductActive(c *fiber.Ctx) error {
@@ -119,6 +334,105 @@ func UpdateProductActive(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Product active updated", nil)
 }
 
+// ProductVariants is ...
+// [get] /api/_/products/:product_id/variants
+func ProductVariants(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	variants, err := db.ProductVariants(c.Context(), true, productID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Product variants", variants)
+}
+
+// AddProductVariant is ...
+// [post] /api/_/products/:product_id/variants
+func AddProductVariant(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.ProductVariant)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+	request.ProductID = c.Params("product_id")
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	variant, err := db.AddVariant(c.Context(), request)
+	if err != nil {
+		switch err {
+		case errors.ErrProductNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrVariantExists, errors.ErrVariantSale:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Variant added", variant)
+}
+
+// UpdateProductVariant is ...
+// [patch] /api/_/products/:product_id/variants/:variant_id
+func UpdateProductVariant(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.ProductVariant)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+	request.ID = c.Params("variant_id")
+	request.ProductID = c.Params("product_id")
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateVariant(c.Context(), request); err != nil {
+		switch err {
+		case errors.ErrProductNotFound, errors.ErrVariantNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrVariantExists:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Variant updated", request)
+}
+
+// DeleteProductVariant is ...
+// [delete] /api/_/products/:product_id/variants/:variant_id
+func DeleteProductVariant(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	variantID := c.Params("variant_id")
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.DeleteVariant(c.Context(), productID, variantID); err != nil {
+		if err == errors.ErrVariantNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Variant deleted", nil)
+}
+
 // ProductImages
 // [get] /api/_/products/:product_id/image
 func ProductImages(c *fiber.Ctx) error {
@@ -160,16 +474,34 @@ func AddProductImage(c *fiber.Ctx) error {
 		return webutil.StatusBadRequest(c, "file format not supported")
 	}
 
+	fs, err := queries.Storage(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
 	fileUUID := uuid.New().String()
 	fileExt := fsutil.ExtName(file.Filename)
 	fileName := fmt.Sprintf("%s.%s", fileUUID, fileExt)
-	filePath := fmt.Sprintf("./lc_uploads/%s", fileName)
 	fileOrigName := file.Filename
 
-	c.SaveFile(file, filePath)
+	fileData, err := readFormFile(file)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	fileSource, err := imaging.Decode(bytes.NewReader(fileData))
+	if err != nil {
+		return webutil.StatusBadRequest(c, "file format not supported")
+	}
 
-	fileSource, err := imaging.Open(filePath)
+	format, err := imaging.FormatFromExtension(fileExt)
 	if err != nil {
+		return webutil.StatusBadRequest(c, "file format not supported")
+	}
+
+	if err := fs.Put(c.Context(), queries.UploadKey(fileName), bytes.NewReader(fileData), int64(len(fileData))); err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -184,7 +516,12 @@ func AddProductImage(c *fiber.Ctx) error {
 
 	for _, s := range sizes {
 		resizedImage := imaging.Fill(fileSource, s.dim, s.dim, imaging.Center, imaging.Lanczos)
-		err := imaging.Save(resizedImage, fmt.Sprintf("./lc_uploads/%s_%s.%s", fileUUID, s.size, fileExt))
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, resizedImage, format); err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		err := fs.Put(c.Context(), queries.UploadKey(fmt.Sprintf("%s_%s.%s", fileUUID, s.size, fileExt)), &buf, int64(buf.Len()))
 		if err != nil {
 			log.ErrorStack(err)
 			return webutil.StatusInternalServerError(c)
@@ -239,21 +576,34 @@ func ProductDigital(c *fiber.Ctx) error {
 // [post] /api/_/products/:product_id/digital
 func AddProductDigital(c *fiber.Ctx) error {
 	productID := c.Params("product_id")
+	variantID := c.FormValue("variant_id")
 	db := queries.DB()
 	log := logging.New()
 
 	fileTmp, _ := c.FormFile("document")
 	if fileTmp != nil {
+		fs, err := queries.Storage(c.Context())
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+
 		fileUUID := uuid.New().String()
 		fileExt := fsutil.ExtName(fileTmp.Filename)
-		fileName := fmt.Sprintf("%s.%s", fileUUID, fileExt)
-		filePath := fmt.Sprintf("./lc_digitals/%s", fileName)
+		fileKey := queries.DigitalKey(fileUUID, fileExt)
 		fileOrigName := fileTmp.Filename
 
-		c.SaveFile(fileTmp, filePath)
+		if err := saveFormFile(c, fs, fileTmp, fileKey); err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
 
-		file, err := db.AddDigitalFile(c.Context(), productID, fileUUID, fileExt, fileOrigName)
+		file, err := db.AddDigitalFile(c.Context(), productID, variantID, fileUUID, fileExt, fileOrigName, "")
 		if err != nil {
+			fs.Delete(c.Context(), fileKey)
+			if err == errors.ErrVariantNotFound {
+				return webutil.StatusBadRequest(c, err.Error())
+			}
 			log.ErrorStack(err)
 			return webutil.StatusInternalServerError(c)
 		}
@@ -261,8 +611,11 @@ func AddProductDigital(c *fiber.Ctx) error {
 		return webutil.Response(c, fiber.StatusOK, "Digital added", file)
 	}
 
-	data, err := db.AddDigitalData(c.Context(), productID, "")
+	data, err := db.AddDigitalData(c.Context(), productID, variantID, "")
 	if err != nil {
+		if err == errors.ErrVariantNotFound {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -270,6 +623,340 @@ func AddProductDigital(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Digital added", data)
 }
 
+// AddProductDigitalVersion is ...
+// [post] /api/_/products/:product_id/digital/:digital_id/version
+func AddProductDigitalVersion(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	digitalID := c.Params("digital_id")
+	db := queries.DB()
+	log := logging.New()
+
+	fileTmp, err := c.FormFile("document")
+	if err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	fs, err := queries.Storage(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	fileUUID := uuid.New().String()
+	fileExt := fsutil.ExtName(fileTmp.Filename)
+	fileKey := queries.DigitalKey(fileUUID, fileExt)
+
+	if err := saveFormFile(c, fs, fileTmp, fileKey); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	file, err := db.AddDigitalFile(c.Context(), productID, "", fileUUID, fileExt, fileTmp.Filename, digitalID)
+	if err != nil {
+		fs.Delete(c.Context(), fileKey)
+		if err == errors.ErrNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital added", file)
+}
+
+// UpdateProductDigitalVersion is ...
+// [patch] /api/_/products/:product_id/digital/:digital_id/version
+func UpdateProductDigitalVersion(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	digitalID := c.Params("digital_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.FileVersion)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateDigitalFile(c.Context(), productID, digitalID, request); err != nil {
+		if err == errors.ErrNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		if err == errors.ErrActiveVersion {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital updated", request)
+}
+
+// UpdateProductDigitalVariant is ...
+// [patch] /api/_/products/:product_id/digital/:digital_id/variant
+func UpdateProductDigitalVariant(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	digitalID := c.Params("digital_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.DigitalVariant)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateDigitalVariant(c.Context(), productID, digitalID, request.VariantID); err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrVariantNotFound:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital variant updated", request)
+}
+
+// NotifyProductDigitalRelease is ...
+// [post] /api/_/products/:product_id/digital/:digital_id/notify
+func NotifyProductDigitalRelease(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	digitalID := c.Params("digital_id")
+	db := queries.DB()
+	log := logging.New()
+
+	queued, err := db.QueueReleaseNotices(c.Context(), productID, digitalID)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Buyers will be notified", map[string]int64{"queued": queued})
+}
+
+// ImportProductDigital is ...
+// [post] /api/_/products/:product_id/digital/import
+func ImportProductDigital(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	data, isCSV := c.Body(), strings.Contains(c.Get(fiber.HeaderContentType), "csv")
+	if fileTmp, err := c.FormFile("document"); err == nil {
+		file, err := fileTmp.Open()
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		defer file.Close()
+
+		if data, err = io.ReadAll(file); err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		isCSV = strings.EqualFold(fsutil.ExtName(fileTmp.Filename), "csv")
+	}
+
+	lines, err := importLines(data, isCSV)
+	if err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	report, err := db.ImportDigitalData(c.Context(), productID, c.Query("variant_id"), lines)
+	if err != nil {
+		if err == errors.ErrProductNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		if err == errors.ErrDigitalNotData || err == errors.ErrVariantNotFound {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital imported", report)
+}
+
+// ExportProductDigital is ...
+// [get] /api/_/products/:product_id/digital/export
+func ExportProductDigital(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+
+	var assigned *bool
+	switch c.Query("status") {
+	case "assigned":
+		assigned = new(bool)
+		*assigned = true
+	case "unassigned":
+		assigned = new(bool)
+	}
+
+	keys, err := db.ExportDigitalData(c.Context(), productID, assigned)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"key", "cart_id"})
+	for _, key := range keys {
+		w.Write([]string{key.Content, key.CartID})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, productID))
+	return c.Send(buf.Bytes())
+}
+
+// importLines splits an uploaded list of keys into lines. A csv upload takes the first
+// column of every record and skips the header written by the export.
+func importLines(data []byte, isCSV bool) ([]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !isCSV {
+		return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	lines := []string{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// keep line numbers in the report aligned with the file
+		line, _ := r.FieldPos(0)
+		for len(lines) < line-1 {
+			lines = append(lines, "")
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "key") {
+			record[0] = ""
+		}
+		lines = append(lines, record[0])
+	}
+
+	return lines, nil
+}
+
+// UpdateProductDigitalAPI is ...
+// [patch] /api/_/products/:product_id/digital/api
+func UpdateProductDigitalAPI(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.DigitalAPI)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateDigitalAPI(c.Context(), productID, request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital updated", request)
+}
+
+// UpdateProductDigitalGenerator is ...
+// [patch] /api/_/products/:product_id/digital/generator
+func UpdateProductDigitalGenerator(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.DigitalGenerator)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if request.Mode == "pattern" {
+		charset := request.Charset
+		if charset == "" {
+			charset = license.DefaultCharset
+		}
+		if _, err := license.Generate(request.Pattern, charset, request.Checksum); err != nil {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+	}
+
+	if err := db.UpdateDigitalGenerator(c.Context(), productID, request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital updated", request)
+}
+
+// UpdateProductDigitalBundle is ...
+// [patch] /api/_/products/:product_id/digital/bundle
+func UpdateProductDigitalBundle(c *fiber.Ctx) error {
+	productID := c.Params("product_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.DigitalBundle)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateDigitalBundle(c.Context(), productID, request.Products); err != nil {
+		switch err {
+		case errors.ErrProductNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrBundleComponent, errors.ErrVariantRequired, errors.ErrVariantNotFound:
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Digital updated", request)
+}
+
 // UpdateProductDigital is ...
 // [patch] /api/_/products/:product_id/digital/:digital_id
 func UpdateProductDigital(c *fiber.Ctx) error {
@@ -307,3 +994,25 @@ func DeleteProductDigital(c *fiber.Ctx) error {
 
 	return webutil.Response(c, fiber.StatusOK, "Digital deleted", nil)
 }
+
+// saveFormFile stores an uploaded file under the key.
+func saveFormFile(c *fiber.Ctx, fs storage.Storage, header *multipart.FileHeader, key string) error {
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return fs.Put(c.Context(), key, file, header.Size)
+}
+
+// readFormFile returns the content of an uploaded file.
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Reviews is ...
+// [get] /api/_/reviews?status=:status&page=:page&limit=:limit
+func Reviews(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	filter := &models.ReviewFilter{
+		Page:  1,
+		Limit: 20,
+	}
+
+	if err := c.QueryParser(filter); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := filter.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	reviews, err := db.ListReviews(c.Context(), true, "", *filter)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Reviews", reviews)
+}
+
+// UpdateReviewStatus is ...
+// [patch] /api/_/reviews/:review_id/status
+func UpdateReviewStatus(c *fiber.Ctx) error {
+	reviewID := c.Params("review_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.ReviewModeration)
+
+	if err := c.BodyParser(request); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateReviewStatus(c.Context(), reviewID, request.Status); err != nil {
+		if err == errors.ErrReviewNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Review status updated", nil)
+}
+
+// UpdateReviewReply is ...
+// [patch] /api/_/reviews/:review_id/reply
+func UpdateReviewReply(c *fiber.Ctx) error {
+	reviewID := c.Params("review_id")
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.ReviewReply)
+
+	if err := c.BodyParser(request); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.UpdateReviewReply(c.Context(), reviewID, request.Reply); err != nil {
+		if err == errors.ErrReviewNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Review reply updated", nil)
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,11 +16,15 @@ import (
 	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/internal/queries"
 	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/license"
 	"github.com/shurco/litecart/pkg/logging"
 	"github.com/shurco/litecart/pkg/update"
 	"github.com/shurco/litecart/pkg/webutil"
 )
 
+// secretMask replaces the secrets that are returned to the admin.
+const secretMask = "********"
+
 // Version is ...
 // [get] /api/_/version
 func Version(c *fiber.Ctx) error {
@@ -76,8 +84,10 @@ func GetSetting(c *fiber.Ctx) error {
 	var err error
 
 	switch settingKey {
-	case "password":
+	case "password", "license_seed":
 		return webutil.StatusNotFound(c)
+	case "license":
+		section, err = licensePublicKey(c.Context(), db)
 	case "main":
 		section, err = db.GetSettingByGroup(c.Context(), &models.Main{})
 	case "social":
@@ -98,6 +108,18 @@ func GetSetting(c *fiber.Ctx) error {
 		section, err = db.GetSettingByGroup(c.Context(), &models.Spectrocoin{})
 	case "mail":
 		section, err = db.GetSettingByGroup(c.Context(), &models.Mail{})
+	case "recovery":
+		section, err = db.GetSettingByGroup(c.Context(), &models.Recovery{})
+	case "review":
+		section, err = db.GetSettingByGroup(c.Context(), &models.ReviewRequest{})
+	case "maintenance":
+		section, err = db.GetSettingByGroup(c.Context(), &models.Maintenance{})
+	case "invoice":
+		section, err = db.GetSettingByGroup(c.Context(), &models.Seller{})
+	case "delivery":
+		section, err = db.GetSettingByGroup(c.Context(), &models.Delivery{})
+	case "storage":
+		section, err = storageSetting(c.Context(), db, true)
 	default:
 		section, err = db.GetSettingByKey(c.Context(), settingKey)
 	}
@@ -121,6 +143,8 @@ func UpdateSetting(c *fiber.Ctx) error {
 	var request any
 
 	switch settingKey {
+	case "license", "license_seed":
+		return webutil.StatusNotFound(c)
 	case "password":
 		request = &models.Password{}
 	case "main":
@@ -143,6 +167,18 @@ func UpdateSetting(c *fiber.Ctx) error {
 		request = &models.Webhook{}
 	case "mail":
 		request = &models.Mail{}
+	case "recovery":
+		request = &models.Recovery{}
+	case "review":
+		request = &models.ReviewRequest{}
+	case "maintenance":
+		request = &models.Maintenance{}
+	case "invoice":
+		request = &models.Seller{}
+	case "delivery":
+		request = &models.Delivery{}
+	case "storage":
+		request = &models.Storage{}
 	default:
 		request = &models.SettingName{}
 	}
@@ -174,6 +210,25 @@ func UpdateSetting(c *fiber.Ctx) error {
 		return webutil.Response(c, fiber.StatusOK, "Setting key updated", nil)
 	}
 
+	// a storage that can not be written to would break every upload and download
+	if config, ok := request.(*models.Storage); ok {
+		// the secret is never sent to the browser, an empty or masked value keeps the stored one
+		if config.SecretKey == "" || config.SecretKey == secretMask {
+			stored, err := storageSetting(c.Context(), db, false)
+			if err != nil {
+				log.ErrorStack(err)
+				return webutil.StatusInternalServerError(c)
+			}
+			config.SecretKey = stored.SecretKey
+		}
+		if err := config.Validate(); err != nil {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		if err := checkStorage(c.Context(), config); err != nil {
+			return webutil.StatusBadRequest(c, fmt.Sprintf("storage check failed: %v", err))
+		}
+	}
+
 	// Update setting for all other cases
 	if err := db.UpdateSettingByGroup(c.Context(), request); err != nil {
 		log.ErrorStack(err)
@@ -183,6 +238,55 @@ func UpdateSetting(c *fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusOK, "Setting group updated", nil)
 }
 
+// licensePublicKey returns the public part of the license signing key,
+// so the software of the seller can verify the licenses offline.
+func licensePublicKey(ctx context.Context, db *queries.Base) (map[string]string, error) {
+	key, err := db.LicenseKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pem, err := license.PublicKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"algorithm":  "Ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		"pem":        string(pem),
+	}, nil
+}
+
+// storageSetting returns the storage settings, with the secret key masked when
+// they are shown in the admin.
+func storageSetting(ctx context.Context, db *queries.Base, mask bool) (*models.Storage, error) {
+	config, err := db.GetSettingByGroup(ctx, &models.Storage{})
+	if err != nil {
+		return nil, err
+	}
+
+	storage := config.(*models.Storage)
+	if mask && storage.SecretKey != "" {
+		storage.SecretKey = secretMask
+	}
+	return storage, nil
+}
+
+// checkStorage writes and removes a small object to make sure the storage works.
+func checkStorage(ctx context.Context, config *models.Storage) error {
+	fs, err := queries.NewStorage(config)
+	if err != nil {
+		return err
+	}
+
+	key := queries.UploadKey(".litecart-check")
+	if err := fs.Put(ctx, key, strings.NewReader("ok"), 2); err != nil {
+		return err
+	}
+	return fs.Delete(ctx, key)
+}
+
 // TestLetter is ...
 // [get] /api/_/test/letter/:letter_name
 func TestLetter(c *fiber.Ctx) error {
@@ -10,6 +10,7 @@ import (
 	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/internal/queries"
 	"github.com/shurco/litecart/internal/webhook"
+	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/litepay"
 	"github.com/shurco/litecart/pkg/logging"
 	"github.com/shurco/litecart/pkg/security"
@@ -42,6 +43,10 @@ func Payment(c *fiber.Ctx) error {
 		return webutil.StatusBadRequest(c, err.Error())
 	}
 
+	if err := payment.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
 	setting, err := db.GetSettingByKey(c.Context(), "domain", "currency")
 	if err != nil {
 		log.ErrorStack(err)
@@ -50,41 +55,79 @@ func Payment(c *fiber.Ctx) error {
 	domain := setting["domain"].Value.(string)
 	currency := setting["currency"].Value.(string)
 
-	products, err := db.ListProducts(c.Context(), false, payment.Products...)
+	variants := make([]*models.ProductVariant, len(payment.Products))
+	for i, cartProduct := range payment.Products {
+		payment.Products[i].Amount = nil
+		variant, err := db.CartVariant(c.Context(), cartProduct)
+		if err != nil {
+			if err == errors.ErrVariantRequired || err == errors.ErrVariantNotFound {
+				return webutil.StatusBadRequest(c, err.Error())
+			}
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		variants[i] = variant
+	}
+
+	if err := db.CartBundles(c.Context(), payment.Products); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if err := db.CheckStock(c.Context(), payment.Products); err != nil {
+		if err == errors.ErrOutOfStock || err == errors.ErrProductNotFound {
+			return webutil.Response(c, fiber.StatusConflict, "Some products are out of stock", nil)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	products, err := db.ListProducts(c.Context(), false, models.ProductFilter{}, payment.Products...)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
 
-	items := make([]litepay.Item, len(products.Products))
-	for i, product := range products.Products {
+	productMap := make(map[string]models.Product, len(products.Products))
+	for _, product := range products.Products {
+		productMap[product.ID] = product
+	}
+
+	items := []litepay.Item{}
+	for i, cartProduct := range payment.Products {
+		// a product that can not be priced is not sold, it would be delivered for free
+		product, ok := productMap[cartProduct.ProductID]
+		if !ok {
+			return webutil.StatusBadRequest(c, errors.MsgProductNotFound)
+		}
+
 		images := []string{}
 		for _, image := range product.Images {
 			path := fmt.Sprintf("https://%s/uploads/%s_md.%s", domain, image.Name, image.Ext)
 			images = append(images, path)
 		}
 
-		quantity := 1
-		for _, cartProduct := range payment.Products {
-			if cartProduct.ProductID == product.ID {
-				quantity = cartProduct.Quantity
-			}
+		name, amount := product.Name, product.Price
+		if variant := variants[i]; variant != nil {
+			name, amount = product.Name+" - "+variant.Name, variant.Amount
 		}
+		payment.Products[i].Amount = &amount
 
-		items[i] = litepay.Item{
+		item := litepay.Item{
 			PriceData: litepay.Price{
-				UnitAmount: product.Amount,
+				UnitAmount: amount,
 				Product: litepay.Product{
-					Name:   product.Name,
+					Name:   name,
 					Images: images,
 				},
 			},
-			Quantity: quantity,
+			Quantity: cartProduct.Quantity,
 		}
 
 		if product.Description != "" {
-			items[i].PriceData.Product.Description = product.Description
+			item.PriceData.Product.Description = product.Description
 		}
+		items = append(items, item)
 	}
 
 	cart := litepay.Cart{
@@ -100,6 +143,7 @@ func Payment(c *fiber.Ctx) error {
 
 	paymentURL := fmt.Sprintf("https://%s/cart", domain)
 	paymentSystem := payment.Provider
+	var session litepay.LitePay
 	switch paymentSystem {
 	case litepay.STRIPE:
 		setting, err := queries.GetSettingByGroup[models.Stripe](c.Context(), db)
@@ -111,13 +155,7 @@ func Payment(c *fiber.Ctx) error {
 		if !setting.Active {
 			return webutil.Response(c, fiber.StatusOK, "Payment url", paymentURL)
 		}
-		session := pay.Stripe(setting.SecretKey)
-		response, err := session.Pay(cart)
-		if err != nil {
-			log.ErrorStack(err)
-			return webutil.StatusInternalServerError(c)
-		}
-		paymentURL = response.URL
+		session = pay.Stripe(setting.SecretKey)
 
 	case litepay.PAYPAL:
 		setting, err := queries.GetSettingByGroup[models.Paypal](c.Context(), db)
@@ -129,13 +167,7 @@ func Payment(c *fiber.Ctx) error {
 		if !setting.Active {
 			return webutil.Response(c, fiber.StatusOK, "Payment url", paymentURL)
 		}
-		session := pay.Paypal(setting.ClientID, setting.SecretKey)
-		response, err := session.Pay(cart)
-		if err != nil {
-			log.ErrorStack(err)
-			return webutil.StatusInternalServerError(c)
-		}
-		paymentURL = response.URL
+		session = pay.Paypal(setting.ClientID, setting.SecretKey)
 
 	case litepay.SPECTROCOIN:
 		setting, err := queries.GetSettingByGroup[models.Spectrocoin](c.Context(), db)
@@ -147,13 +179,7 @@ func Payment(c *fiber.Ctx) error {
 		if !setting.Active {
 			return webutil.Response(c, fiber.StatusOK, "Payment url", paymentURL)
 		}
-		session := pay.Spectrocoin(setting.MerchantID, setting.ProjectID, setting.PrivateKey)
-		response, err := session.Pay(cart)
-		if err != nil {
-			log.ErrorStack(err)
-			return webutil.StatusInternalServerError(c)
-		}
-		paymentURL = response.URL
+		session = pay.Spectrocoin(setting.MerchantID, setting.ProjectID, setting.PrivateKey)
 	}
 
 	var amountTotal int
@@ -161,20 +187,53 @@ func Payment(c *fiber.Ctx) error {
 		amountTotal += s.PriceData.UnitAmount * s.Quantity
 	}
 
-	db.AddCart(c.Context(), &models.Cart{
+	var customerID string
+	if payment.Email != "" {
+		customerID, err = db.CustomerID(c.Context(), payment.Email)
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+	}
+
+	// the cart reserves the keys before the provider is asked for a checkout,
+	// so a product out of stock does not leave a checkout behind
+	err = db.AddCart(c.Context(), &models.Cart{
 		Core: models.Core{
 			ID: cart.ID,
 		},
 		Email:         payment.Email,
+		CustomerID:    customerID,
 		Cart:          payment.Products,
+		Billing:       payment.Billing,
 		AmountTotal:   amountTotal,
 		Currency:      cart.Currency,
 		PaymentStatus: litepay.NEW,
 		PaymentSystem: paymentSystem,
 	})
+	if err != nil {
+		if err == errors.ErrOutOfStock {
+			return webutil.Response(c, fiber.StatusConflict, "Some products are out of stock", nil)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if session != nil {
+		response, err := session.Pay(cart)
+		if err != nil {
+			log.ErrorStack(err)
+			// the cart can not be paid, its keys go back to the stock
+			if err := db.UpdateCart(c.Context(), &models.Cart{Core: models.Core{ID: cart.ID}, PaymentStatus: litepay.FAILED}, models.CartActorCheckout); err != nil {
+				log.ErrorStack(err)
+			}
+			return webutil.StatusInternalServerError(c)
+		}
+		paymentURL = response.URL
+	}
 
 	// send email
-	if err := mailer.SendPrepaymentLetter(payment.Email, fmt.Sprintf("%.2f %s", float64(amountTotal)/100, cart.Currency), paymentURL); err != nil {
+	if err := mailer.SendPrepaymentLetter(cart.ID, payment.Email, fmt.Sprintf("%.2f %s", float64(amountTotal)/100, cart.Currency), paymentURL); err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
@@ -234,7 +293,7 @@ func PaymentCallback(c *fiber.Ctx) error {
 		PaymentID:     payment.MerchantID,
 		PaymentStatus: payment.Status,
 		PaymentSystem: payment.PaymentSystem,
-	})
+	}, models.CartActorProviderCallback)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
@@ -242,7 +301,7 @@ func PaymentCallback(c *fiber.Ctx) error {
 
 	// send email
 	if payment.Status == litepay.PAID {
-		if err := mailer.SendCartLetter(payment.CartID); err != nil {
+		if err := mailer.SendCartLetter(payment.CartID, models.CartActorProviderCallback); err != nil {
 			log.ErrorStack(err)
 			return webutil.StatusInternalServerError(c)
 		}
@@ -344,7 +403,7 @@ func PaymentSuccess(c *fiber.Ctx) error {
 		PaymentID:     payment.MerchantID,
 		PaymentStatus: payment.Status,
 		PaymentSystem: payment.PaymentSystem,
-	})
+	}, models.CartActorSuccessRedirect)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
@@ -352,7 +411,7 @@ func PaymentSuccess(c *fiber.Ctx) error {
 
 	// send email
 	if payment.Status == litepay.PAID {
-		if err := mailer.SendCartLetter(payment.CartID); err != nil {
+		if err := mailer.SendCartLetter(payment.CartID, models.CartActorSuccessRedirect); err != nil {
 			log.ErrorStack(err)
 			return webutil.StatusInternalServerError(c)
 		}
@@ -392,7 +451,7 @@ func PaymentCancel(c *fiber.Ctx) error {
 		},
 		PaymentStatus: litepay.CANCELED,
 		PaymentSystem: payment.PaymentSystem,
-	})
+	}, models.CartActorCancelRedirect)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
@@ -415,3 +474,72 @@ func PaymentCancel(c *fiber.Ctx) error {
 
 	return c.Render("cancel", nil, "layouts/main")
 }
+
+// CartRecover is ...
+// [get] /api/cart/recover/:token
+func CartRecover(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	cartID, err := db.GetSession(c.Context(), "cart_recover:"+c.Params("token"))
+	if err != nil || cartID == "" {
+		return webutil.StatusNotFound(c)
+	}
+
+	cart, err := db.Cart(c.Context(), cartID)
+	if err != nil {
+		if err == errors.ErrCartNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if cart.PaymentStatus == litepay.PAID {
+		return webutil.StatusNotFound(c)
+	}
+
+	recovery := &models.CartRecovery{
+		Email:    cart.Email,
+		Provider: cart.PaymentSystem,
+		Items:    []models.CartRecoveryItem{},
+	}
+
+	if len(cart.Cart) > 0 {
+		products, err := db.ListProducts(c.Context(), false, models.ProductFilter{}, cart.Cart...)
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+
+		productMap := make(map[string]models.Product, len(products.Products))
+		for _, product := range products.Products {
+			productMap[product.ID] = product
+		}
+
+		for _, cartProduct := range cart.Cart {
+			product, ok := productMap[cartProduct.ProductID]
+			if !ok {
+				continue
+			}
+
+			item := models.CartRecoveryItem{
+				ID:     product.ID,
+				Name:   product.Name,
+				Slug:   product.Slug,
+				Amount: product.Price,
+			}
+			for _, variant := range product.Variants {
+				if variant.ID == cartProduct.VariantID {
+					item.VariantID, item.VariantName, item.Amount = variant.ID, variant.Name, variant.Amount
+				}
+			}
+			if len(product.Images) > 0 {
+				item.Image = &product.Images[0]
+			}
+			recovery.Items = append(recovery.Items, item)
+		}
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Cart recovery", recovery)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Categories is ...
+// [get] /api/categories
+func Categories(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	categories, err := db.ListCategories(c.Context(), false)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Categories", categories)
+}
+
+// Category is ...
+// [get] /api/categories/:category_slug
+func Category(c *fiber.Ctx) error {
+	categorySlug := c.Params("category_slug")
+	db := queries.DB()
+	log := logging.New()
+
+	category, err := db.Category(c.Context(), false, categorySlug)
+	if err != nil {
+		if err == errors.ErrCategoryNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Category info", category)
+}
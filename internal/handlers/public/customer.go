@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// CustomerSignIn is ...
+// [post] /api/customer/sign/in
+func CustomerSignIn(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.CustomerSignIn)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	// the response is the same whether the customer exists or not
+	customer, err := db.CustomerByEmail(c.Context(), request.Email)
+	if err != nil {
+		if err == errors.ErrCustomerNotFound {
+			return webutil.Response(c, fiber.StatusOK, "Sign in link sent", nil)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	setting, err := db.GetSettingByKey(c.Context(), "domain")
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	token := uuid.New().String()
+	expires := time.Now().Add(15 * time.Minute).Unix()
+	if err := db.AddSession(c.Context(), "customer_login:"+token, customer.ID, expires); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	loginURL := fmt.Sprintf("https://%s/api/customer/sign/in/%s", setting["domain"].Value.(string), token)
+	if err := mailer.SendLoginLetter(customer.Email, loginURL); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Sign in link sent", nil)
+}
+
+// CustomerSignInToken is ...
+// [get] /api/customer/sign/in/:token
+func CustomerSignInToken(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	loginKey := "customer_login:" + c.Params("token")
+
+	customerID, err := db.GetSession(c.Context(), loginKey)
+	if err != nil || customerID == "" {
+		return c.Redirect("/account")
+	}
+
+	// a sign in link can only be used once
+	if err := db.DeleteSession(c.Context(), loginKey); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	settingJWT, err := queries.GetSettingByGroup[models.JWT](c.Context(), db)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	token := uuid.New().String()
+	expires := time.Now().Add(time.Hour * time.Duration(settingJWT.ExpireHours))
+	if err := db.AddSession(c.Context(), "customer:"+token, customerID, expires.Unix()); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "customer_token",
+		Value:    token,
+		Expires:  expires,
+		HTTPOnly: true,
+		// the token is kept off plain connections whenever the shop is served over https
+		Secure:   c.Protocol() == "https",
+		SameSite: "lax",
+	})
+
+	return c.Redirect("/account")
+}
+
+// CustomerSignOut is ...
+// [post] /api/customer/sign/out
+func CustomerSignOut(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	if err := db.DeleteSession(c.Context(), "customer:"+c.Cookies("customer_token")); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "customer_token",
+		Expires:  time.Now().Add(-(time.Hour * 2)),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "lax",
+	})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Customer is ...
+// [get] /api/customer
+func Customer(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	customerID := c.Locals("customer_id").(string)
+
+	customer, err := db.Customer(c.Context(), customerID)
+	if err != nil {
+		if err == errors.ErrCustomerNotFound {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Customer", customer)
+}
+
+// CustomerPurchases is ...
+// [get] /api/customer/purchases
+func CustomerPurchases(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	customerID := c.Locals("customer_id").(string)
+
+	purchases, err := db.CustomerPurchases(c.Context(), customerID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Purchases", purchases)
+}
+
+// CustomerUnsubscribe is ...
+// [get] /unsubscribe/:token
+func CustomerUnsubscribe(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	customerID, err := db.GetSession(c.Context(), "unsubscribe:"+c.Params("token"))
+	if err != nil || customerID == "" {
+		return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+	}
+
+	if err := db.CustomerUnsubscribe(c.Context(), customerID); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return c.Render("unsubscribe", nil, "layouts/main")
+}
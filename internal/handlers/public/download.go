@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/security"
+	"github.com/shurco/litecart/pkg/storage"
+	"github.com/shurco/litecart/pkg/watermark"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Download is ...
+// [get] /download/:token
+func Download(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	settingJWT, err := queries.GetSettingByGroup[models.JWT](c.Context(), db)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	payload, err := security.Verify(settingJWT.Secret, c.Params("token"))
+	if err != nil {
+		if err == security.ErrExpiredSignature {
+			return c.Status(fiber.StatusGone).Render("download", fiber.Map{
+				"Title":   "Download link has expired",
+				"Message": "Sign in to your account to get a new link to your purchase.",
+			}, "layouts/main")
+		}
+		return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+	}
+
+	cartID, fileID, _ := strings.Cut(payload, ":")
+	file, err := db.CartFile(c.Context(), cartID, fileID)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	fs, err := queries.Storage(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	// the download is recorded before the file is prepared, so a buyer over the limit
+	// gets no stamped copy, and it is no longer counted when the file can not be served
+	download := &models.Download{
+		CartID:    cartID,
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+	if err := db.AddDownload(c.Context(), file, download); err != nil {
+		return downloadError(c, cartID, err)
+	}
+	uncount := func() {
+		if err := db.UncountDownload(c.Context(), download.ID); err != nil {
+			log.ErrorStack(err)
+		}
+	}
+
+	key := queries.DigitalKey(file.Name, file.Ext)
+	if file.Watermark && watermark.Supported(file.Ext) {
+		// the stamped copy is made on the first download and kept for the cart,
+		// the original is never served in its place
+		stamped := queries.WatermarkKey(cartID, file.Name, file.Ext)
+		mark := watermark.Mark{Email: file.Email, OrderID: cartID, Issued: time.Now()}
+		if err := stampFile(c.Context(), fs, key, stamped, file.Ext, mark); err != nil {
+			uncount()
+			if err == storage.ErrNotFound {
+				return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+			}
+			log.ErrorStack(err)
+			return c.Status(fiber.StatusInternalServerError).Render("download", fiber.Map{
+				"Title":   "Download is not ready",
+				"Message": "Your copy of this file could not be prepared. Please try again in a few minutes.",
+			}, "layouts/main")
+		}
+		key = stamped
+	}
+
+	if url, err := fs.URL(c.Context(), key, file.OrigName); err == nil {
+		if _, err := fs.Stat(c.Context(), key); err != nil {
+			uncount()
+			if err == storage.ErrNotFound {
+				return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+			}
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		return c.Redirect(url, fiber.StatusFound)
+	}
+
+	reader, object, err := fs.Get(c.Context(), key)
+	if err != nil {
+		uncount()
+		if err == storage.ErrNotFound {
+			return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	c.Attachment(file.OrigName)
+	c.Set(fiber.HeaderContentType, storage.ContentType(key))
+	return c.SendStream(reader, int(object.Size))
+}
+
+// downloadError answers a download that could not be recorded, the buyer is sent
+// to the support when the limit of the purchase is reached.
+func downloadError(c *fiber.Ctx, cartID string, err error) error {
+	db := queries.DB()
+	log := logging.New()
+
+	if err != errors.ErrDownloadLimit {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	setting, err := db.GetSettingByKey(c.Context(), "email")
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+	email := setting["email"].Value.(string)
+	return c.Status(fiber.StatusForbidden).Render("download", fiber.Map{
+		"Title":   "Download limit reached",
+		"Message": fmt.Sprintf("This purchase can no longer be downloaded. Please contact support at %s and mention order %s.", email, cartID),
+		"Email":   email,
+	}, "layouts/main")
+}
+
+// stampFile stores the watermarked copy of the file unless it already exists.
+func stampFile(ctx context.Context, fs storage.Storage, key, stamped, ext string, mark watermark.Mark) error {
+	if _, err := fs.Stat(ctx, stamped); err == nil {
+		return nil
+	}
+
+	data, err := storage.ReadAll(ctx, fs, key)
+	if err != nil {
+		return err
+	}
+
+	data, err = watermark.Stamp(ext, data, mark)
+	if err != nil {
+		return err
+	}
+
+	return fs.Put(ctx, stamped, bytes.NewReader(data), int64(len(data)))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// LicenseVerify is ...
+// [post] /api/licenses/verify
+func LicenseVerify(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	request := new(models.LicenseVerify)
+
+	if err := c.BodyParser(request); err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := request.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	license, err := db.VerifyLicense(c.Context(), request, c.IP())
+	if err != nil {
+		switch err {
+		case errors.ErrLicenseNotFound:
+			return webutil.StatusNotFound(c)
+		case errors.ErrActivationLimit:
+			return webutil.Response(c, fiber.StatusForbidden, "Activation limit reached", license)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "License", license)
+}
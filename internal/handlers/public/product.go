@@ -1,25 +1,59 @@
 package handlers
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/logging"
 	"github.com/shurco/litecart/pkg/webutil"
 )
 
 // Products is ...
-// [get] /api/products
+// [get] /api/products?category=:category_slug&q=:query&sort=:sort&order=:order&page=:page&limit=:limit&fields=:fields
 func Products(c *fiber.Ctx) error {
 	db := queries.DB()
 	log := logging.New()
+	filter := &models.ProductFilter{
+		Page:  1,
+		Limit: 20,
+	}
+
+	if err := c.QueryParser(filter); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if fields := c.Query("fields"); fields != "" {
+		filter.Fields = strings.Split(fields, ",")
+	}
+
+	if err := filter.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if filter.Category != "" && !db.IsCategory(c.Context(), filter.Category) {
+		return webutil.StatusNotFound(c)
+	}
 
-	products, err := db.ListProducts(c.Context(), false)
+	products, err := db.ListProducts(c.Context(), false, *filter)
 	if err != nil {
 		log.ErrorStack(err)
 		return webutil.StatusInternalServerError(c)
 	}
 
+	if len(filter.Fields) > 0 {
+		selected, err := products.Select(filter.Fields)
+		if err != nil {
+			log.ErrorStack(err)
+			return webutil.StatusInternalServerError(c)
+		}
+		return webutil.Response(c, fiber.StatusOK, "Products", selected)
+	}
+
 	return webutil.Response(c, fiber.StatusOK, "Products", products)
 }
 
@@ -38,3 +72,84 @@ func Product(c *fiber.Ctx) error {
 
 	return webutil.Response(c, fiber.StatusOK, "Product info", product)
 }
+
+// ProductPage is ...
+// [get] /products/:product_slug
+func ProductPage(c *fiber.Ctx) error {
+	productSlug := c.Params("product_slug")
+	db := queries.DB()
+	log := logging.New()
+
+	product, err := db.Product(c.Context(), false, productSlug)
+	if err != nil {
+		if err == errors.ErrProductNotFound {
+			return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	settings, err := db.GetSettingByKey(c.Context(), "domain", "currency")
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+	domain := settings["domain"].Value.(string)
+
+	return c.Render("product", fiber.Map{
+		"ProductSlug":    productSlug,
+		"StructuredData": structuredProduct(product, domain, settings["currency"].Value.(string)),
+	}, "layouts/main")
+}
+
+// structuredProduct describes the product in the schema.org vocabulary read by search engines.
+func structuredProduct(product *models.Product, domain, currency string) map[string]any {
+	data := map[string]any{
+		"@context":    "https://schema.org",
+		"@type":       "Product",
+		"name":        product.Name,
+		"description": product.Brief,
+		"offers": map[string]any{
+			"@type":         "Offer",
+			"price":         fmt.Sprintf("%.2f", float64(product.Price)/100),
+			"priceCurrency": currency,
+			"availability":  "https://schema.org/InStock",
+			"url":           fmt.Sprintf("https://%s/products/%s", domain, product.Slug),
+		},
+	}
+
+	// the variants are sold at their own prices, the offer spans them
+	if len(product.Variants) > 0 {
+		low, high := product.Variants[0].Amount, product.Variants[0].Amount
+		for _, variant := range product.Variants[1:] {
+			low, high = min(low, variant.Amount), max(high, variant.Amount)
+		}
+		data["offers"] = map[string]any{
+			"@type":         "AggregateOffer",
+			"lowPrice":      fmt.Sprintf("%.2f", float64(low)/100),
+			"highPrice":     fmt.Sprintf("%.2f", float64(high)/100),
+			"offerCount":    len(product.Variants),
+			"priceCurrency": currency,
+			"availability":  "https://schema.org/InStock",
+			"url":           fmt.Sprintf("https://%s/products/%s", domain, product.Slug),
+		}
+	}
+
+	if len(product.Images) > 0 {
+		images := make([]string, len(product.Images))
+		for i, image := range product.Images {
+			images[i] = fmt.Sprintf("https://%s/uploads/%s.%s", domain, image.Name, image.Ext)
+		}
+		data["image"] = images
+	}
+
+	if product.Rating != nil {
+		data["aggregateRating"] = map[string]any{
+			"@type":       "AggregateRating",
+			"ratingValue": product.Rating.Value,
+			"reviewCount": product.Rating.Count,
+		}
+	}
+
+	return data
+}
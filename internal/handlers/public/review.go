@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// ReviewPage is ...
+// [get] /review/:token
+func ReviewPage(c *fiber.Ctx) error {
+	db := queries.DB()
+
+	cartID, err := db.GetSession(c.Context(), "review:"+c.Params("token"))
+	if err != nil || cartID == "" {
+		return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
+	}
+
+	return c.Render("review", nil, "layouts/main")
+}
+
+// ReviewProducts is ...
+// [get] /api/reviews/:token
+func ReviewProducts(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+
+	cartID, err := db.GetSession(c.Context(), "review:"+c.Params("token"))
+	if err != nil || cartID == "" {
+		return webutil.StatusNotFound(c)
+	}
+
+	products, err := db.ReviewProducts(c.Context(), cartID)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Review products", products)
+}
+
+// AddReview is ...
+// [post] /api/reviews/:token
+func AddReview(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	review := new(models.Review)
+
+	cartID, err := db.GetSession(c.Context(), "review:"+c.Params("token"))
+	if err != nil || cartID == "" {
+		return webutil.StatusNotFound(c)
+	}
+
+	if err := c.BodyParser(review); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := review.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := db.AddReview(c.Context(), cartID, review); err != nil {
+		if err == errors.ErrReviewNotBought {
+			return webutil.StatusBadRequest(c, err.Error())
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Review added", review)
+}
+
+// ProductReviews is ...
+// [get] /api/products/:product_id/reviews?page=:page&limit=:limit
+func ProductReviews(c *fiber.Ctx) error {
+	db := queries.DB()
+	log := logging.New()
+	filter := &models.ReviewFilter{
+		Page:  1,
+		Limit: 20,
+	}
+
+	if err := c.QueryParser(filter); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	if err := filter.Validate(); err != nil {
+		return webutil.StatusBadRequest(c, err.Error())
+	}
+
+	reviews, err := db.ListReviews(c.Context(), false, c.Params("product_id"), *filter)
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Product reviews", reviews)
+}
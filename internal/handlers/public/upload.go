@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/logging"
+	"github.com/shurco/litecart/pkg/storage"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Upload is ...
+// [get] /uploads/:name
+func Upload(c *fiber.Ctx) error {
+	log := logging.New()
+	key := queries.UploadKey(c.Params("name"))
+
+	fs, err := queries.Storage(c.Context())
+	if err != nil {
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	if url, err := fs.URL(c.Context(), key, ""); err == nil {
+		return c.Redirect(url, fiber.StatusFound)
+	}
+
+	reader, object, err := fs.Get(c.Context(), key)
+	if err != nil {
+		if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+			return webutil.StatusNotFound(c)
+		}
+		log.ErrorStack(err)
+		return webutil.StatusInternalServerError(c)
+	}
+
+	// uploads are never changed in place, a new image gets a new name
+	modified := object.Modified.UTC().Truncate(time.Second)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+	if since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince)); err == nil && !modified.After(since) {
+		reader.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, storage.ContentType(key))
+	return c.SendStream(reader, int(object.Size))
+}
@@ -1,7 +1,13 @@
 package app
 
 import (
+	"context"
+	"os"
+	"time"
+
 	"github.com/shurco/litecart/internal/base"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
 	"github.com/shurco/litecart/migrations"
 	"github.com/shurco/litecart/pkg/fsutil"
 )
@@ -39,3 +45,79 @@ func Migrate() error {
 
 	return nil
 }
+
+// Cleanup is ...
+func Cleanup() (*models.Cleanup, error) {
+	if err := queries.New(migrations.Embed()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db := queries.DB()
+	setting, err := queries.GetSettingByGroup[models.Maintenance](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Cleanup(ctx, setting.CartRetention)
+}
+
+// GarbageCollect removes the orphaned uploads and digital files,
+// a grace period of zero uses the one of the maintenance settings.
+func GarbageCollect(grace time.Duration, dryRun bool) (*models.Garbage, error) {
+	if err := queries.New(migrations.Embed()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db := queries.DB()
+	if grace == 0 {
+		setting, err := queries.GetSettingByGroup[models.Maintenance](ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		grace = time.Duration(setting.GCGrace) * time.Hour
+	}
+
+	return db.CollectGarbage(ctx, grace, dryRun)
+}
+
+// ExportProducts writes all products with their images and files to a zip archive,
+// listed in a "json" or "csv" file.
+func ExportProducts(path, format string) error {
+	if err := queries.New(migrations.Embed()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// a failed export leaves no half written archive behind
+	if err := queries.DB().ExportCatalog(ctx, file, format); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// ImportProducts adds the products of a zip archive made by ExportProducts, or of a
+// json or csv list of products.
+func ImportProducts(path string, upsert, dryRun bool) (*models.CatalogImport, error) {
+	if err := queries.New(migrations.Embed()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	return queries.DB().ImportCatalog(ctx, path, upsert, dryRun)
+}
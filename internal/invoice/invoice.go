@@ -0,0 +1,119 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/shurco/litecart/internal/models"
+)
+
+// PDF renders an invoice as a single A4 page.
+func PDF(invoice *models.Invoice) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(invoice.Code, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// header
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 10, tr("Invoice "+invoice.Code), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	date := time.Unix(invoice.Created, 0).UTC().Format("2006-01-02")
+	pdf.CellFormat(0, 6, "Date: "+date, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Status: paid", "", 1, "L", false, 0, "")
+	pdf.Ln(8)
+
+	// seller and buyer
+	top := pdf.GetY()
+	party(pdf, tr, 20, top, "Seller", []string{
+		invoice.Seller.Name,
+		invoice.Seller.Address,
+		vatLine(invoice.Seller.VatID),
+		invoice.Seller.Email,
+	})
+	party(pdf, tr, 110, top, "Bill to", []string{
+		invoice.Buyer.Name,
+		invoice.Buyer.Company,
+		invoice.Buyer.Address,
+		vatLine(invoice.Buyer.VatID),
+		invoice.Buyer.Email,
+	})
+	pdf.SetXY(20, top+45)
+
+	// items
+	widths := []float64{95, 15, 30, 30}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(240, 240, 240)
+	for i, title := range []string{"Item", "Qty", "Price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, title, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, item := range invoice.Items {
+		pdf.CellFormat(widths[0], 8, tr(item.Name), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 8, fmt.Sprintf("%d", item.Quantity), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 8, amount(item.Amount, invoice.Currency), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 8, amount(item.Amount*item.Quantity, invoice.Currency), "B", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(widths[0]+widths[1]+widths[2], 10, "Total", "", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[3], 10, amount(invoice.AmountTotal, invoice.Currency), "", 1, "R", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Attachment wraps the rendered invoice so it can be attached to a letter.
+func Attachment(invoice *models.Invoice) (*models.Attachment, error) {
+	data, err := PDF(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Attachment{
+		Name:     invoice.Code + ".pdf",
+		MimeType: "application/pdf",
+		Data:     data,
+	}, nil
+}
+
+func party(pdf *fpdf.Fpdf, tr func(string) string, x, y float64, title string, lines []string) {
+	pdf.SetXY(x, y)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(80, 6, title, "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		pdf.MultiCell(80, 5, tr(line), "", "L", false)
+		pdf.SetX(x)
+	}
+}
+
+func vatLine(vatID string) string {
+	if vatID == "" {
+		return ""
+	}
+	return "VAT ID: " + vatID
+}
+
+func amount(value int, currency string) string {
+	return fmt.Sprintf("%.2f %s", float64(value)/100, currency)
+}
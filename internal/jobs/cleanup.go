@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+// Cleanup purges expired sessions, expires stale carts and releases
+// their licence keys, then logs what was changed.
+func Cleanup(ctx context.Context) error {
+	db := queries.DB()
+	log := logging.New()
+
+	setting, err := queries.GetSettingByGroup[models.Maintenance](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	report, err := db.Cleanup(ctx, setting.CartRetention)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Int64("sessions", report.Sessions).
+		Int64("expired_carts", report.ExpiredCarts).
+		Int64("released_keys", report.ReleasedKeys).
+		Msg("cleanup")
+
+	return nil
+}
+
+// GarbageCollection removes the stored files no image or digital file refers to
+// once they are older than the grace period of the maintenance settings.
+func GarbageCollection(ctx context.Context) error {
+	db := queries.DB()
+	log := logging.New()
+
+	setting, err := queries.GetSettingByGroup[models.Maintenance](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	report, err := db.CollectGarbage(ctx, time.Duration(setting.GCGrace)*time.Hour, false)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("orphans", len(report.Orphans)).
+		Int64("removed", report.Removed).
+		Int64("size", report.Size).
+		Msg("garbage collection")
+
+	return nil
+}
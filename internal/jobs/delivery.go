@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/shurco/litecart/internal/delivery"
+	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+// APIDelivery retries the due deliveries of "api" products and sends the
+// purchase letter again to every buyer whose delivery went through.
+func APIDelivery(ctx context.Context) error {
+	log := logging.New()
+
+	delivered, err := delivery.Run(ctx, "", models.CartActorDeliveryJob)
+	if err != nil {
+		return err
+	}
+
+	sent := map[string]bool{}
+	for _, cartID := range delivered {
+		if sent[cartID] {
+			continue
+		}
+		sent[cartID] = true
+
+		if err := mailer.SendCartLetter(cartID, models.CartActorDeliveryJob); err != nil {
+			log.Err(err).Str("cart_id", cartID).Send()
+		}
+	}
+
+	return nil
+}
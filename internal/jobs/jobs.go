@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+// Job is a task that runs periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+var list = []Job{
+	{Name: "cart_recovery", Interval: 15 * time.Minute, Run: CartRecovery},
+	{Name: "cleanup", Interval: time.Hour, Run: Cleanup},
+	{Name: "gc", Interval: 6 * time.Hour, Run: GarbageCollection},
+	{Name: "api_delivery", Interval: time.Minute, Run: APIDelivery},
+	{Name: "release_notices", Interval: time.Minute, Run: ReleaseNotices},
+	{Name: "review_requests", Interval: 15 * time.Minute, Run: ReviewRequests},
+}
+
+// Start launches every registered job in its own goroutine.
+// The jobs are stopped when the context is canceled.
+func Start(ctx context.Context) {
+	for _, job := range list {
+		go run(ctx, job)
+	}
+}
+
+func run(ctx context.Context, job Job) {
+	log := logging.New()
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil {
+				log.Err(err).Str("job", job.Name).Send()
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+const (
+	recoveryLinkTTL    = 7 * 24 * time.Hour
+	unsubscribeLinkTTL = 30 * 24 * time.Hour
+)
+
+// CartRecovery sends a recovery letter for every abandoned cart that
+// is due according to the recovery settings.
+func CartRecovery(ctx context.Context) error {
+	db := queries.DB()
+	log := logging.New()
+
+	setting, err := queries.GetSettingByGroup[models.Recovery](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if !setting.Active {
+		return nil
+	}
+
+	main, err := db.GetSettingByKey(ctx, "domain")
+	if err != nil {
+		return err
+	}
+	domain := main["domain"].Value.(string)
+
+	carts, err := db.RecoveryCarts(ctx, setting.Delay, setting.Frequency)
+	if err != nil {
+		return err
+	}
+
+	for i := range carts {
+		cart := &carts[i]
+
+		recoveryToken := uuid.New().String()
+		if err := db.AddSession(ctx, "cart_recover:"+recoveryToken, cart.ID, time.Now().Add(recoveryLinkTTL).Unix()); err != nil {
+			return err
+		}
+
+		unsubscribeToken := uuid.New().String()
+		if err := db.AddSession(ctx, "unsubscribe:"+unsubscribeToken, cart.CustomerID, time.Now().Add(unsubscribeLinkTTL).Unix()); err != nil {
+			return err
+		}
+
+		recoveryURL := fmt.Sprintf("https://%s/cart?recover=%s", domain, recoveryToken)
+		unsubscribeURL := fmt.Sprintf("https://%s/unsubscribe/%s", domain, unsubscribeToken)
+
+		// a failed letter must not block the remaining carts
+		if err := mailer.SendRecoveryLetter(cart, recoveryURL, unsubscribeURL); err != nil {
+			log.Err(err).Str("cart_id", cart.ID).Send()
+		}
+	}
+
+	return nil
+}
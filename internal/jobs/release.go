@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+// ReleaseNotices sends the queued letters about new versions of files.
+// At most one batch is sent per run, so a release to many buyers is
+// spread over several runs instead of flooding the mail server.
+func ReleaseNotices(ctx context.Context) error {
+	db := queries.DB()
+	log := logging.New()
+
+	setting, err := queries.GetSettingByGroup[models.Delivery](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	notices, err := db.PendingReleaseNotices(ctx, setting.NoticeBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range notices {
+		notice := &notices[i]
+
+		notice.Status = models.ReleaseNoticeSent
+		if err := mailer.SendReleaseLetter(notice); err != nil {
+			// a failed letter must not block the remaining buyers
+			log.Err(err).Str("cart_id", notice.CartID).Send()
+			notice.Status = models.ReleaseNoticeFailed
+			notice.Error = err.Error()
+		}
+
+		if err := db.UpdateReleaseNotice(ctx, notice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
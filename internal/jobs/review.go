@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/litecart/internal/mailer"
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/logging"
+)
+
+const reviewLinkTTL = 30 * 24 * time.Hour
+
+// ReviewRequests asks the buyers of the paid carts that are due according
+// to the review settings to review the products they bought.
+func ReviewRequests(ctx context.Context) error {
+	db := queries.DB()
+	log := logging.New()
+
+	setting, err := queries.GetSettingByGroup[models.ReviewRequest](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if !setting.Active {
+		return nil
+	}
+
+	main, err := db.GetSettingByKey(ctx, "domain")
+	if err != nil {
+		return err
+	}
+	domain := main["domain"].Value.(string)
+
+	carts, err := db.ReviewCarts(ctx, setting.Delay)
+	if err != nil {
+		return err
+	}
+
+	for i := range carts {
+		cart := &carts[i]
+
+		// the links work before the letter goes out, the ones of a letter that could not
+		// be sent are removed, the cart is tried again on the next run
+		reviewToken, unsubscribeToken := uuid.New().String(), uuid.New().String()
+		reviewKey, unsubscribeKey := "review:"+reviewToken, "unsubscribe:"+unsubscribeToken
+		if err := addReviewSessions(ctx, cart, reviewKey, unsubscribeKey); err != nil {
+			log.Err(err).Str("cart_id", cart.ID).Send()
+			continue
+		}
+
+		reviewURL := fmt.Sprintf("https://%s/review/%s", domain, reviewToken)
+		unsubscribeURL := fmt.Sprintf("https://%s/unsubscribe/%s", domain, unsubscribeToken)
+
+		// a failed letter must not block the remaining carts
+		if err := mailer.SendReviewLetter(cart, reviewURL, unsubscribeURL); err != nil {
+			log.Err(err).Str("cart_id", cart.ID).Send()
+			deleteSessions(ctx, reviewKey, unsubscribeKey)
+		}
+	}
+
+	return nil
+}
+
+// addReviewSessions stores the review and unsubscribe links of a cart, none of them
+// is kept when one can not be stored.
+func addReviewSessions(ctx context.Context, cart *models.Cart, reviewKey, unsubscribeKey string) error {
+	db := queries.DB()
+
+	if err := db.AddSession(ctx, reviewKey, cart.ID, time.Now().Add(reviewLinkTTL).Unix()); err != nil {
+		return err
+	}
+	if err := db.AddSession(ctx, unsubscribeKey, cart.CustomerID, time.Now().Add(unsubscribeLinkTTL).Unix()); err != nil {
+		deleteSessions(ctx, reviewKey)
+		return err
+	}
+	return nil
+}
+
+// deleteSessions removes the sessions of links that were never sent, the cleanup
+// removes the ones left when this fails too.
+func deleteSessions(ctx context.Context, keys ...string) {
+	log := logging.New()
+	for _, key := range keys {
+		if err := queries.DB().DeleteSession(ctx, key); err != nil {
+			log.Err(err).Str("session", key).Send()
+		}
+	}
+}
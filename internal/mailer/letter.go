@@ -3,10 +3,13 @@ package mailer
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/shurco/litecart/internal/invoice"
 	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/errors"
 )
 
 // SendTestLetter is ...
@@ -33,10 +36,17 @@ func SendTestLetter(letterName string) error {
 			Text:    "test message",
 		},
 		Data: map[string]string{
-			"Payment_URL":    "https://payment.com/order/1234567890",
-			"Admin_Email":    "Admin Name <admin@mail.com>",
-			"Site_Name":      "Site name",
-			"Amount_Payment": "21.00 USD",
+			"Payment_URL":     "https://payment.com/order/1234567890",
+			"Admin_Email":     "Admin Name <admin@mail.com>",
+			"Site_Name":       "Site name",
+			"Amount_Payment":  "21.00 USD",
+			"Login_URL":       "https://site.com/api/customer/sign/in/1234567890",
+			"Unsubscribe_URL": "https://site.com/unsubscribe/1234567890",
+			"Product_Name":    "Product name",
+			"Version":         "2.0",
+			"Changelog":       "New features and fixes",
+			"Download_URL":    "https://site.com/download/1234567890",
+			"Review_URL":      "https://site.com/review/1234567890",
 		},
 	}
 
@@ -54,7 +64,7 @@ func SendTestLetter(letterName string) error {
 }
 
 // SendPrepaymentLetter is ...
-func SendPrepaymentLetter(email, amountPayment, paymentURL string) error {
+func SendPrepaymentLetter(cartID, email, amountPayment, paymentURL string) error {
 	db := queries.DB()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -74,13 +84,15 @@ func SendPrepaymentLetter(email, amountPayment, paymentURL string) error {
 		return err
 	}
 
-	return nil
+	return addEmailEvent(ctx, cartID, models.CartActorCheckout, "mail_letter_payment", letter.To)
 }
 
 // SendCartLetter is ...
-func SendCartLetter(cartID string) error {
+func SendCartLetter(cartID string, actor models.CartActor) error {
 	db := queries.DB()
 
+	// "api" products are delivered by the delivery job, which sends the letter
+	// again with their content, a payment never waits for an endpoint
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -89,6 +101,115 @@ func SendCartLetter(cartID string) error {
 		return err
 	}
 
+	invoiceInfo, err := db.Invoice(ctx, cartID)
+	if err != nil && err != errors.ErrInvoiceNotFound {
+		return err
+	}
+	if invoiceInfo != nil {
+		attachment, err := invoice.Attachment(invoiceInfo)
+		if err != nil {
+			return err
+		}
+		letter.Attachments = append(letter.Attachments, *attachment)
+	}
+
+	mailSetting, err := queries.GetSettingByGroup[models.Mail](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := SendMail(mailSetting, letter); err != nil {
+		return err
+	}
+
+	return addEmailEvent(ctx, cartID, actor, "mail_letter_purchase", letter.To)
+}
+
+// SendRecoveryLetter is ...
+func SendRecoveryLetter(cart *models.Cart, recoveryURL, unsubscribeURL string) error {
+	db := queries.DB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	amountPayment := fmt.Sprintf("%.2f %s", float64(cart.AmountTotal)/100, cart.Currency)
+	letter, err := db.CartLetterRecovery(ctx, cart.Email, amountPayment, recoveryURL, unsubscribeURL)
+	if err != nil {
+		return err
+	}
+
+	mailSetting, err := queries.GetSettingByGroup[models.Mail](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := SendMail(mailSetting, letter); err != nil {
+		return err
+	}
+
+	return addEmailEvent(ctx, cart.ID, models.CartActorRecoveryJob, "mail_letter_recovery", letter.To)
+}
+
+// SendReleaseLetter is ...
+func SendReleaseLetter(notice *models.ReleaseNotice) error {
+	db := queries.DB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	letter, err := db.ReleaseLetter(ctx, notice)
+	if err != nil {
+		return err
+	}
+
+	mailSetting, err := queries.GetSettingByGroup[models.Mail](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := SendMail(mailSetting, letter); err != nil {
+		return err
+	}
+
+	return addEmailEvent(ctx, notice.CartID, models.CartActorReleaseJob, "mail_letter_release", letter.To)
+}
+
+// SendReviewLetter is ...
+func SendReviewLetter(cart *models.Cart, reviewURL, unsubscribeURL string) error {
+	db := queries.DB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	letter, err := db.ReviewLetter(ctx, cart, reviewURL, unsubscribeURL)
+	if err != nil {
+		return err
+	}
+
+	mailSetting, err := queries.GetSettingByGroup[models.Mail](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := SendMail(mailSetting, letter); err != nil {
+		return err
+	}
+
+	return addEmailEvent(ctx, cart.ID, models.CartActorReviewJob, "mail_letter_review", letter.To)
+}
+
+// SendLoginLetter is ...
+func SendLoginLetter(email, loginURL string) error {
+	db := queries.DB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	letter, err := db.CustomerLetterLogin(ctx, email, loginURL)
+	if err != nil {
+		return err
+	}
+
 	mailSetting, err := queries.GetSettingByGroup[models.Mail](ctx, db)
 	if err != nil {
 		return err
@@ -100,3 +221,16 @@ func SendCartLetter(cartID string) error {
 
 	return nil
 }
+
+// addEmailEvent records a sent letter in the audit trail of the cart.
+func addEmailEvent(ctx context.Context, cartID string, actor models.CartActor, letterName, to string) error {
+	return queries.DB().AddCartEvent(ctx, &models.CartEvent{
+		CartID: cartID,
+		Event:  models.CartEventEmailSent,
+		Actor:  actor,
+		Payload: map[string]any{
+			"letter": letterName,
+			"to":     to,
+		},
+	})
+}
@@ -48,13 +48,12 @@ func SendMail(smtp *models.Mail, mail *models.MessageMail) error {
 	email.SetBodyData(mailer.TextPlain, bodyText)
 	// email.AddAlternativeData(mail.TextPlain, "Hello Gophers!")
 
-	if len(mail.Files) > 0 {
-		for _, file := range mail.Files {
-			email.Attach(&mailer.File{
-				FilePath: fmt.Sprintf("./lc_digitals/%s.%s", file.Name, file.Ext),
-				Name:     file.OrigName,
-			})
-		}
+	for _, attachment := range mail.Attachments {
+		email.Attach(&mailer.File{
+			Name:     attachment.Name,
+			MimeType: attachment.MimeType,
+			Data:     attachment.Data,
+		})
 	}
 
 	if err := email.Send(smtpClient); err != nil {
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/shurco/litecart/internal/queries"
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// CustomerProtected is ...
+func CustomerProtected() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		token := c.Cookies("customer_token")
+		if token == "" {
+			return webutil.Response(c, fiber.StatusUnauthorized, "Unauthorized", "missing customer token")
+		}
+
+		customerID, err := queries.DB().GetSession(c.Context(), "customer:"+token)
+		if err != nil || customerID == "" {
+			return webutil.Response(c, fiber.StatusUnauthorized, "Unauthorized", "invalid or expired customer token")
+		}
+
+		c.Locals("customer_id", customerID)
+		return c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+
+	"github.com/shurco/litecart/pkg/webutil"
+)
+
+// Limiter allows max requests from one IP address during the expiration window.
+func Limiter(max int, expiration time.Duration) func(*fiber.Ctx) error {
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: expiration,
+		LimitReached: func(c *fiber.Ctx) error {
+			return webutil.Response(c, fiber.StatusTooManyRequests, "Too Many Requests", nil)
+		},
+	})
+}
@@ -1,12 +1,20 @@
 package models
 
-import "github.com/shurco/litecart/pkg/litepay"
+import (
+	"errors"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/shurco/litecart/pkg/litepay"
+)
 
 // Cart is ...
 type Cart struct {
 	Core
 	Email         string                `json:"email"`
+	CustomerID    string                `json:"customer_id,omitempty"`
 	Cart          []CartProduct         `json:"cart,omitempty"`
+	Billing       Billing               `json:"billing"`
 	AmountTotal   int                   `json:"amount_total"`
 	Currency      string                `json:"currency"`
 	PaymentID     string                `json:"payment_id"`
@@ -17,7 +25,24 @@ type Cart struct {
 // CartProduct is ...
 type CartProduct struct {
 	ProductID string `json:"id"`
+	VariantID string `json:"variant_id,omitempty"`
 	Quantity  int    `json:"quantity"`
+	// unit price charged at checkout, always computed by the server
+	Amount *int `json:"amount,omitempty"`
+	// products of a bundle as they were at checkout, set by the server too
+	Bundle []CartProduct `json:"bundle,omitempty"`
+}
+
+// MaxCartQuantity caps the quantity of a product in one cart.
+const MaxCartQuantity = 100
+
+// Validate is ...
+func (v CartProduct) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.ProductID, validation.Required, validation.Length(15, 15)),
+		validation.Field(&v.VariantID, validation.Length(15, 15)),
+		validation.Field(&v.Quantity, validation.Required, validation.Min(1), validation.Max(MaxCartQuantity)),
+	)
 }
 
 // CartPayment is ...
@@ -25,4 +50,134 @@ type CartPayment struct {
 	Email    string                `json:"email"`
 	Provider litepay.PaymentSystem `json:"provider"`
 	Products []CartProduct         `json:"products"`
+	Billing  Billing               `json:"billing"`
+}
+
+// Validate is ...
+func (v CartPayment) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Products, validation.Required, validation.By(uniqueCartProducts)),
+		validation.Field(&v.Billing),
+	)
+}
+
+// uniqueCartProducts rejects a cart that lists a product, or a variant of it, twice.
+func uniqueCartProducts(value any) error {
+	seen := map[[2]string]bool{}
+	for _, product := range value.([]CartProduct) {
+		line := [2]string{product.ProductID, product.VariantID}
+		if seen[line] {
+			return errors.New("must not list a product twice")
+		}
+		seen[line] = true
+	}
+	return nil
+}
+
+// CartEventType is ...
+type CartEventType string
+
+const (
+	CartEventCreated       CartEventType = "created"
+	CartEventStatusChanged CartEventType = "status_changed"
+	CartEventEmailSent     CartEventType = "email_sent"
+	CartEventWebhookSent   CartEventType = "webhook_sent"
+	CartEventRefund        CartEventType = "refund"
+	CartEventAdminAction   CartEventType = "admin_action"
+	CartEventDelivery      CartEventType = "delivery"
+)
+
+// CartActor is ...
+type CartActor string
+
+const (
+	CartActorCheckout          CartActor = "checkout"
+	CartActorProviderCallback  CartActor = "provider_callback"
+	CartActorSuccessRedirect   CartActor = "success_redirect"
+	CartActorCancelRedirect    CartActor = "cancel_redirect"
+	CartActorAdmin             CartActor = "admin"
+	CartActorReconciliationJob CartActor = "reconciliation_job"
+	CartActorRecoveryJob       CartActor = "recovery_job"
+	CartActorDeliveryJob       CartActor = "delivery_job"
+	CartActorReleaseJob        CartActor = "release_job"
+	CartActorReviewJob         CartActor = "review_job"
+)
+
+// CartEvent is ...
+type CartEvent struct {
+	ID      string         `json:"id"`
+	CartID  string         `json:"cart_id"`
+	Event   CartEventType  `json:"event"`
+	Actor   CartActor      `json:"actor"`
+	Payload map[string]any `json:"payload,omitempty"`
+	Created int64          `json:"created"`
+}
+
+// CartDeliveryStatus is ...
+type CartDeliveryStatus string
+
+const (
+	CartDeliveryPending CartDeliveryStatus = "pending"
+	// claimed by a caller that is calling the endpoint
+	CartDeliveryProcessing CartDeliveryStatus = "processing"
+	CartDeliveryDelivered  CartDeliveryStatus = "delivered"
+	CartDeliveryFailed     CartDeliveryStatus = "failed"
+)
+
+// CartDelivery is the delivery of an "api" product of a paid cart.
+type CartDelivery struct {
+	ID          string             `json:"id"`
+	CartID      string             `json:"cart_id"`
+	ProductID   string             `json:"product_id"`
+	ProductName string             `json:"product_name,omitempty"`
+	Status      CartDeliveryStatus `json:"status"`
+	Attempts    int                `json:"attempts"`
+	NextAttempt int64              `json:"next_attempt,omitempty"`
+	Content     string             `json:"content,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	Created     int64              `json:"created"`
+	Updated     int64              `json:"updated,omitempty"`
+}
+
+// CartDeliveryRequest is the signed payload sent to the endpoint of an "api" product.
+type CartDeliveryRequest struct {
+	CartID    string `json:"cart_id"`
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Email     string `json:"email"`
+	Quantity  int    `json:"quantity"`
+	Attempt   int    `json:"attempt"`
+	TimeStamp int64  `json:"timestamp"`
+}
+
+// CartStatus is ...
+type CartStatus struct {
+	Status litepay.Status `json:"status"`
+	Note   string         `json:"note,omitempty"`
+}
+
+// Validate is ...
+func (v CartStatus) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Status, validation.Required, validation.In(litepay.NEW, litepay.UNPAID, litepay.PAID, litepay.CANCELED, litepay.FAILED, litepay.PROCESSED, litepay.REFUNDED, litepay.EXPIRED)),
+		validation.Field(&v.Note, validation.Length(0, 255)),
+	)
+}
+
+// CartRecovery is ...
+type CartRecovery struct {
+	Email    string                `json:"email"`
+	Provider litepay.PaymentSystem `json:"provider"`
+	Items    []CartRecoveryItem    `json:"items"`
+}
+
+// CartRecoveryItem is ...
+type CartRecoveryItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Amount      int    `json:"amount"`
+	Image       *File  `json:"image"`
+	VariantID   string `json:"variant_id,omitempty"`
+	VariantName string `json:"variant_name,omitempty"`
 }
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// CatalogProduct is a product as it is moved between shops by an export and an import.
+// Images and files are entries of the archive the catalog is exported with, named
+// <name>.<ext> in its images and files directories.
+type CatalogProduct struct {
+	Name        string        `json:"name"`
+	Slug        string        `json:"slug"`
+	Brief       string        `json:"brief,omitempty"`
+	Description string        `json:"description"`
+	Amount      int           `json:"amount"`
+	Metadata    []Metadata    `json:"metadata,omitempty"`
+	Attributes  []string      `json:"attributes,omitempty"`
+	Seo         *Seo          `json:"seo,omitempty"`
+	Digital     string        `json:"digital"`
+	Images      []CatalogFile `json:"images,omitempty"`
+	Files       []CatalogFile `json:"files,omitempty"`
+}
+
+// CatalogFile is an image or a file of a catalog product.
+type CatalogFile struct {
+	Name     string `json:"name"`
+	Ext      string `json:"ext"`
+	OrigName string `json:"orig_name,omitempty"`
+}
+
+// Product returns the product a catalog entry describes.
+func (v CatalogProduct) Product() Product {
+	return Product{
+		Name:        v.Name,
+		Slug:        v.Slug,
+		Brief:       v.Brief,
+		Description: v.Description,
+		Amount:      v.Amount,
+		Metadata:    v.Metadata,
+		Attributes:  v.Attributes,
+		Seo:         v.Seo,
+		Digital:     Digital{Type: v.Digital},
+	}
+}
+
+// Validate checks a catalog entry with rules of its own, a catalog of another shop
+// may have longer names and metadata of any text.
+func (v CatalogProduct) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Name, validation.Required, validation.Length(3, 254)),
+		validation.Field(&v.Slug, validation.Required, validation.Length(3, 20)),
+		validation.Field(&v.Amount, validation.Required, validation.Min(0)),
+		validation.Field(&v.Metadata, validation.By(catalogMetadata), validation.Skip),
+		validation.Field(&v.Attributes, validation.Each(validation.Length(3, 254))),
+		validation.Field(&v.Seo),
+		validation.Field(&v.Digital, validation.Required, validation.In("file", "data", "api", "generated", "bundle")),
+	)
+}
+
+// catalogMetadata checks the metadata of a catalog product, a value is any non empty text.
+func catalogMetadata(value any) error {
+	errs := validation.Errors{}
+	for i, metadata := range value.([]Metadata) {
+		err := validation.ValidateStruct(&metadata,
+			validation.Field(&metadata.Key, validation.Required, validation.Length(1, 20)),
+			validation.Field(&metadata.Value, validation.Required),
+		)
+		if err != nil {
+			errs[strconv.Itoa(i)] = err
+		}
+	}
+	return errs.Filter()
+}
+
+// CatalogImport is a report of a catalog import, rows are counted from 1 in the order
+// of the products in the catalog. Products whose slug is taken are skipped unless
+// the import updates them.
+type CatalogImport struct {
+	DryRun   bool           `json:"dry_run"`
+	Inserted int            `json:"inserted"`
+	Updated  int            `json:"updated"`
+	Skipped  []int          `json:"skipped"`
+	Invalid  []CatalogError `json:"invalid"`
+}
+
+// CatalogError is a product of a catalog that can not be imported.
+type CatalogError struct {
+	Row   int    `json:"row"`
+	Slug  string `json:"slug"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// slugRegexp is the same rule the admin panel checks slugs with.
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// Category is a node of the catalog tree, a product can be linked to
+// any number of categories and is listed in their parents as well.
+type Category struct {
+	Core
+	ParentID    string `json:"parent_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Sort        int    `json:"sort"`
+	Active      bool   `json:"active"`
+	Seo         *Seo   `json:"seo,omitempty"`
+}
+
+// Validate is ...
+func (v Category) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.ID, validation.Length(15, 15)),
+		validation.Field(&v.ParentID, validation.Length(15, 15), validation.When(v.ID != "", validation.NotIn(v.ID).Error("a category can not be its own parent"))),
+		validation.Field(&v.Name, validation.Required, validation.Length(3, 50)),
+		validation.Field(&v.Slug, validation.Required, validation.Length(3, 50), validation.Match(slugRegexp)),
+		validation.Field(&v.Description, validation.Length(0, 4096)),
+		validation.Field(&v.Seo),
+	)
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+
+	"github.com/shurco/litecart/pkg/litepay"
+)
+
+// Customers is ...
+type Customers struct {
+	Total     int        `json:"total"`
+	Currency  string     `json:"currency"`
+	Customers []Customer `json:"customers"`
+}
+
+// Customer is ...
+type Customer struct {
+	Core
+	Email         string `json:"email"`
+	OrderCount    int    `json:"order_count"`
+	LifetimeValue int    `json:"lifetime_value"`
+	Unsubscribed  bool   `json:"unsubscribed"`
+}
+
+// CustomerSignIn is ...
+type CustomerSignIn struct {
+	Email string `json:"email"`
+}
+
+// Validate is ...
+func (v CustomerSignIn) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Email, validation.Required, is.Email),
+	)
+}
+
+// Purchase is ...
+type Purchase struct {
+	CartID        string            `json:"cart_id"`
+	AmountTotal   int               `json:"amount_total"`
+	Currency      string            `json:"currency"`
+	PaymentStatus litepay.Status    `json:"payment_status"`
+	Created       int64             `json:"created"`
+	Products      []PurchaseProduct `json:"products"`
+}
+
+// PurchaseProduct is ...
+type PurchaseProduct struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Slug     string         `json:"slug"`
+	Variant  string         `json:"variant,omitempty"`
+	Quantity int            `json:"quantity"`
+	Keys     []string       `json:"keys,omitempty"`
+	Files    []PurchaseFile `json:"files,omitempty"`
+	Delivery string         `json:"delivery,omitempty"`
+	// Bundle holds the products delivered for a bundle
+	Bundle []PurchaseProduct `json:"bundle,omitempty"`
+
+	DownloadTTL int `json:"-"`
+}
+
+// PurchaseFile is ...
+type PurchaseFile struct {
+	File
+	URL string `json:"url"`
+}
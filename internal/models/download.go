@@ -0,0 +1,25 @@
+package models
+
+// Download is ...
+type Download struct {
+	ID        string `json:"id"`
+	CartID    string `json:"cart_id"`
+	ProductID string `json:"product_id"`
+	FileID    string `json:"file_id"`
+	FileName  string `json:"file_name,omitempty"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Counted   bool   `json:"counted"`
+	Created   int64  `json:"created"`
+}
+
+// DownloadFile is a digital file of a paid cart together with the
+// download limits of the product it belongs to.
+type DownloadFile struct {
+	File
+	ProductID string `json:"product_id"`
+	Limit     int    `json:"limit"`
+	IPLimit   int    `json:"ip_limit"`
+	Watermark bool   `json:"watermark"`
+	Email     string `json:"email"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Billing is ...
+type Billing struct {
+	Name    string `json:"name,omitempty"`
+	Company string `json:"company,omitempty"`
+	Address string `json:"address,omitempty"`
+	VatID   string `json:"vat_id,omitempty"`
+}
+
+// Validate is ...
+func (v Billing) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Name, validation.Length(0, 100)),
+		validation.Field(&v.Company, validation.Length(0, 100)),
+		validation.Field(&v.Address, validation.Length(0, 255)),
+		validation.Field(&v.VatID, validation.Length(0, 30)),
+	)
+}
+
+// Invoice is ...
+type Invoice struct {
+	ID          string        `json:"id"`
+	CartID      string        `json:"cart_id"`
+	Number      int           `json:"number"`
+	Code        string        `json:"code"`
+	Seller      Seller        `json:"seller"`
+	Buyer       InvoiceBuyer  `json:"buyer"`
+	Items       []InvoiceItem `json:"items"`
+	AmountTotal int           `json:"amount_total"`
+	Currency    string        `json:"currency"`
+	Created     int64         `json:"created"`
+}
+
+// InvoiceBuyer is ...
+type InvoiceBuyer struct {
+	Email string `json:"email"`
+	Billing
+}
+
+// InvoiceItem is ...
+type InvoiceItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Amount   int    `json:"amount"`
+}
+
+// Seller is ...
+type Seller struct {
+	Prefix  string `json:"prefix,omitempty"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	VatID   string `json:"vat_id"`
+	Email   string `json:"email"`
+}
+
+// Validate is ...
+func (v Seller) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Prefix, validation.Length(0, 10)),
+		validation.Field(&v.Name, validation.Length(0, 100)),
+		validation.Field(&v.Address, validation.Length(0, 255)),
+		validation.Field(&v.VatID, validation.Length(0, 30)),
+	)
+}
+
+// InvoiceCode formats an invoice number with the seller prefix.
+func InvoiceCode(prefix string, number int) string {
+	return fmt.Sprintf("%s%06d", prefix, number)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/shurco/litecart/pkg/litepay"
+)
+
+// LicenseVerify is a request to check a license key sold through the shop.
+// When Activate is set, the instance is registered as an activation of the key.
+type LicenseVerify struct {
+	Key       string `json:"key"`
+	ProductID string `json:"product_id,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Activate  bool   `json:"activate,omitempty"`
+}
+
+// Validate is ...
+func (v LicenseVerify) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Key, validation.Required, validation.Length(1, 1024)),
+		validation.Field(&v.ProductID, validation.Length(15, 15)),
+		validation.Field(&v.Instance, validation.When(v.Activate, validation.Required), validation.Length(1, 128)),
+	)
+}
+
+// License is the result of a license verification.
+type License struct {
+	Valid           bool           `json:"valid"`
+	ProductID       string         `json:"product_id"`
+	ProductName     string         `json:"product_name"`
+	Purchased       int64          `json:"purchased"`
+	Status          litepay.Status `json:"status"`
+	Activations     int            `json:"activations"`
+	ActivationLimit int            `json:"activation_limit"`
+}
+
+// LicenseActivation is ...
+type LicenseActivation struct {
+	ID        string `json:"id"`
+	CartID    string `json:"cart_id"`
+	ProductID string `json:"product_id"`
+	Key       string `json:"key"`
+	Instance  string `json:"instance"`
+	IP        string `json:"ip"`
+	Created   int64  `json:"created"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
@@ -8,10 +10,77 @@ import (
 // Products is ...
 type Products struct {
 	Total    int       `json:"total"`
+	Page     int       `json:"page,omitempty"`
+	Limit    int       `json:"limit,omitempty"`
 	Currency string    `json:"currency"`
 	Products []Product `json:"products"`
 }
 
+// ProductFilter narrows and orders a list of products.
+type ProductFilter struct {
+	// slug of a category, the products of its subcategories are listed too
+	Category string `query:"category"`
+	// full-text search request, the products are ranked by relevance
+	// unless another sort is asked for
+	Query string `query:"q"`
+	// price, created or name, the newest products come first by default
+	Sort  string `query:"sort"`
+	Order string `query:"order"`
+	// page numbers start at 1, the queries list every product when
+	// the limit is 0 but a request has to stay within 100 per page
+	Page  int `query:"page"`
+	Limit int `query:"limit"`
+	// json keys kept for every product, all of them when empty
+	Fields []string `query:"-"`
+	// deleted products are listed instead of the others, only for the admin
+	Trash bool `query:"trash"`
+}
+
+// ProductFields lists the keys a product list can be narrowed to.
+var ProductFields = []any{"id", "name", "brief", "slug", "amount", "price", "sale", "active", "digital", "images", "variants", "snippet", "created"}
+
+// Validate is ...
+func (v ProductFilter) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Sort, validation.In("price", "created", "name")),
+		validation.Field(&v.Order, validation.In("asc", "desc")),
+		validation.Field(&v.Page, validation.Required, validation.Min(1)),
+		validation.Field(&v.Limit, validation.Required, validation.Max(100)),
+		validation.Field(&v.Fields, validation.Each(validation.In(ProductFields...))),
+	)
+}
+
+// Select returns the list with only the given keys left in every product.
+func (v *Products) Select(fields []string) (map[string]any, error) {
+	products := make([]map[string]any, len(v.Products))
+	for i, product := range v.Products {
+		data, err := json.Marshal(product)
+		if err != nil {
+			return nil, err
+		}
+
+		full := map[string]any{}
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, err
+		}
+
+		products[i] = map[string]any{}
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				products[i][field] = value
+			}
+		}
+	}
+
+	return map[string]any{
+		"total":    v.Total,
+		"page":     v.Page,
+		"limit":    v.Limit,
+		"currency": v.Currency,
+		"products": products,
+	}, nil
+}
+
 // Product is ...
 type Product struct {
 	Core
@@ -23,9 +92,28 @@ type Product struct {
 	Amount      int        `json:"amount"`
 	Metadata    []Metadata `json:"metadata,omitempty"`
 	Attributes  []string   `json:"attributes,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
 	Digital     Digital    `json:"digital,omitempty"`
 	Active      bool       `json:"active"`
 	Seo         *Seo       `json:"seo,omitempty"`
+	// fragment of the product matching a search with the words in <mark>
+	Snippet string `json:"snippet,omitempty"`
+	// editions of the product sold at their own price instead of the amount
+	Variants []ProductVariant `json:"variants,omitempty"`
+	// scheduled sale of the product, the price is the amount or the sale
+	// amount while the sale runs and is never taken from a request
+	Sale  *Sale `json:"sale,omitempty"`
+	Price int   `json:"price"`
+	// download limits per purchase, 0 means no limit or the global link lifetime
+	DownloadLimit   int `json:"download_limit"`
+	DownloadIPLimit int `json:"download_ip_limit"`
+	DownloadTTL     int `json:"download_ttl"`
+	// number of instances a sold key can be activated on, 0 means no limit
+	ActivationLimit int `json:"activation_limit"`
+	// stamp the buyer into delivered pdf and zip files
+	Watermark bool `json:"watermark"`
+	// average of the approved reviews, empty until one is approved
+	Rating *Rating `json:"rating,omitempty"`
 }
 
 // Validate is ...
@@ -39,8 +127,32 @@ func (v Product) Validate() error {
 		validation.Field(&v.Amount, validation.Required, validation.Min(0)),
 		validation.Field(&v.Metadata),
 		validation.Field(&v.Attributes, validation.Each(validation.Length(3, 254))),
+		validation.Field(&v.Categories, validation.Each(validation.Length(15, 15))),
+		validation.Field(&v.Variants),
+		validation.Field(&v.Sale),
 		validation.Field(&v.Digital),
 		validation.Field(&v.Seo),
+		validation.Field(&v.DownloadLimit, validation.Min(0)),
+		validation.Field(&v.DownloadIPLimit, validation.Min(0)),
+		validation.Field(&v.DownloadTTL, validation.Min(0)),
+		validation.Field(&v.ActivationLimit, validation.Min(0)),
+	)
+}
+
+// Sale is a price a product is sold at for a limited time. A sale without
+// a start begins right away and one without an end runs until it is removed.
+type Sale struct {
+	Amount int   `json:"amount"`
+	Start  int64 `json:"start,omitempty"`
+	End    int64 `json:"end,omitempty"`
+}
+
+// Validate is ...
+func (v Sale) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Amount, validation.Min(0)),
+		validation.Field(&v.Start, validation.Min(0)),
+		validation.Field(&v.End, validation.When(v.Start > 0, validation.Min(v.Start+1).Error("must be after the start"))),
 	)
 }
 
@@ -60,18 +172,85 @@ func (v Metadata) Validate() error {
 
 // Digital is ...
 type Digital struct {
-	Type   string `json:"type"`
-	Filled bool   `json:"filled,omitempty"`
-	Files  []File `json:"files,omitempty"`
-	Data   []Data `json:"data,omitempty"`
+	Type      string            `json:"type"`
+	Filled    bool              `json:"filled,omitempty"`
+	Files     []File            `json:"files,omitempty"`
+	Data      []Data            `json:"data,omitempty"`
+	API       *DigitalAPI       `json:"api,omitempty"`
+	Generator *DigitalGenerator `json:"generator,omitempty"`
+	Bundle    []BundleItem      `json:"bundle,omitempty"`
 }
 
 // Validate is ...
 func (v Digital) Validate() error {
 	return validation.ValidateStruct(&v,
-		validation.Field(&v.Type, validation.In("file", "data", "api")),
+		validation.Field(&v.Type, validation.In("file", "data", "api", "generated", "bundle")),
 		validation.Field(&v.Files),
 		validation.Field(&v.Data, validation.Each(validation.Length(1, 254))),
+		validation.Field(&v.API),
+		validation.Field(&v.Generator),
+		validation.Field(&v.Bundle),
+	)
+}
+
+// DigitalAPI is an endpoint that is called on payment to deliver an "api" product.
+type DigitalAPI struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// Validate is ...
+func (v DigitalAPI) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.URL, validation.Required, is.URL),
+		validation.Field(&v.Secret, validation.Length(16, 64)),
+	)
+}
+
+// BundleItem is a product delivered as a part of a "bundle" product. The variant
+// has to be set when the product is sold in variants.
+type BundleItem struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// Validate is ...
+func (v BundleItem) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.ProductID, validation.Required, validation.Length(15, 15)),
+		validation.Field(&v.VariantID, validation.Length(15, 15)),
+	)
+}
+
+// DigitalBundle lists the products of a "bundle" product in the order they are delivered.
+type DigitalBundle struct {
+	Products []BundleItem `json:"products"`
+}
+
+// Validate is ...
+func (v DigitalBundle) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Products, validation.Required, validation.Length(1, 50)),
+	)
+}
+
+// DigitalGenerator describes how the keys of a "generated" product are made.
+// The "pattern" mode fills the X placeholders of the pattern with random characters
+// of the charset, the "signed" mode issues an Ed25519 signed license.
+type DigitalGenerator struct {
+	Mode     string `json:"mode"`
+	Pattern  string `json:"pattern,omitempty"`
+	Charset  string `json:"charset,omitempty"`
+	Checksum bool   `json:"checksum,omitempty"`
+}
+
+// Validate is ...
+func (v DigitalGenerator) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Mode, validation.Required, validation.In("pattern", "signed")),
+		validation.Field(&v.Pattern, validation.When(v.Mode == "pattern", validation.Required, validation.Length(4, 64))),
+		validation.Field(&v.Charset, validation.Length(2, 64), is.PrintableASCII),
 	)
 }
 
@@ -81,6 +260,13 @@ type File struct {
 	Name     string `json:"name"`
 	Ext      string `json:"ext"`
 	OrigName string `json:"orig_name,omitempty"`
+	// versions of a digital file share the lineage of the first one,
+	// buyers always get the active version of the lineage
+	LineageID string `json:"lineage_id,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Changelog string `json:"changelog,omitempty"`
+	Active    bool   `json:"active,omitempty"`
+	VariantID string `json:"variant_id,omitempty"`
 }
 
 // Validate is ...
@@ -92,11 +278,28 @@ func (v File) Validate() error {
 	)
 }
 
+// FileVersion is ...
+type FileVersion struct {
+	Version   string `json:"version"`
+	Changelog string `json:"changelog"`
+	// activates the version when true, the flag is left as it is when omitted
+	Active *bool `json:"active,omitempty"`
+}
+
+// Validate is ...
+func (v FileVersion) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Version, validation.Length(1, 32)),
+		validation.Field(&v.Changelog, validation.Length(0, 4096)),
+	)
+}
+
 // Data is ...
 type Data struct {
-	ID      string `json:"id"`
-	Content string `json:"content"`
-	CartID  string `json:"cart_id"`
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	CartID    string `json:"cart_id"`
+	VariantID string `json:"variant_id,omitempty"`
 }
 
 // Validate is ...
@@ -107,3 +310,11 @@ func (v Data) Validate() error {
 		// validation.Field(&v.Ext, validation.In("jpeg", "png")),
 	)
 }
+
+// DigitalImport is a report of a bulk import of digital data,
+// duplicate and invalid entries are listed by their line number.
+type DigitalImport struct {
+	Inserted  int   `json:"inserted"`
+	Duplicate []int `json:"duplicate"`
+	Invalid   []int `json:"invalid"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// ReleaseNoticeStatus is ...
+type ReleaseNoticeStatus string
+
+const (
+	ReleaseNoticePending ReleaseNoticeStatus = "pending"
+	ReleaseNoticeSent    ReleaseNoticeStatus = "sent"
+	ReleaseNoticeFailed  ReleaseNoticeStatus = "failed"
+)
+
+// ReleaseNotice is a queued letter that tells a past buyer about a new version of a file.
+type ReleaseNotice struct {
+	ID        string              `json:"id"`
+	ProductID string              `json:"product_id"`
+	FileID    string              `json:"file_id"`
+	CartID    string              `json:"cart_id"`
+	Email     string              `json:"email"`
+	Status    ReleaseNoticeStatus `json:"status"`
+	Error     string              `json:"error,omitempty"`
+}
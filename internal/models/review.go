@@ -0,0 +1,105 @@
+package models
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// ReviewStatus is ...
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// Review is the rating and the opinion of a buyer about a product of a paid cart,
+// it is shown on the product page once it is approved.
+type Review struct {
+	ID          string       `json:"id"`
+	ProductID   string       `json:"product_id"`
+	ProductName string       `json:"product_name,omitempty"`
+	Email       string       `json:"email,omitempty"`
+	Name        string       `json:"name"`
+	Rating      int          `json:"rating"`
+	Text        string       `json:"text"`
+	Status      ReviewStatus `json:"status,omitempty"`
+	// answer of the shop shown under the review
+	Reply   string `json:"reply,omitempty"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated,omitempty"`
+}
+
+// Validate is ...
+func (v Review) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.ProductID, validation.Required, validation.Length(15, 15)),
+		validation.Field(&v.Name, validation.Length(0, 50)),
+		validation.Field(&v.Rating, validation.Required, validation.Min(1), validation.Max(5)),
+		validation.Field(&v.Text, validation.Length(0, 2000)),
+	)
+}
+
+// Reviews is a page of reviews with the rating of everything they were taken from.
+type Reviews struct {
+	Total   int      `json:"total"`
+	Page    int      `json:"page,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+	Rating  *Rating  `json:"rating,omitempty"`
+	Reviews []Review `json:"reviews"`
+}
+
+// ReviewFilter narrows a list of reviews.
+type ReviewFilter struct {
+	// pending, approved or rejected, all of them when empty
+	Status ReviewStatus `query:"status"`
+	Page   int          `query:"page"`
+	Limit  int          `query:"limit"`
+}
+
+// Validate is ...
+func (v ReviewFilter) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Status, validation.In(ReviewPending, ReviewApproved, ReviewRejected)),
+		validation.Field(&v.Page, validation.Required, validation.Min(1)),
+		validation.Field(&v.Limit, validation.Required, validation.Max(100)),
+	)
+}
+
+// ReviewModeration is a decision of the admin about a review.
+type ReviewModeration struct {
+	Status ReviewStatus `json:"status"`
+}
+
+// Validate is ...
+func (v ReviewModeration) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Status, validation.Required, validation.In(ReviewApproved, ReviewRejected)),
+	)
+}
+
+// ReviewReply is ...
+type ReviewReply struct {
+	Reply string `json:"reply"`
+}
+
+// Validate is ...
+func (v ReviewReply) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Reply, validation.Length(0, 2000)),
+	)
+}
+
+// ReviewProduct is a product a review link can review, with the review already left.
+type ReviewProduct struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Slug      string  `json:"slug"`
+	Review    *Review `json:"review,omitempty"`
+}
+
+// Rating is the average of the approved reviews of a product.
+type Rating struct {
+	Value float64 `json:"value"`
+	Count int     `json:"count"`
+}
@@ -132,6 +132,116 @@ func (v Webhook) Validate() error {
 		validation.Field(&v.Url, is.URL))
 }
 
+// Recovery is ...
+type Recovery struct {
+	Active    bool `json:"active"`
+	Delay     int  `json:"delay"`
+	Frequency int  `json:"frequency"`
+}
+
+// Validate is ...
+func (v Recovery) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Delay, validation.Required, validation.Min(1)),
+		validation.Field(&v.Frequency, validation.Required, validation.Min(1)),
+	)
+}
+
+// ReviewRequest is ...
+type ReviewRequest struct {
+	Active bool `json:"active"`
+	// days after the payment the buyer is asked for a review
+	Delay int `json:"delay"`
+}
+
+// Validate is ...
+func (v ReviewRequest) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Delay, validation.Required, validation.Min(1), validation.Max(60)),
+	)
+}
+
+// Delivery is ...
+type Delivery struct {
+	Attachments       bool `json:"attachments"`
+	AttachmentMaxSize int  `json:"attachment_max_size"`
+	LinkTTL           int  `json:"link_ttl"`
+	ReservationTTL    int  `json:"reservation_ttl"`
+	NoticeBatch       int  `json:"notice_batch"`
+}
+
+// Validate is ...
+func (v Delivery) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.AttachmentMaxSize, validation.Min(1), validation.Max(25)),
+		validation.Field(&v.LinkTTL, validation.Required, validation.Min(1)),
+		validation.Field(&v.ReservationTTL, validation.Required, validation.Min(1)),
+		validation.Field(&v.NoticeBatch, validation.Required, validation.Min(1), validation.Max(1000)),
+	)
+}
+
+// Maintenance is ...
+type Maintenance struct {
+	CartRetention int `json:"cart_retention"`
+	// hours an unreferenced file is kept, so uploads still being saved are not collected
+	GCGrace int `json:"gc_grace"`
+}
+
+// Validate is ...
+func (v Maintenance) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.CartRetention, validation.Required, validation.Min(1)),
+		validation.Field(&v.GCGrace, validation.Required, validation.Min(1)),
+	)
+}
+
+// Storage is ...
+type Storage struct {
+	Driver    string `json:"driver"`
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	PathStyle bool   `json:"path_style"`
+	Presign   bool   `json:"presign"`
+}
+
+// Validate is ...
+func (v Storage) Validate() error {
+	s3 := v.Driver == "s3"
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.Driver, validation.Required, validation.In("local", "s3")),
+		validation.Field(&v.Endpoint, validation.When(s3, validation.Required, is.URL)),
+		validation.Field(&v.Region, validation.Length(0, 64)),
+		validation.Field(&v.Bucket, validation.When(s3, validation.Required, validation.Length(3, 63))),
+		validation.Field(&v.AccessKey, validation.When(s3, validation.Required, validation.Length(3, 128))),
+		validation.Field(&v.SecretKey, validation.When(s3, validation.Required, validation.Length(3, 128))),
+	)
+}
+
+// Cleanup is ...
+type Cleanup struct {
+	Sessions     int64 `json:"sessions"`
+	ExpiredCarts int64 `json:"expired_carts"`
+	ReleasedKeys int64 `json:"released_keys"`
+}
+
+// Garbage is the report of a garbage collection of stored files.
+type Garbage struct {
+	Orphans []Orphan `json:"orphans"`
+	Size    int64    `json:"size"`
+	Removed int64    `json:"removed"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// Orphan is a stored file that no image or digital file refers to.
+type Orphan struct {
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"`
+}
+
 type Social struct {
 	Facebook  string `json:"facebook,omitempty"`
 	Instagram string `json:"instagram,omitempty"`
@@ -221,10 +331,17 @@ func (v SMTP) Validate() error {
 
 // MessageMail ...
 type MessageMail struct {
-	To     string            `json:"to"`
-	Letter Letter            `json:"letter"`
-	Data   map[string]string `json:"data"`
-	Files  []File            `json:"files,omitempty"`
+	To          string            `json:"to"`
+	Letter      Letter            `json:"letter"`
+	Data        map[string]string `json:"data"`
+	Attachments []Attachment      `json:"-"`
+}
+
+// Attachment is a file attached to a letter.
+type Attachment struct {
+	Name     string
+	MimeType string
+	Data     []byte
 }
 
 // Validate is ...
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// skuRegexp allows the usual stock keeping unit characters.
+var skuRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ProductVariant is an edition of a product sold at its own price. Files and keys
+// assigned to a variant are only delivered to its buyers, the ones without a
+// variant go with every variant of the product.
+type ProductVariant struct {
+	Core
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku"`
+	Amount    int    `json:"amount"`
+	Sort      int    `json:"sort"`
+	Active    bool   `json:"active"`
+}
+
+// Validate is ...
+func (v ProductVariant) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.ID, validation.Length(15, 15)),
+		validation.Field(&v.Name, validation.Required, validation.Length(1, 50)),
+		validation.Field(&v.SKU, validation.Length(1, 64), validation.Match(skuRegexp)),
+		validation.Field(&v.Amount, validation.Min(0)),
+	)
+}
+
+// DigitalVariant moves a file or a key of a product to a variant,
+// an empty variant shares it between all variants.
+type DigitalVariant struct {
+	VariantID string `json:"variant_id"`
+}
+
+// Validate is ...
+func (v DigitalVariant) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.VariantID, validation.Length(15, 15)),
+	)
+}
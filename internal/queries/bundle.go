@@ -0,0 +1,181 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+// bundleFilled is true when every product of a bundle has something left to deliver,
+// the keys are counted within the variant the bundle holds. A deleted product leaves
+// its bundles empty until it is restored.
+const bundleFilled = `(
+	EXISTS(SELECT 1 FROM product_bundle WHERE product_bundle.bundle_id = product.id) AND
+	NOT EXISTS(
+		SELECT 1 FROM product_bundle
+		JOIN product component ON component.id = product_bundle.product_id
+		WHERE product_bundle.bundle_id = product.id AND (component.deleted = 1 OR NOT (
+			component.digital = 'data' AND EXISTS(
+				SELECT 1 FROM digital_data
+				WHERE digital_data.product_id = component.id AND digital_data.content != '' AND ` + freeKey + `
+					AND (digital_data.variant_id IS NULL OR digital_data.variant_id = product_bundle.variant_id)
+			) OR
+			component.digital = 'file' AND EXISTS(SELECT 1 FROM digital_file WHERE digital_file.product_id = component.id AND digital_file.active = 1) OR
+			component.digital = 'api' AND EXISTS(SELECT 1 FROM digital_api WHERE digital_api.product_id = component.id) OR
+			component.digital = 'generated' AND EXISTS(SELECT 1 FROM digital_generator WHERE digital_generator.product_id = component.id)
+		))
+	)
+)`
+
+// productBundle retrieves the products of a bundle in the order they are delivered.
+func (q *ProductQueries) productBundle(ctx context.Context, bundleID string) ([]models.BundleItem, error) {
+	query := `
+			SELECT product.id, COALESCE(product_bundle.variant_id, ''),
+				CASE WHEN product_variant.id IS NULL THEN product.name ELSE product.name || ' - ' || product_variant.name END
+			FROM product_bundle
+			JOIN product ON product.id = product_bundle.product_id
+			LEFT JOIN product_variant ON product_variant.id = product_bundle.variant_id
+			WHERE product_bundle.bundle_id = ?
+			ORDER BY product_bundle.sort
+	`
+	rows, err := q.DB.QueryContext(ctx, query, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.BundleItem{}
+	for rows.Next() {
+		item := models.BundleItem{}
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// UpdateDigitalBundle replaces the products of a "bundle" product. A bundle holds other
+// existing products once each and bundles can not be nested, errors.ErrBundleComponent
+// is returned otherwise. Products sold in variants need one of their variants.
+func (q *ProductQueries) UpdateDigitalBundle(ctx context.Context, bundleID string, items []models.BundleItem) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var digitalType sql.NullString
+	if err := tx.QueryRowContext(ctx, `SELECT digital FROM product WHERE id = ?`, bundleID).Scan(&digitalType); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrProductNotFound
+		}
+		return err
+	}
+	if digitalType.String != "bundle" {
+		return errors.ErrProductNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_bundle WHERE bundle_id = ?`, bundleID); err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for i, item := range items {
+		var componentType sql.NullString
+		var variants bool
+		query := `SELECT digital, EXISTS(SELECT 1 FROM product_variant WHERE product_id = product.id AND active = 1) FROM product WHERE id = ? AND deleted = 0`
+		if err := tx.QueryRowContext(ctx, query, item.ProductID).Scan(&componentType, &variants); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.ErrBundleComponent
+			}
+			return err
+		}
+		if item.ProductID == bundleID || listed[item.ProductID] || !componentType.Valid || componentType.String == "bundle" {
+			return errors.ErrBundleComponent
+		}
+		listed[item.ProductID] = true
+
+		if variants && item.VariantID == "" {
+			return errors.ErrVariantRequired
+		}
+		if err := hasVariant(ctx, tx, item.ProductID, item.VariantID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO product_bundle (bundle_id, product_id, variant_id, sort) VALUES (?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, bundleID, item.ProductID, nullString(item.VariantID), i); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CartBundles sets the products every bundle of a cart holds right now, so that a
+// purchase keeps what was bought when the bundle changes later. Bundles sent by a
+// buyer for other products are dropped.
+func (q *ProductQueries) CartBundles(ctx context.Context, products []models.CartProduct) error {
+	query := `
+			SELECT product_bundle.product_id, COALESCE(product_bundle.variant_id, '')
+			FROM product_bundle
+			JOIN product ON product.id = product_bundle.bundle_id AND product.digital = 'bundle'
+			WHERE product_bundle.bundle_id = ?
+			ORDER BY product_bundle.sort
+	`
+	for i := range products {
+		products[i].Bundle = nil
+
+		rows, err := q.DB.QueryContext(ctx, query, products[i].ProductID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			component := models.CartProduct{Quantity: 1}
+			if err := rows.Scan(&component.ProductID, &component.VariantID); err != nil {
+				rows.Close()
+				return err
+			}
+			products[i].Bundle = append(products[i].Bundle, component)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+	}
+
+	return nil
+}
+
+// deliveryLines returns the products of a cart that are delivered: bundles are replaced
+// by their products, each of them once per bundle bought, and a product that is both
+// bought alone and in a bundle, or in several bundles, is merged into one line.
+func deliveryLines(products []models.CartProduct) []models.CartProduct {
+	lines := []models.CartProduct{}
+	index := map[[2]string]int{}
+	add := func(product models.CartProduct, quantity int) {
+		key := [2]string{product.ProductID, product.VariantID}
+		if i, ok := index[key]; ok {
+			lines[i].Quantity += quantity
+			return
+		}
+		index[key] = len(lines)
+		lines = append(lines, models.CartProduct{ProductID: product.ProductID, VariantID: product.VariantID, Quantity: quantity})
+	}
+
+	for _, product := range products {
+		if len(product.Bundle) == 0 {
+			add(product, keyQuantity(product))
+			continue
+		}
+		for _, component := range product.Bundle {
+			add(component, keyQuantity(component)*keyQuantity(product))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,91 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+func TestProductBundles(t *testing.T) {
+	newTestProducts(t, 4)
+	ctx := context.Background()
+	const keyID, apiID, bundleID = "p00000000000001", "p00000000000002", "p00000000000003"
+
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'data' WHERE id = ?`, keyID)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'bundle' WHERE id = ?`, bundleID)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `DELETE FROM digital_api WHERE product_id = ?`, bundleID)
+	require.NoError(t, err)
+	_, err = db.ImportDigitalData(ctx, keyID, "", []string{"KEY-1"})
+	require.NoError(t, err)
+
+	t.Run("components", func(t *testing.T) {
+		assert.False(t, db.IsProduct(ctx, "product-3"))
+
+		assert.Equal(t, errors.ErrBundleComponent, db.UpdateDigitalBundle(ctx, bundleID, []models.BundleItem{{ProductID: bundleID}}))
+		assert.Equal(t, errors.ErrBundleComponent, db.UpdateDigitalBundle(ctx, bundleID, []models.BundleItem{{ProductID: "p00000000000099"}}))
+		assert.Equal(t, errors.ErrBundleComponent, db.UpdateDigitalBundle(ctx, bundleID, []models.BundleItem{{ProductID: keyID}, {ProductID: keyID}}))
+		assert.Equal(t, errors.ErrProductNotFound, db.UpdateDigitalBundle(ctx, keyID, []models.BundleItem{{ProductID: apiID}}))
+
+		require.NoError(t, db.UpdateDigitalBundle(ctx, bundleID, []models.BundleItem{{ProductID: keyID}, {ProductID: apiID}}))
+
+		digital, err := db.ProductDigital(ctx, bundleID)
+		require.NoError(t, err)
+		require.Len(t, digital.Bundle, 2)
+		assert.Equal(t, "Product 1", digital.Bundle[0].Name)
+		assert.Equal(t, apiID, digital.Bundle[1].ProductID)
+
+		assert.True(t, db.IsProduct(ctx, "product-3"))
+	})
+
+	t.Run("stock", func(t *testing.T) {
+		products := []models.CartProduct{{ProductID: bundleID, Quantity: 1}}
+		require.NoError(t, db.CartBundles(ctx, products))
+		require.Len(t, products[0].Bundle, 2)
+		assert.NoError(t, db.CheckStock(ctx, products))
+
+		products[0].Quantity = 2
+		assert.Equal(t, errors.ErrOutOfStock, db.CheckStock(ctx, products))
+
+		products = append(products[:1:1], models.CartProduct{ProductID: keyID, Quantity: 1})
+		products[0].Quantity = 1
+		assert.Equal(t, errors.ErrOutOfStock, db.CheckStock(ctx, products))
+	})
+
+	t.Run("delivery lines", func(t *testing.T) {
+		lines := deliveryLines([]models.CartProduct{
+			{ProductID: bundleID, Quantity: 2, Bundle: []models.CartProduct{{ProductID: keyID, Quantity: 1}, {ProductID: apiID, Quantity: 1}}},
+			{ProductID: keyID, Quantity: 1},
+		})
+		require.Len(t, lines, 2)
+		assert.Equal(t, models.CartProduct{ProductID: keyID, Quantity: 3}, lines[0])
+		assert.Equal(t, models.CartProduct{ProductID: apiID, Quantity: 2}, lines[1])
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		products := []models.CartProduct{{ProductID: bundleID, Quantity: 1}}
+		require.NoError(t, db.CartBundles(ctx, products))
+
+		tx, err := db.ProductQueries.DB.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		require.NoError(t, reserveKeys(ctx, tx, "c00000000000001", products, 30))
+		assert.Equal(t, errors.ErrOutOfStock, reserveKeys(ctx, tx, "c00000000000002", products, 30))
+		_, err = tx.ExecContext(ctx, `UPDATE digital_data SET reserved_until = NULL WHERE cart_id = ?`, "c00000000000001")
+		require.NoError(t, err)
+
+		keys, err := soldKeys(ctx, tx, "c00000000000001", models.CartProduct{ProductID: keyID})
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "KEY-1", keys[0].Content)
+		require.NoError(t, tx.Commit())
+
+		assert.False(t, db.IsProduct(ctx, "product-3"))
+	})
+}
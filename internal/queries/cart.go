@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/security"
+	"github.com/shurco/litecart/pkg/storage"
 )
 
 // CartQueries is a struct that embeds a pointer to an sql.DB.
@@ -19,6 +22,16 @@ type CartQueries struct {
 	*sql.DB
 }
 
+// recoveryWindow is the number of hours after the recovery delay during
+// which an abandoned cart is still worth a reminder.
+const recoveryWindow = 7 * 24
+
+// execer is implemented by both *sql.DB and *sql.Tx, so audit entries
+// can be written inside or outside of a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // PaymentList retrieves the status of different payment methods from the database.
 func (q *CartQueries) PaymentList(ctx context.Context) (map[string]bool, error) {
 	payments := map[string]bool{}
@@ -63,6 +76,7 @@ func (q *CartQueries) Carts(ctx context.Context) ([]*models.Cart, error) {
 	SELECT 
 		id, 
 		email, 
+		customer_id,
 		amount_total,
 		currency,
 		payment_id,
@@ -80,13 +94,14 @@ func (q *CartQueries) Carts(ctx context.Context) ([]*models.Cart, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var email, paymentID sql.NullString
+		var email, customerID, paymentID sql.NullString
 		var updated sql.NullInt64
 		cart := &models.Cart{}
 
 		err := rows.Scan(
 			&cart.ID,
 			&email,
+			&customerID,
 			&cart.AmountTotal,
 			&cart.Currency,
 			&paymentID,
@@ -100,6 +115,7 @@ func (q *CartQueries) Carts(ctx context.Context) ([]*models.Cart, error) {
 		}
 
 		cart.Email = email.String
+		cart.CustomerID = customerID.String
 		cart.PaymentID = paymentID.String
 		if updated.Valid {
 			cart.Updated = updated.Int64
@@ -121,40 +137,59 @@ func (q *CartQueries) Cart(ctx context.Context, cartId string) (*models.Cart, er
 	SELECT 
     id, 
     email, 
+    customer_id,
+    cart,
+    billing,
     amount_total,
     currency,
     payment_id,
     payment_status,
+    payment_system,
     strftime('%s', created),
     strftime('%s', updated)
 	FROM cart
 	WHERE id = ?
 	`
 
-	var email, paymentID sql.NullString
+	var email, customerID, paymentID, paymentSystem sql.NullString
 	var created, updated sql.NullInt64
+	var cartJSON, billingJSON string
 	cart := &models.Cart{}
 
 	err := q.DB.QueryRowContext(ctx, query, cartId).
 		Scan(
 			&cart.ID,
 			&email,
+			&customerID,
+			&cartJSON,
+			&billingJSON,
 			&cart.AmountTotal,
 			&cart.Currency,
 			&paymentID,
 			&cart.PaymentStatus,
+			&paymentSystem,
 			&created,
 			&updated,
 		)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.ErrProductNotFound
+			return nil, errors.ErrCartNotFound
 		}
 		return nil, err
 	}
 
+	if err := json.Unmarshal([]byte(cartJSON), &cart.Cart); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(billingJSON), &cart.Billing); err != nil {
+		return nil, err
+	}
+
 	cart.Email = email.String
+	cart.CustomerID = customerID.String
 	cart.PaymentID = paymentID.String
+	cart.PaymentSystem = litepay.PaymentSystem(paymentSystem.String)
 	if created.Valid {
 		cart.Created = created.Int64
 	}
@@ -166,20 +201,76 @@ func (q *CartQueries) Cart(ctx context.Context, cartId string) (*models.Cart, er
 	return cart, nil
 }
 
-// AddCart inserts a new cart into the database.
+// AddCart inserts a new cart into the database and reserves the keys of its products.
+// It returns errors.ErrOutOfStock when the keys ran out in the meantime.
 func (q *CartQueries) AddCart(ctx context.Context, cart *models.Cart) error {
 	byteCart, err := json.Marshal(cart.Cart)
 	if err != nil {
 		return err
 	}
 
-	query := `INSERT INTO cart (id, email, cart, amount_total, currency, payment_status, payment_system) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err = q.DB.ExecContext(ctx, query, cart.ID, cart.Email, string(byteCart), cart.AmountTotal, cart.Currency, cart.PaymentStatus, cart.PaymentSystem)
-	return err
+	delivery, err := GetSettingByGroup[models.Delivery](ctx, db)
+	if err != nil {
+		return err
+	}
+
+	byteBilling, err := json.Marshal(cart.Billing)
+	if err != nil {
+		return err
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO cart (id, email, customer_id, cart, billing, amount_total, currency, payment_status, payment_system) VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, cart.ID, cart.Email, cart.CustomerID, string(byteCart), string(byteBilling), cart.AmountTotal, cart.Currency, cart.PaymentStatus, cart.PaymentSystem)
+	if err != nil {
+		return err
+	}
+
+	if err := reserveKeys(ctx, tx, cart.ID, cart.Cart, delivery.ReservationTTL); err != nil {
+		return err
+	}
+
+	err = addCartEvent(ctx, tx, &models.CartEvent{
+		CartID: cart.ID,
+		Event:  models.CartEventCreated,
+		Actor:  models.CartActorCheckout,
+		Payload: map[string]any{
+			"status":         cart.PaymentStatus,
+			"payment_system": cart.PaymentSystem,
+			"amount_total":   cart.AmountTotal,
+			"currency":       cart.Currency,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// UpdateCart updates the cart details in the database.
-func (q *CartQueries) UpdateCart(ctx context.Context, cart *models.Cart) error {
+// UpdateCart updates the cart details in the database and records
+// the status change in the audit trail on behalf of the given actor.
+func (q *CartQueries) UpdateCart(ctx context.Context, cart *models.Cart, actor models.CartActor) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var prevStatus string
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(payment_status, '') FROM cart WHERE id = ?`, cart.ID).Scan(&prevStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
 	var (
 		args []interface{}
 		sql  strings.Builder
@@ -200,10 +291,121 @@ func (q *CartQueries) UpdateCart(ctx context.Context, cart *models.Cart) error {
 	sql.WriteString("updated = datetime('now') WHERE id = ?")
 	args = append(args, cart.ID)
 
-	_, err := q.DB.ExecContext(ctx, sql.String(), args...)
+	if _, err := tx.ExecContext(ctx, sql.String(), args...); err != nil {
+		return err
+	}
+
+	if cart.PaymentStatus != "" && cart.PaymentStatus != litepay.Status(prevStatus) {
+		event := models.CartEventStatusChanged
+		if cart.PaymentStatus == litepay.REFUNDED {
+			event = models.CartEventRefund
+		}
+
+		payload := map[string]any{
+			"from": prevStatus,
+			"to":   cart.PaymentStatus,
+		}
+		if cart.PaymentID != "" {
+			payload["payment_id"] = cart.PaymentID
+		}
+		if cart.PaymentSystem != "" {
+			payload["payment_system"] = cart.PaymentSystem
+		}
+
+		err := addCartEvent(ctx, tx, &models.CartEvent{
+			CartID:  cart.ID,
+			Event:   event,
+			Actor:   actor,
+			Payload: payload,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch cart.PaymentStatus {
+		case litepay.PAID:
+			if err := confirmKeys(ctx, tx, cart.ID); err != nil {
+				return err
+			}
+			if err := generateKeys(ctx, tx, cart.ID); err != nil {
+				return err
+			}
+			if err := addCartDeliveries(ctx, tx, cart.ID); err != nil {
+				return err
+			}
+			// the invoice number is assigned in the same transaction,
+			// so a rolled back payment never leaves a gap in the sequence
+			if err := addInvoice(ctx, tx, cart.ID); err != nil {
+				return err
+			}
+		case litepay.CANCELED, litepay.FAILED, litepay.EXPIRED:
+			if _, err := releaseKeys(ctx, tx, cart.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AddCartEvent records an entry in the audit trail of a cart.
+func (q *CartQueries) AddCartEvent(ctx context.Context, event *models.CartEvent) error {
+	return addCartEvent(ctx, q.DB, event)
+}
+
+func addCartEvent(ctx context.Context, exec execer, event *models.CartEvent) error {
+	payload := []byte("{}")
+	if len(event.Payload) > 0 {
+		var err error
+		if payload, err = json.Marshal(event.Payload); err != nil {
+			return err
+		}
+	}
+
+	event.ID = security.RandomString()
+	query := `INSERT INTO cart_event (id, cart_id, event, actor, payload) VALUES (?, ?, ?, ?, ?)`
+	_, err := exec.ExecContext(ctx, query, event.ID, event.CartID, event.Event, event.Actor, string(payload))
 	return err
 }
 
+// CartEvents retrieves the audit trail of a cart in chronological order.
+func (q *CartQueries) CartEvents(ctx context.Context, cartID string) ([]models.CartEvent, error) {
+	events := []models.CartEvent{}
+
+	query := `
+	SELECT id, cart_id, event, actor, payload, strftime('%s', created)
+	FROM cart_event
+	WHERE cart_id = ?
+	ORDER BY created, rowid
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payload string
+		event := models.CartEvent{}
+		if err := rows.Scan(&event.ID, &event.CartID, &event.Event, &event.Actor, &payload, &event.Created); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(payload), &event.Payload); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 // CartLetterPayment is ...
 func (q *CartQueries) CartLetterPayment(ctx context.Context, email, amountPayment, paymentURL string) (*models.MessageMail, error) {
 	mailLetter, err := db.GetSettingByKey(ctx, "site_name", "mail_letter_payment")
@@ -228,6 +430,103 @@ func (q *CartQueries) CartLetterPayment(ctx context.Context, email, amountPaymen
 	return mail, nil
 }
 
+// RecoveryCarts retrieves the unpaid carts that are older than delay hours and
+// whose buyer has neither unsubscribed nor received a recovery letter in the
+// last frequency hours. Only the latest cart of every customer is returned.
+func (q *CartQueries) RecoveryCarts(ctx context.Context, delay, frequency int) ([]models.Cart, error) {
+	carts := []models.Cart{}
+
+	query := `
+	SELECT cart.id, cart.email, cart.customer_id, cart.cart, cart.amount_total, cart.currency, cart.payment_system
+	FROM cart
+	JOIN customer ON customer.id = cart.customer_id
+	WHERE cart.payment_status IN (?, ?)
+		AND cart.created <= datetime('now', ?)
+		AND cart.created > datetime('now', ?)
+		AND customer.unsubscribed = 0
+		AND NOT EXISTS (
+			SELECT 1 FROM cart AS paid
+			WHERE paid.customer_id = cart.customer_id AND paid.payment_status = ? AND paid.created > cart.created
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM cart_event
+			JOIN cart AS sent ON sent.id = cart_event.cart_id
+			WHERE sent.customer_id = cart.customer_id
+				AND cart_event.event = ?
+				AND json_extract(cart_event.payload, '$.letter') = 'mail_letter_recovery'
+				AND (sent.id = cart.id OR cart_event.created > datetime('now', ?))
+		)
+	ORDER BY cart.created DESC
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query,
+		litepay.NEW, litepay.CANCELED,
+		fmt.Sprintf("-%d hours", delay),
+		fmt.Sprintf("-%d hours", delay+recoveryWindow),
+		litepay.PAID,
+		models.CartEventEmailSent,
+		fmt.Sprintf("-%d hours", frequency),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := map[string]bool{}
+	for rows.Next() {
+		var cartJSON string
+		var paymentSystem sql.NullString
+		cart := models.Cart{}
+		err := rows.Scan(&cart.ID, &cart.Email, &cart.CustomerID, &cartJSON, &cart.AmountTotal, &cart.Currency, &paymentSystem)
+		if err != nil {
+			return nil, err
+		}
+
+		if customers[cart.CustomerID] {
+			continue
+		}
+		customers[cart.CustomerID] = true
+
+		if err := json.Unmarshal([]byte(cartJSON), &cart.Cart); err != nil {
+			return nil, err
+		}
+		cart.PaymentSystem = litepay.PaymentSystem(paymentSystem.String)
+
+		carts = append(carts, cart)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return carts, nil
+}
+
+// CartLetterRecovery is ...
+func (q *CartQueries) CartLetterRecovery(ctx context.Context, email, amountPayment, recoveryURL, unsubscribeURL string) (*models.MessageMail, error) {
+	mailLetter, err := db.GetSettingByKey(ctx, "site_name", "mail_letter_recovery")
+	if err != nil {
+		return nil, err
+	}
+	letterTemplate := models.Letter{}
+	if err := json.Unmarshal([]byte(mailLetter["mail_letter_recovery"].Value.(string)), &letterTemplate); err != nil {
+		return nil, err
+	}
+
+	mail := &models.MessageMail{
+		To:     email,
+		Letter: letterTemplate,
+		Data: map[string]string{
+			"Payment_URL":     recoveryURL,
+			"Unsubscribe_URL": unsubscribeURL,
+			"Site_Name":       mailLetter["site_name"].Value.(string),
+			"Amount_Payment":  amountPayment,
+		},
+	}
+
+	return mail, nil
+}
+
 // CartLetterPurchase is ...
 func (q *CartQueries) CartLetterPurchase(ctx context.Context, cartID string) (*models.MessageMail, error) {
 	mail := &models.MessageMail{}
@@ -260,10 +559,17 @@ func (q *CartQueries) CartLetterPurchase(ctx context.Context, cartID string) (*m
 	defer tx.Rollback()
 
 	keys := []models.Data{}
+	listedKeys := map[string]bool{}
 	files := []models.File{}
-	for _, cart := range products {
+	fileTTL := map[string]int{}
+	// watermarked files are stamped on download, so they are never attached
+	watermarked := map[string]bool{}
+	deliveries := []models.CartDelivery{}
+	for _, cart := range deliveryLines(products) {
 		var digitalType string
-		err := tx.QueryRowContext(ctx, `SELECT digital FROM product WHERE id = ?`, cart.ProductID).Scan(&digitalType)
+		var downloadTTL int
+		var watermark bool
+		err := tx.QueryRowContext(ctx, `SELECT digital, download_ttl, watermark FROM product WHERE id = ?`, cart.ProductID).Scan(&digitalType, &downloadTTL, &watermark)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, errors.ErrPageNotFound
@@ -273,39 +579,67 @@ func (q *CartQueries) CartLetterPurchase(ctx context.Context, cartID string) (*m
 
 		switch digitalType {
 		case "file":
-			rows, err := tx.QueryContext(ctx, `SELECT id, name, ext, orig_name FROM digital_file WHERE product_id = ?`, cart.ProductID)
+			query := `SELECT id, name, ext, orig_name, version FROM digital_file WHERE product_id = ? AND active = 1 AND ` + variantScope("digital_file")
+			rows, err := tx.QueryContext(ctx, query, cart.ProductID, cart.VariantID)
 			if err != nil {
 				return nil, err
 			}
 			for rows.Next() {
 				file := models.File{}
-				if err := rows.Scan(&file.ID, &file.Name, &file.Ext, &file.OrigName); err != nil {
+				if err := rows.Scan(&file.ID, &file.Name, &file.Ext, &file.OrigName, &file.Version); err != nil {
 					rows.Close()
 					return nil, err
 				}
+				// shared files are listed once for several variants of a product
+				if _, ok := fileTTL[file.ID]; ok {
+					continue
+				}
 				files = append(files, file)
+				fileTTL[file.ID] = downloadTTL
+				watermarked[file.ID] = watermark
 			}
 			rows.Close()
-		case "data":
-			key := models.Data{}
-			err := tx.QueryRowContext(ctx, `SELECT id, content FROM digital_data WHERE cart_id = ?`, cartID).Scan(&key.ID, &key.Content)
+		case "data", "generated":
+			// keys are reserved at checkout and confirmed or generated on payment,
+			// carts created before that get them here
+			productKeys, err := soldKeys(ctx, tx, cartID, cart)
 			if err != nil {
-				if err == sql.ErrNoRows {
-					err = tx.QueryRowContext(ctx, `SELECT id, content FROM digital_data WHERE cart_id IS NULL AND product_id = ? LIMIT 1`, cart.ProductID).Scan(&key.ID, &key.Content)
-					if err != nil {
-						if err == sql.ErrNoRows {
-							return nil, errors.ErrPageNotFound
-						}
-						return nil, err
-					}
-					if _, err := tx.ExecContext(ctx, `UPDATE digital_data SET cart_id = ? WHERE id = ?`, cartID, key.ID); err != nil {
-						return nil, err
-					}
+				return nil, err
+			}
+			if len(productKeys) == 0 {
+				if digitalType == "generated" {
+					err = generateKeys(ctx, tx, cartID)
 				} else {
+					_, err = claimKeys(ctx, tx, cartID, cart, keyQuantity(cart), "")
+				}
+				if err != nil {
+					return nil, err
+				}
+				if productKeys, err = soldKeys(ctx, tx, cartID, cart); err != nil {
 					return nil, err
 				}
 			}
-			keys = append(keys, key)
+			if len(productKeys) == 0 {
+				return nil, errors.ErrPageNotFound
+			}
+			for _, key := range productKeys {
+				if !listedKeys[key.ID] {
+					listedKeys[key.ID] = true
+					keys = append(keys, key)
+				}
+			}
+		case "api":
+			delivery := models.CartDelivery{ProductID: cart.ProductID}
+			query := `
+			SELECT product.name, COALESCE(cart_delivery.status, ''), COALESCE(cart_delivery.content, '')
+			FROM product
+			LEFT JOIN cart_delivery ON cart_delivery.product_id = product.id AND cart_delivery.cart_id = ?
+			WHERE product.id = ?
+			`
+			if err := tx.QueryRowContext(ctx, query, cartID, cart.ProductID).Scan(&delivery.ProductName, &delivery.Status, &delivery.Content); err != nil {
+				return nil, err
+			}
+			deliveries = append(deliveries, delivery)
 		}
 	}
 
@@ -313,6 +647,20 @@ func (q *CartQueries) CartLetterPurchase(ctx context.Context, cartID string) (*m
 		return nil, err
 	}
 
+	// Fetch the 'mail_letter_purchase' setting value.
+	mailLetter, err := db.GetSettingByKey(ctx, "email", "mail_letter_purchase", "domain", "jwt_secret")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(mailLetter["mail_letter_purchase"].Value.(string)), &mail.Letter); err != nil {
+		return nil, err
+	}
+
+	delivery, err := GetSettingByGroup[models.Delivery](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
 	// Construct the purchases information.
 	var purchases strings.Builder
 	count := 1
@@ -325,26 +673,122 @@ func (q *CartQueries) CartLetterPurchase(ctx context.Context, cartID string) (*m
 	}
 	if len(files) > 0 {
 		purchases.WriteString("Files:\n")
+		domain := mailLetter["domain"].Value.(string)
+		secret := mailLetter["jwt_secret"].Value.(string)
+		maxSize := int64(delivery.AttachmentMaxSize) << 20
+
 		for _, file := range files {
-			purchases.WriteString(fmt.Sprintf("%v: %s\n", count, file.OrigName))
+			name := file.OrigName
+			if file.Version != "" {
+				name = fmt.Sprintf("%s (%s)", file.OrigName, file.Version)
+			}
+
+			var attachment *models.Attachment
+			if delivery.Attachments && !watermarked[file.ID] {
+				attachment = digitalAttachment(ctx, file, maxSize)
+			}
+			if attachment != nil {
+				purchases.WriteString(fmt.Sprintf("%v: %s (attached)\n", count, name))
+				mail.Attachments = append(mail.Attachments, *attachment)
+			} else {
+				purchases.WriteString(fmt.Sprintf("%v: %s - %s\n", count, name, DownloadURL(domain, secret, cartID, file.ID, downloadExpires(fileTTL[file.ID], delivery.LinkTTL))))
+			}
 			count++
 		}
 	}
 
-	// Fetch the 'mail_letter_purchase' setting value.
-	mailLetter, err := db.GetSettingByKey(ctx, "email", "mail_letter_purchase")
-	if err != nil {
-		return nil, err
-	}
-	if err := json.Unmarshal([]byte(mailLetter["mail_letter_purchase"].Value.(string)), &mail.Letter); err != nil {
-		return nil, err
+	if len(deliveries) > 0 {
+		purchases.WriteString("Services:\n")
+		for _, delivery := range deliveries {
+			content := delivery.Content
+			if delivery.Status != models.CartDeliveryDelivered {
+				content = "is being prepared, you will receive it in a separate letter"
+			}
+			purchases.WriteString(fmt.Sprintf("%v: %s - %s\n", count, delivery.ProductName, content))
+			count++
+		}
 	}
 
 	mail.Data = map[string]string{
 		"Purchases":   purchases.String(),
 		"Admin_Email": mailLetter["email"].Value.(string),
 	}
-	mail.Files = files
 
 	return mail, nil
 }
+
+// CartFile retrieves a digital file that belongs to a product of a paid cart,
+// along with the download limits and the watermark setting of that product. Links to an older version
+// of a file resolve to the active version of its lineage.
+func (q *CartQueries) CartFile(ctx context.Context, cartID, fileID string) (*models.DownloadFile, error) {
+	query := `
+	SELECT digital_file.id, digital_file.name, digital_file.ext, digital_file.orig_name,
+		product.id, product.download_limit, product.download_ip_limit, product.watermark, COALESCE(cart.email, '')
+	FROM digital_file requested
+	JOIN digital_file ON digital_file.lineage_id = requested.lineage_id AND digital_file.active = 1
+	JOIN product ON product.id = digital_file.product_id
+	JOIN cart ON cart.id = ?
+	WHERE requested.id = ?
+		AND cart.payment_status = ?
+		AND EXISTS (
+			SELECT 1 FROM json_tree(cart.cart)
+			WHERE json_tree.key = 'id' AND json_tree.value = digital_file.product_id
+				AND (digital_file.variant_id IS NULL OR digital_file.variant_id = json_extract(cart.cart, json_tree.path || '.variant_id'))
+		)
+	ORDER BY digital_file.created DESC, digital_file.rowid DESC
+	LIMIT 1
+	`
+
+	file := &models.DownloadFile{}
+	err := q.DB.QueryRowContext(ctx, query, cartID, fileID, litepay.PAID).
+		Scan(&file.ID, &file.Name, &file.Ext, &file.OrigName, &file.ProductID, &file.Limit, &file.IPLimit, &file.Watermark, &file.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// downloadExpires returns the expiry time of a download link, the lifetime of the
+// product takes precedence over the global one when it is set.
+func downloadExpires(productTTL, linkTTL int) time.Time {
+	if productTTL > 0 {
+		linkTTL = productTTL
+	}
+	return time.Now().Add(time.Duration(linkTTL) * time.Hour)
+}
+
+// DownloadURL returns a signed link to a digital file of a cart that stops working after expires.
+func DownloadURL(domain, secret, cartID, fileID string, expires time.Time) string {
+	token := security.Sign(secret, cartID+":"+fileID, expires)
+	return fmt.Sprintf("https://%s/download/%s", domain, token)
+}
+
+// digitalAttachment reads a digital file to attach it to a letter. Nil is returned
+// when the file is larger than maxSize or can not be read, the buyer gets a link then.
+func digitalAttachment(ctx context.Context, file models.File, maxSize int64) *models.Attachment {
+	fs, err := Storage(ctx)
+	if err != nil {
+		return nil
+	}
+
+	key := DigitalKey(file.Name, file.Ext)
+	object, err := fs.Stat(ctx, key)
+	if err != nil || object.Size > maxSize {
+		return nil
+	}
+
+	data, err := storage.ReadAll(ctx, fs, key)
+	if err != nil {
+		return nil
+	}
+
+	return &models.Attachment{
+		Name:     file.OrigName,
+		MimeType: storage.ContentType(key),
+		Data:     data,
+	}
+}
@@ -0,0 +1,577 @@
+package queries
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/archive"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/fsutil"
+	"github.com/shurco/litecart/pkg/security"
+	"github.com/shurco/litecart/pkg/storage"
+)
+
+// a catalog archive holds the products as products.json or products.csv,
+// and their images and files in these directories next to it
+const (
+	catalogName   = "products"
+	catalogImages = "images"
+	catalogFiles  = "files"
+)
+
+// catalogColumns are the columns of a csv catalog, the lists and the seo are json encoded.
+var catalogColumns = []string{"name", "slug", "brief", "description", "amount", "digital", "metadata", "attributes", "seo", "images", "files"}
+
+// CatalogProducts retrieves the products that are moved to another shop by an export,
+// with their images and the active versions of their digital files.
+func (q *ProductQueries) CatalogProducts(ctx context.Context) ([]models.CatalogProduct, error) {
+	query := `
+		SELECT id, name, slug, brief, desc, amount, COALESCE(digital, ''), metadata, attribute, seo
+		FROM product
+		WHERE deleted = 0
+		ORDER BY created, rowid
+	`
+	rows, err := q.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	products := []models.CatalogProduct{}
+	for rows.Next() {
+		var id string
+		var metadata, attributes, seo sql.NullString
+		product := models.CatalogProduct{}
+		err := rows.Scan(&id, &product.Name, &product.Slug, &product.Brief, &product.Description, &product.Amount, &product.Digital, &metadata, &attributes, &seo)
+		if err != nil {
+			return nil, err
+		}
+		if metadata.Valid {
+			json.Unmarshal([]byte(metadata.String), &product.Metadata)
+		}
+		if attributes.Valid {
+			json.Unmarshal([]byte(attributes.String), &product.Attributes)
+		}
+		if seo.Valid {
+			product.Seo = &models.Seo{}
+			json.Unmarshal([]byte(seo.String), product.Seo)
+		}
+		ids = append(ids, id)
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if products[i].Images, err = catalogFileList(ctx, q.DB, `SELECT name, ext, orig_name FROM product_image WHERE product_id = ? ORDER BY rowid`, id); err != nil {
+			return nil, err
+		}
+		if products[i].Files, err = catalogFileList(ctx, q.DB, `SELECT name, ext, orig_name FROM digital_file WHERE product_id = ? AND active = 1 ORDER BY created, rowid`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return products, nil
+}
+
+// catalogFileList returns the stored files a query selects by their name, extension and original name.
+func catalogFileList(ctx context.Context, db *sql.DB, query, productID string) ([]models.CatalogFile, error) {
+	rows, err := db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []models.CatalogFile{}
+	for rows.Next() {
+		file := models.CatalogFile{}
+		if err := rows.Scan(&file.Name, &file.Ext, &file.OrigName); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// ExportCatalog writes a zip archive with all products to w, listed in a "json" or
+// "csv" file. The images are exported with their resized copies. w is closed when
+// the export ends, also when it fails.
+func (q *ProductQueries) ExportCatalog(ctx context.Context, w io.WriteCloser, format string) error {
+	ar := archive.NewZipArchive(w)
+	err := q.exportCatalog(ctx, ar, format)
+	if err == nil {
+		err = ar.Close()
+	}
+	if err != nil {
+		w.Close()
+	}
+	return err
+}
+
+// exportCatalog adds the products with their stored images and files to the archive.
+func (q *ProductQueries) exportCatalog(ctx context.Context, ar archive.Archive, format string) error {
+	products, err := q.CatalogProducts(ctx)
+	if err != nil {
+		return err
+	}
+
+	fs, err := Storage(ctx)
+	if err != nil {
+		return err
+	}
+
+	// files missing from the storage are left out, so that the catalog can be imported
+	for i := range products {
+		if products[i].Images, err = storedFiles(ctx, fs, products[i].Images, UploadKey); err != nil {
+			return err
+		}
+		if products[i].Files, err = storedFiles(ctx, fs, products[i].Files, func(name string) string { return digitalPrefix + name }); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := encodeCatalog(products, format)
+	if err != nil {
+		return err
+	}
+
+	return writeCatalog(ctx, ar, fs, products, catalogName+"."+format, manifest)
+}
+
+// writeCatalog adds the list of products to the archive, then their images and files.
+func writeCatalog(ctx context.Context, ar archive.Archive, fs storage.Storage, products []models.CatalogProduct, name string, manifest []byte) error {
+	entry, err := ar.Header(catalogEntry{name: name, size: int64(len(manifest))})
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write(manifest); err != nil {
+		return err
+	}
+
+	// products can share a stored file, it is archived once
+	archived := map[string]bool{}
+
+	if err := ar.Directory(catalogImages); err != nil {
+		return err
+	}
+	for _, product := range products {
+		for _, image := range product.Images {
+			for _, name := range []string{image.Name, image.Name + "_sm", image.Name + "_md"} {
+				key := UploadKey(name + "." + image.Ext)
+				if archived[key] {
+					continue
+				}
+				archived[key] = true
+				if err := archiveObject(ctx, ar, fs, key, name+"."+image.Ext); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := ar.Directory(catalogFiles); err != nil {
+		return err
+	}
+	for _, product := range products {
+		for _, file := range product.Files {
+			key := DigitalKey(file.Name, file.Ext)
+			if archived[key] {
+				continue
+			}
+			archived[key] = true
+			if err := archiveObject(ctx, ar, fs, key, file.Name+"."+file.Ext); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// storedFiles returns the files that are in the storage under the key of their name.
+func storedFiles(ctx context.Context, fs storage.Storage, files []models.CatalogFile, key func(name string) string) ([]models.CatalogFile, error) {
+	stored := []models.CatalogFile{}
+	for _, file := range files {
+		if _, err := fs.Stat(ctx, key(file.Name+"."+file.Ext)); err != nil {
+			if err == storage.ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+		stored = append(stored, file)
+	}
+	return stored, nil
+}
+
+// archiveObject copies a stored object into the archive, a missing object is left out.
+func archiveObject(ctx context.Context, ar archive.Archive, fs storage.Storage, key, name string) error {
+	r, object, err := fs.Get(ctx, key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+
+	entry, err := ar.Header(catalogEntry{name: name, size: object.Size, modified: object.Modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, r)
+	return err
+}
+
+// encodeCatalog writes the products as a json or csv list.
+func encodeCatalog(products []models.CatalogProduct, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(products, "", "  ")
+
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(catalogColumns)
+		for _, product := range products {
+			record := []string{product.Name, product.Slug, product.Brief, product.Description, strconv.Itoa(product.Amount), product.Digital}
+			for _, value := range []any{product.Metadata, product.Attributes, product.Seo, product.Images, product.Files} {
+				cell, err := json.Marshal(value)
+				if err != nil {
+					return nil, err
+				}
+				record = append(record, string(cell))
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+
+	return nil, fmt.Errorf("unknown catalog format %q", format)
+}
+
+// decodeCatalog reads a json or csv list of products. The columns of a csv list are
+// found by its header, missing columns are left empty.
+func decodeCatalog(data []byte, format string) ([]models.CatalogProduct, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	products := []models.CatalogProduct{}
+
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &products); err != nil {
+			return nil, err
+		}
+		return products, nil
+
+	case "csv":
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return products, nil
+		}
+
+		columns := map[string]int{}
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, record := range records[1:] {
+			cell := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+				return ""
+			}
+
+			product := models.CatalogProduct{
+				Name:        cell("name"),
+				Slug:        cell("slug"),
+				Brief:       cell("brief"),
+				Description: cell("description"),
+				Digital:     cell("digital"),
+			}
+			if amount := cell("amount"); amount != "" {
+				if product.Amount, err = strconv.Atoi(amount); err != nil {
+					return nil, fmt.Errorf("row %d: amount: %v", len(products)+1, err)
+				}
+			}
+			for name, value := range map[string]any{"metadata": &product.Metadata, "attributes": &product.Attributes, "seo": &product.Seo, "images": &product.Images, "files": &product.Files} {
+				if raw := cell(name); raw != "" {
+					if err := json.Unmarshal([]byte(raw), value); err != nil {
+						return nil, fmt.Errorf("row %d: %s: %v", len(products)+1, name, err)
+					}
+				}
+			}
+			products = append(products, product)
+		}
+		return products, nil
+	}
+
+	return nil, fmt.Errorf("unknown catalog format %q", format)
+}
+
+// ImportCatalog adds the products of a catalog exported by ExportCatalog. The source is
+// the zip archive, or a json or csv list of products without images and files.
+// Products whose slug is taken are updated with upsert and skipped otherwise, the digital
+// type of an existing product is kept and files it already has by name are not added again.
+// With dryRun the catalog is only checked. errors.ErrCatalogInvalid is returned
+// when the source can not be read as a catalog.
+func (q *ProductQueries) ImportCatalog(ctx context.Context, src string, upsert, dryRun bool) (*models.CatalogImport, error) {
+	report := &models.CatalogImport{DryRun: dryRun, Skipped: []int{}, Invalid: []models.CatalogError{}}
+
+	dir := filepath.Dir(src)
+	format := strings.ToLower(fsutil.ExtName(src))
+	manifest := src
+	if format == "zip" {
+		tmp, err := os.MkdirTemp("", "litecart-catalog-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+
+		if err := archive.ExtractZip(src, tmp); err != nil {
+			return nil, errors.ErrCatalogInvalid
+		}
+		dir, format = tmp, "json"
+		manifest = filepath.Join(tmp, catalogName+".json")
+		if !fsutil.IsFile(manifest) {
+			format = "csv"
+			manifest = filepath.Join(tmp, catalogName+".csv")
+		}
+	}
+
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.ErrCatalogInvalid
+		}
+		return nil, err
+	}
+	products, err := decodeCatalog(data, format)
+	if err != nil {
+		return nil, errors.ErrCatalogInvalid
+	}
+
+	fs, err := Storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	slugs := map[string]bool{}
+	for i, product := range products {
+		row := i + 1
+		invalid := func(err error) {
+			report.Invalid = append(report.Invalid, models.CatalogError{Row: row, Slug: product.Slug, Error: err.Error()})
+		}
+
+		if err := product.Validate(); err != nil {
+			invalid(err)
+			continue
+		}
+		if slugs[product.Slug] {
+			invalid(fmt.Errorf("slug: is repeated in the catalog."))
+			continue
+		}
+		slugs[product.Slug] = true
+		if err := catalogEntries(dir, product); err != nil {
+			invalid(err)
+			continue
+		}
+
+		var productID, digitalType string
+		err := tx.QueryRowContext(ctx, `SELECT id, COALESCE(digital, '') FROM product WHERE slug = ?`, product.Slug).Scan(&productID, &digitalType)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		exists := err == nil
+		if exists && !upsert {
+			report.Skipped = append(report.Skipped, row)
+			continue
+		}
+		if exists {
+			report.Updated++
+		} else {
+			report.Inserted++
+		}
+		if dryRun {
+			continue
+		}
+
+		if !exists {
+			productID, digitalType = security.RandomString(), product.Digital
+		}
+		if err := importCatalogProduct(ctx, tx, fs, dir, productID, digitalType, exists, product); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// catalogEntries checks that the images and files of a product are in the catalog.
+func catalogEntries(dir string, product models.CatalogProduct) error {
+	for _, list := range []struct {
+		dir   string
+		files []models.CatalogFile
+	}{{catalogImages, product.Images}, {catalogFiles, product.Files}} {
+		for _, file := range list.files {
+			name := file.Name + "." + file.Ext
+			if file.Name == "" || filepath.Base(name) != name || !fsutil.IsFile(filepath.Join(dir, list.dir, name)) {
+				return fmt.Errorf("%s: %s is not in the catalog.", list.dir, name)
+			}
+		}
+	}
+	return nil
+}
+
+// importCatalogProduct writes a product of a catalog with the stored copies of its
+// images and files. The images of an existing product are replaced when the catalog
+// has any, the replaced ones are left to the garbage collection.
+func importCatalogProduct(ctx context.Context, tx *sql.Tx, fs storage.Storage, dir, productID, digitalType string, exists bool, product models.CatalogProduct) error {
+	metadata, err := json.Marshal(product.Metadata)
+	if err != nil {
+		return err
+	}
+	attributes, err := json.Marshal(product.Attributes)
+	if err != nil {
+		return err
+	}
+	seo, err := json.Marshal(product.Seo)
+	if err != nil {
+		return err
+	}
+	if product.Seo == nil {
+		seo = []byte("{}")
+	}
+
+	if exists {
+		query := `UPDATE product SET name = ?, brief = ?, desc = ?, amount = ?, metadata = ?, attribute = ?, seo = ?, updated = datetime('now') WHERE id = ?`
+		_, err = tx.ExecContext(ctx, query, product.Name, product.Brief, product.Description, product.Amount, metadata, attributes, seo, productID)
+	} else {
+		query := `INSERT INTO product (id, name, slug, brief, desc, amount, metadata, attribute, seo, digital, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE)`
+		_, err = tx.ExecContext(ctx, query, productID, product.Name, product.Slug, product.Brief, product.Description, product.Amount, metadata, attributes, seo, digitalType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(product.Images) > 0 && exists {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_image WHERE product_id = ?`, productID); err != nil {
+			return err
+		}
+	}
+	for _, image := range product.Images {
+		name := uuid.New().String()
+		for _, size := range []string{"", "_sm", "_md"} {
+			// an image without its resized copies stands in for them
+			src := filepath.Join(dir, catalogImages, image.Name+size+"."+image.Ext)
+			if !fsutil.IsFile(src) {
+				src = filepath.Join(dir, catalogImages, image.Name+"."+image.Ext)
+			}
+			if err := storeCatalogFile(ctx, fs, src, UploadKey(name+size+"."+image.Ext)); err != nil {
+				return err
+			}
+		}
+
+		query := `INSERT INTO product_image (id, product_id, name, ext, orig_name) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, security.RandomString(), productID, name, image.Ext, catalogOrigName(image)); err != nil {
+			return err
+		}
+	}
+
+	if digitalType != "file" {
+		return nil
+	}
+	for _, file := range product.Files {
+		var present bool
+		query := `SELECT EXISTS(SELECT 1 FROM digital_file WHERE product_id = ? AND orig_name = ? AND active = 1)`
+		if err := tx.QueryRowContext(ctx, query, productID, catalogOrigName(file)).Scan(&present); err != nil {
+			return err
+		}
+		if present {
+			continue
+		}
+
+		name := uuid.New().String()
+		if err := storeCatalogFile(ctx, fs, filepath.Join(dir, catalogFiles, file.Name+"."+file.Ext), DigitalKey(name, file.Ext)); err != nil {
+			return err
+		}
+
+		id := security.RandomString()
+		query = `INSERT INTO digital_file (id, product_id, name, ext, orig_name, lineage_id, created) VALUES (?, ?, ?, ?, ?, ?, datetime('now'))`
+		if _, err := tx.ExecContext(ctx, query, id, productID, name, file.Ext, catalogOrigName(file), id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// catalogOrigName returns the name a file of a catalog was uploaded with.
+func catalogOrigName(file models.CatalogFile) string {
+	if file.OrigName != "" {
+		return file.OrigName
+	}
+	return file.Name + "." + file.Ext
+}
+
+// storeCatalogFile copies a file of an extracted catalog into the storage.
+func storeCatalogFile(ctx context.Context, fs storage.Storage, src, key string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return fs.Put(ctx, key, file, info.Size())
+}
+
+// catalogEntry describes a file written to a catalog archive.
+type catalogEntry struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+func (e catalogEntry) Name() string      { return e.name }
+func (e catalogEntry) Size() int64       { return e.size }
+func (e catalogEntry) Mode() os.FileMode { return 0o644 }
+func (e catalogEntry) IsDir() bool       { return false }
+func (e catalogEntry) Sys() any          { return nil }
+
+func (e catalogEntry) ModTime() time.Time {
+	if e.modified.IsZero() {
+		return time.Now()
+	}
+	return e.modified
+}
@@ -0,0 +1,153 @@
+package queries
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/storage"
+)
+
+func TestCatalog(t *testing.T) {
+	newTestProducts(t, 3)
+	ctx := context.Background()
+
+	slugCount := func(slug string) (count int) {
+		require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COUNT(id) FROM product WHERE slug = ?`, slug).Scan(&count))
+		return count
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		products := []models.CatalogProduct{{
+			Name:        "Trail Maps",
+			Slug:        "trail-maps",
+			Description: "maps, \"gpx\" and notes",
+			Amount:      500,
+			Digital:     "file",
+			Metadata:    []models.Metadata{{Key: "region", Value: "alps"}},
+			Attributes:  []string{"gpx"},
+			Images:      []models.CatalogFile{{Name: "cover", Ext: "png"}},
+		}}
+		data, err := encodeCatalog(products, "csv")
+		require.NoError(t, err)
+		decoded, err := decodeCatalog(data, "csv")
+		require.NoError(t, err)
+		assert.Equal(t, products, decoded)
+	})
+
+	t.Run("failed export", func(t *testing.T) {
+		file, err := os.Create(filepath.Join(t.TempDir(), "products.zip"))
+		require.NoError(t, err)
+		assert.Error(t, db.ExportCatalog(ctx, file, "xml"))
+		assert.ErrorIs(t, file.Close(), os.ErrClosed)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "catalog.json")
+		require.NoError(t, os.WriteFile(src, []byte(`[
+			{"name": "Trail Maps", "slug": "trail-maps", "description": "maps", "amount": 500, "digital": "file"},
+			{"name": "X", "slug": "bad", "description": "", "amount": 0, "digital": "file"},
+			{"name": "Renamed", "slug": "product-1", "description": "guide", "amount": 300, "digital": "data"},
+			{"name": "Photos", "slug": "photos", "description": "photos", "amount": 100, "digital": "file", "images": [{"name": "cover", "ext": "png"}]}
+		]`), 0o644))
+
+		report, err := db.ImportCatalog(ctx, src, false, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Inserted)
+		assert.Equal(t, []int{3}, report.Skipped)
+		require.Len(t, report.Invalid, 2)
+		assert.Equal(t, 2, report.Invalid[0].Row)
+		assert.Equal(t, "photos", report.Invalid[1].Slug)
+		assert.Zero(t, slugCount("trail-maps"))
+
+		report, err = db.ImportCatalog(ctx, src, true, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Inserted)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 1, slugCount("trail-maps"))
+
+		var name, digital string
+		require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT name, digital FROM product WHERE slug = 'product-1'`).Scan(&name, &digital))
+		assert.Equal(t, "Renamed", name)
+		assert.Equal(t, "api", digital)
+
+		notes := filepath.Join(t.TempDir(), "notes.txt")
+		require.NoError(t, os.WriteFile(notes, []byte("notes"), 0o644))
+		_, err = db.ImportCatalog(ctx, notes, false, false)
+		assert.Equal(t, errors.ErrCatalogInvalid, err)
+	})
+}
+
+func TestCatalogRoundTrip(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+	const productID = "p00000000000001"
+
+	fs, err := Storage(ctx)
+	require.NoError(t, err)
+	put := func(key, content string) {
+		require.NoError(t, fs.Put(ctx, key, strings.NewReader(content), int64(len(content))))
+	}
+	read := func(key string) string {
+		data, err := storage.ReadAll(ctx, fs, key)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// product 1 sells a manual with its image and resized copies in the storage,
+	// product 0 has an image that is missing from the storage
+	for _, size := range []string{"", "_sm", "_md"} {
+		put(UploadKey("image-1"+size+".png"), "image"+size)
+	}
+	put(DigitalKey("manual", "pdf"), "manual")
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'file', metadata = '[{"key": "region", "value": "alps"}]' WHERE id = ?`, productID)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO digital_file (id, product_id, name, ext, orig_name, lineage_id, created) VALUES ('f00000000000001', ?, 'manual', 'pdf', 'Manual.pdf', 'f00000000000001', datetime('now'))`, productID)
+	require.NoError(t, err)
+
+	src := filepath.Join(t.TempDir(), "products.zip")
+	file, err := os.Create(src)
+	require.NoError(t, err)
+	require.NoError(t, db.ExportCatalog(ctx, file, "json"))
+
+	// the slugs are freed so the products of the archive are added as new ones
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET slug = slug || '-old'`)
+	require.NoError(t, err)
+
+	report, err := db.ImportCatalog(ctx, src, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Empty(t, report.Invalid)
+
+	var importedID, digital, metadata string
+	err = db.ProductQueries.DB.QueryRowContext(ctx, `SELECT id, digital, metadata FROM product WHERE slug = 'product-1'`).Scan(&importedID, &digital, &metadata)
+	require.NoError(t, err)
+	assert.Equal(t, "file", digital)
+	assert.JSONEq(t, `[{"key": "region", "value": "alps"}]`, metadata)
+
+	var imageName, imageExt string
+	err = db.ProductQueries.DB.QueryRowContext(ctx, `SELECT name, ext FROM product_image WHERE product_id = ?`, importedID).Scan(&imageName, &imageExt)
+	require.NoError(t, err)
+	assert.NotEqual(t, "image-1", imageName)
+	for _, size := range []string{"", "_sm", "_md"} {
+		assert.Equal(t, "image"+size, read(UploadKey(imageName+size+"."+imageExt)))
+	}
+
+	var fileName, fileExt, origName string
+	err = db.ProductQueries.DB.QueryRowContext(ctx, `SELECT name, ext, orig_name FROM digital_file WHERE product_id = ? AND active = 1`, importedID).Scan(&fileName, &fileExt, &origName)
+	require.NoError(t, err)
+	assert.Equal(t, "Manual.pdf", origName)
+	assert.Equal(t, "manual", read(DigitalKey(fileName, fileExt)))
+
+	var images int
+	err = db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COUNT(product_image.id) FROM product_image JOIN product ON product.id = product_image.product_id WHERE product.slug = 'product-0'`).Scan(&images)
+	require.NoError(t, err)
+	assert.Zero(t, images)
+}
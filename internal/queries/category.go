@@ -0,0 +1,267 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// CategoryQueries is a struct that embeds a pointer to an sql.DB.
+// This allows for direct access to database methods on the CategoryQueries struct,
+// effectively extending it with all the methods of *sql.DB.
+type CategoryQueries struct {
+	*sql.DB
+}
+
+// visibleCategory selects the ids of the categories shown in the store,
+// an inactive category hides its whole branch.
+const visibleCategory = `
+			WITH RECURSIVE visible(id) AS (
+				SELECT id FROM category WHERE parent_id IS NULL AND active = 1
+				UNION
+				SELECT category.id FROM category JOIN visible ON category.parent_id = visible.id WHERE category.active = 1
+			)
+			SELECT id FROM visible
+	`
+
+// categoryTree selects the id of the category with the given slug and the ids
+// of all its subcategories, only the visible ones unless the query is private.
+func categoryTree(private bool) string {
+	root, branch := ``, ``
+	if !private {
+		root, branch = ` AND id IN (`+visibleCategory+`)`, ` WHERE category.active = 1`
+	}
+	return `
+			WITH RECURSIVE tree(id) AS (
+				SELECT id FROM category WHERE slug = ?` + root + `
+				UNION
+				SELECT category.id FROM category JOIN tree ON category.parent_id = tree.id` + branch + `
+			)
+			SELECT id FROM tree
+	`
+}
+
+// IsCategory checks if a visible category with the given slug exists in the database.
+func (q *CategoryQueries) IsCategory(ctx context.Context, slug string) bool {
+	var exists bool
+	err := q.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM category WHERE slug = ? AND id IN (`+visibleCategory+`))`, slug).Scan(&exists)
+	return err == nil && exists
+}
+
+// ListCategories retrieves the categories ordered by their sort order and name,
+// hidden categories are only listed when `private` is set to true.
+func (q *CategoryQueries) ListCategories(ctx context.Context, private bool) ([]models.Category, error) {
+	categories := []models.Category{}
+
+	query := `SELECT id, parent_id, name, slug, description, sort, active, seo, strftime('%s', created), strftime('%s', updated) FROM category`
+	if !private {
+		query += ` WHERE id IN (` + visibleCategory + `)`
+	}
+	query += ` ORDER BY sort, name`
+
+	rows, err := q.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// Category retrieves a category by its ID, or a visible category by its slug
+// when the private data is not requested.
+func (q *CategoryQueries) Category(ctx context.Context, private bool, id string) (*models.Category, error) {
+	query := `SELECT id, parent_id, name, slug, description, sort, active, seo, strftime('%s', created), strftime('%s', updated) FROM category`
+	if private {
+		query += ` WHERE id = ?`
+	} else {
+		query += ` WHERE slug = ? AND id IN (` + visibleCategory + `)`
+	}
+
+	category, err := scanCategory(q.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrCategoryNotFound
+		}
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// AddCategory inserts a new category into the database and returns the created category.
+func (q *CategoryQueries) AddCategory(ctx context.Context, category *models.Category) (*models.Category, error) {
+	category.ID = security.RandomString()
+
+	if err := q.checkCategory(ctx, category); err != nil {
+		return nil, err
+	}
+
+	seo, err := json.Marshal(category.Seo)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO category (id, parent_id, name, slug, description, sort, active, seo) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING strftime('%s', created)`
+	err = q.DB.QueryRowContext(ctx, query,
+		category.ID, nullString(category.ParentID), category.Name, category.Slug, category.Description, category.Sort, category.Active, seo,
+	).Scan(&category.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory updates the details of a category in the database.
+func (q *CategoryQueries) UpdateCategory(ctx context.Context, category *models.Category) error {
+	if err := q.checkCategory(ctx, category); err != nil {
+		return err
+	}
+
+	seo, err := json.Marshal(category.Seo)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE category SET parent_id = ?, name = ?, slug = ?, description = ?, sort = ?, seo = ?, updated = datetime('now') WHERE id = ?`
+	result, err := q.DB.ExecContext(ctx, query,
+		nullString(category.ParentID), category.Name, category.Slug, category.Description, category.Sort, seo, category.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category, its subcategories move up to its parent
+// and its products stay in the catalog.
+func (q *CategoryQueries) DeleteCategory(ctx context.Context, id string) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE category SET parent_id = (SELECT parent_id FROM category WHERE id = ?), updated = datetime('now') WHERE parent_id = ?`
+	if _, err := tx.ExecContext(ctx, query, id, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM category WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateCategoryActive toggles the active status of a category with the given ID.
+func (q *CategoryQueries) UpdateCategoryActive(ctx context.Context, id string) error {
+	query := `UPDATE category SET active = NOT active, updated = datetime('now') WHERE id = ?`
+	_, err := q.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// checkCategory makes sure the slug is free and the parent exists and is not
+// the category itself or one of its subcategories.
+func (q *CategoryQueries) checkCategory(ctx context.Context, category *models.Category) error {
+	var exists bool
+	err := q.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM category WHERE slug = ? AND id != ?)`, category.Slug, category.ID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.ErrCategoryExists
+	}
+
+	if category.ParentID == "" {
+		return nil
+	}
+	if category.ParentID == category.ID {
+		return errors.ErrCategoryLoop
+	}
+
+	query := `
+			WITH RECURSIVE ancestor(id, parent_id) AS (
+				SELECT id, parent_id FROM category WHERE id = ?
+				UNION
+				SELECT category.id, category.parent_id FROM category JOIN ancestor ON category.id = ancestor.parent_id
+			)
+			SELECT COUNT(*), COALESCE(SUM(id = ?), 0) FROM ancestor
+	`
+	var count, loop int
+	if err := q.DB.QueryRowContext(ctx, query, category.ParentID, category.ID).Scan(&count, &loop); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.ErrCategoryNotFound
+	}
+	if loop > 0 {
+		return errors.ErrCategoryLoop
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	category := &models.Category{}
+	var parentID, seo sql.NullString
+	var updated sql.NullInt64
+
+	err := row.Scan(
+		&category.ID,
+		&parentID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.Sort,
+		&category.Active,
+		&seo,
+		&category.Created,
+		&updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	category.ParentID = parentID.String
+	if updated.Valid {
+		category.Updated = updated.Int64
+	}
+	if seo.Valid {
+		if err := json.Unmarshal([]byte(seo.String), &category.Seo); err != nil {
+			return nil, err
+		}
+	}
+
+	return category, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
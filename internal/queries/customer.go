@@ -0,0 +1,353 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// CustomerQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to customers and their purchase history.
+type CustomerQueries struct {
+	*sql.DB
+}
+
+// CustomerID returns the ID of the customer with the given email,
+// creating the customer first if it does not exist yet.
+func (q *CustomerQueries) CustomerID(ctx context.Context, email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	query := `INSERT INTO customer (id, email) VALUES (?, ?) ON CONFLICT(email) DO NOTHING`
+	if _, err := q.DB.ExecContext(ctx, query, security.RandomString(), email); err != nil {
+		return "", err
+	}
+
+	var id string
+	if err := q.DB.QueryRowContext(ctx, `SELECT id FROM customer WHERE email = ?`, email).Scan(&id); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// CustomerByEmail retrieves a customer by email address.
+func (q *CustomerQueries) CustomerByEmail(ctx context.Context, email string) (*models.Customer, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	var id string
+	if err := q.DB.QueryRowContext(ctx, `SELECT id FROM customer WHERE email = ?`, email).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrCustomerNotFound
+		}
+		return nil, err
+	}
+
+	return q.Customer(ctx, id)
+}
+
+// Customers retrieves the list of customers together with their
+// paid order count and lifetime value.
+func (q *CustomerQueries) Customers(ctx context.Context) (*models.Customers, error) {
+	currency, err := db.GetSettingByKey(ctx, "currency")
+	if err != nil {
+		return nil, err
+	}
+
+	customers := &models.Customers{
+		Currency:  currency["currency"].Value.(string),
+		Customers: []models.Customer{},
+	}
+
+	query := `
+		SELECT
+			customer.id,
+			customer.email,
+			COUNT(cart.id),
+			COALESCE(SUM(cart.amount_total), 0),
+			customer.unsubscribed,
+			strftime('%s', customer.created),
+			strftime('%s', customer.updated)
+		FROM customer
+		LEFT JOIN cart ON cart.customer_id = customer.id AND cart.payment_status = ?
+		GROUP BY customer.id
+		ORDER BY customer.created DESC
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, litepay.PAID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var updated sql.NullInt64
+		customer := models.Customer{}
+		err := rows.Scan(
+			&customer.ID,
+			&customer.Email,
+			&customer.OrderCount,
+			&customer.LifetimeValue,
+			&customer.Unsubscribed,
+			&customer.Created,
+			&updated,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if updated.Valid {
+			customer.Updated = updated.Int64
+		}
+
+		customers.Customers = append(customers.Customers, customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	customers.Total = len(customers.Customers)
+	return customers, nil
+}
+
+// Customer retrieves a customer with its paid order count and lifetime value.
+func (q *CustomerQueries) Customer(ctx context.Context, id string) (*models.Customer, error) {
+	query := `
+		SELECT
+			customer.id,
+			customer.email,
+			COUNT(cart.id),
+			COALESCE(SUM(cart.amount_total), 0),
+			customer.unsubscribed,
+			strftime('%s', customer.created),
+			strftime('%s', customer.updated)
+		FROM customer
+		LEFT JOIN cart ON cart.customer_id = customer.id AND cart.payment_status = ?
+		WHERE customer.id = ?
+		GROUP BY customer.id
+	`
+
+	var updated sql.NullInt64
+	customer := &models.Customer{}
+	err := q.DB.QueryRowContext(ctx, query, litepay.PAID, id).Scan(
+		&customer.ID,
+		&customer.Email,
+		&customer.OrderCount,
+		&customer.LifetimeValue,
+		&customer.Unsubscribed,
+		&customer.Created,
+		&updated,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrCustomerNotFound
+		}
+		return nil, err
+	}
+
+	if updated.Valid {
+		customer.Updated = updated.Int64
+	}
+
+	return customer, nil
+}
+
+// CustomerUnsubscribe turns off the recovery letters for a customer.
+func (q *CustomerQueries) CustomerUnsubscribe(ctx context.Context, id string) error {
+	query := `UPDATE customer SET unsubscribed = 1, updated = datetime('now') WHERE id = ?`
+	_, err := q.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// CustomerPurchases retrieves the paid carts of a customer with the keys and files delivered for every product.
+func (q *CustomerQueries) CustomerPurchases(ctx context.Context, customerID string) ([]models.Purchase, error) {
+	purchases := []models.Purchase{}
+	carts := map[string]string{}
+
+	query := `
+		SELECT id, cart, amount_total, currency, payment_status, strftime('%s', created)
+		FROM cart
+		WHERE customer_id = ? AND payment_status = ?
+		ORDER BY created DESC
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, customerID, litepay.PAID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cartJSON string
+		purchase := models.Purchase{}
+		err := rows.Scan(
+			&purchase.CartID,
+			&cartJSON,
+			&purchase.AmountTotal,
+			&purchase.Currency,
+			&purchase.PaymentStatus,
+			&purchase.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		carts[purchase.CartID] = cartJSON
+		purchases = append(purchases, purchase)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	setting, err := db.GetSettingByKey(ctx, "domain", "jwt_secret")
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := GetSettingByGroup[models.Delivery](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	link := func(cartID, fileID string, productTTL int) string {
+		expires := downloadExpires(productTTL, delivery.LinkTTL)
+		return DownloadURL(setting["domain"].Value.(string), setting["jwt_secret"].Value.(string), cartID, fileID, expires)
+	}
+
+	for i := range purchases {
+		cartProducts := []models.CartProduct{}
+		if err := json.Unmarshal([]byte(carts[purchases[i].CartID]), &cartProducts); err != nil {
+			return nil, err
+		}
+
+		for _, cartProduct := range cartProducts {
+			product, err := q.purchaseProduct(ctx, purchases[i].CartID, cartProduct)
+			if err != nil {
+				return nil, err
+			}
+			for j := range product.Files {
+				product.Files[j].URL = link(purchases[i].CartID, product.Files[j].ID, product.DownloadTTL)
+			}
+			for _, component := range product.Bundle {
+				for j := range component.Files {
+					component.Files[j].URL = link(purchases[i].CartID, component.Files[j].ID, component.DownloadTTL)
+				}
+			}
+			purchases[i].Products = append(purchases[i].Products, *product)
+		}
+	}
+
+	return purchases, nil
+}
+
+// purchaseProduct collects the delivered keys and files of a single product in a cart.
+func (q *CustomerQueries) purchaseProduct(ctx context.Context, cartID string, cartProduct models.CartProduct) (*models.PurchaseProduct, error) {
+	product := &models.PurchaseProduct{
+		ID:       cartProduct.ProductID,
+		Quantity: cartProduct.Quantity,
+	}
+
+	var digitalType sql.NullString
+	query := `
+	SELECT product.name, product.slug, product.digital, product.download_ttl, COALESCE(product_variant.name, '')
+	FROM product
+	LEFT JOIN product_variant ON product_variant.id = ? AND product_variant.product_id = product.id
+	WHERE product.id = ?
+	`
+	err := q.DB.QueryRowContext(ctx, query, cartProduct.VariantID, cartProduct.ProductID).
+		Scan(&product.Name, &product.Slug, &digitalType, &product.DownloadTTL, &product.Variant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return product, nil
+		}
+		return nil, err
+	}
+
+	switch digitalType.String {
+	case "bundle":
+		for _, component := range cartProduct.Bundle {
+			componentProduct, err := q.purchaseProduct(ctx, cartID, component)
+			if err != nil {
+				return nil, err
+			}
+			product.Bundle = append(product.Bundle, *componentProduct)
+		}
+
+	case "file":
+		query := `SELECT id, name, ext, orig_name, version FROM digital_file WHERE product_id = ? AND active = 1 AND ` + variantScope("digital_file")
+		rows, err := q.DB.QueryContext(ctx, query, cartProduct.ProductID, cartProduct.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			file := models.PurchaseFile{}
+			if err := rows.Scan(&file.ID, &file.Name, &file.Ext, &file.OrigName, &file.Version); err != nil {
+				return nil, err
+			}
+			product.Files = append(product.Files, file)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+	case "api":
+		query := `SELECT content FROM cart_delivery WHERE cart_id = ? AND product_id = ? AND status = ?`
+		err := q.DB.QueryRowContext(ctx, query, cartID, cartProduct.ProductID, models.CartDeliveryDelivered).Scan(&product.Delivery)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+	case "data", "generated":
+		query := `SELECT content FROM digital_data WHERE cart_id = ? AND product_id = ? AND ` + variantScope("digital_data")
+		rows, err := q.DB.QueryContext(ctx, query, cartID, cartProduct.ProductID, cartProduct.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return nil, err
+			}
+			product.Keys = append(product.Keys, key)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return product, nil
+}
+
+// CustomerLetterLogin is ...
+func (q *CustomerQueries) CustomerLetterLogin(ctx context.Context, email, loginURL string) (*models.MessageMail, error) {
+	mailLetter, err := db.GetSettingByKey(ctx, "site_name", "mail_letter_login")
+	if err != nil {
+		return nil, err
+	}
+	letterTemplate := models.Letter{}
+	if err := json.Unmarshal([]byte(mailLetter["mail_letter_login"].Value.(string)), &letterTemplate); err != nil {
+		return nil, err
+	}
+
+	mail := &models.MessageMail{
+		To:     email,
+		Letter: letterTemplate,
+		Data: map[string]string{
+			"Login_URL": loginURL,
+			"Site_Name": mailLetter["site_name"].Value.(string),
+		},
+	}
+
+	return mail, nil
+}
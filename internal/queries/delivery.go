@@ -0,0 +1,234 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// DeliveryQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to the delivery of "api" products.
+type DeliveryQueries struct {
+	*sql.DB
+}
+
+// PendingDeliveries retrieves the deliveries that are due for an attempt.
+// An empty cartID selects the due deliveries of all carts.
+func (q *DeliveryQueries) PendingDeliveries(ctx context.Context, cartID string) ([]models.CartDelivery, error) {
+	query := `
+	SELECT id, cart_id, product_id, status, attempts
+	FROM cart_delivery
+	WHERE status = ? AND next_attempt <= datetime('now') AND (? = '' OR cart_id = ?)
+	ORDER BY next_attempt
+	LIMIT 100
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, models.CartDeliveryPending, cartID, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.CartDelivery{}
+	for rows.Next() {
+		delivery := models.CartDelivery{}
+		if err := rows.Scan(&delivery.ID, &delivery.CartID, &delivery.ProductID, &delivery.Status, &delivery.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimCartDelivery marks a pending delivery as being processed and counts the attempt.
+// It reports false when another caller claimed the delivery first, the endpoint
+// must then not be called.
+func (q *DeliveryQueries) ClaimCartDelivery(ctx context.Context, delivery *models.CartDelivery) (bool, error) {
+	query := `
+	UPDATE cart_delivery SET status = ?, attempts = attempts + 1, updated = datetime('now')
+	WHERE id = ? AND status = ? AND attempts = ?
+	`
+	res, err := q.DB.ExecContext(ctx, query, models.CartDeliveryProcessing, delivery.ID, models.CartDeliveryPending, delivery.Attempts)
+	if err != nil {
+		return false, err
+	}
+
+	if affected, _ := res.RowsAffected(); affected != 1 {
+		return false, nil
+	}
+
+	delivery.Status = models.CartDeliveryProcessing
+	delivery.Attempts++
+	return true, nil
+}
+
+// ReleaseCartDeliveries puts back the deliveries claimed longer ago than the timeout,
+// their caller stopped before storing the outcome.
+func (q *DeliveryQueries) ReleaseCartDeliveries(ctx context.Context, timeout time.Duration) (int64, error) {
+	query := `
+	UPDATE cart_delivery SET status = ?, next_attempt = datetime('now'), updated = datetime('now')
+	WHERE status = ? AND updated <= datetime('now', ?)
+	`
+	res, err := q.DB.ExecContext(ctx, query, models.CartDeliveryPending, models.CartDeliveryProcessing, fmt.Sprintf("-%d seconds", int(timeout.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CartDeliveries retrieves all deliveries of a cart.
+func (q *DeliveryQueries) CartDeliveries(ctx context.Context, cartID string) ([]models.CartDelivery, error) {
+	query := `
+	SELECT cart_delivery.id, cart_delivery.cart_id, cart_delivery.product_id, COALESCE(product.name, ''), 
+		cart_delivery.status, cart_delivery.attempts, COALESCE(strftime('%s', cart_delivery.next_attempt), 0), 
+		cart_delivery.content, cart_delivery.error, strftime('%s', cart_delivery.created), COALESCE(strftime('%s', cart_delivery.updated), 0)
+	FROM cart_delivery
+	LEFT JOIN product ON product.id = cart_delivery.product_id
+	WHERE cart_delivery.cart_id = ?
+	ORDER BY cart_delivery.created
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.CartDelivery{}
+	for rows.Next() {
+		delivery := models.CartDelivery{}
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.CartID,
+			&delivery.ProductID,
+			&delivery.ProductName,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttempt,
+			&delivery.Content,
+			&delivery.Error,
+			&delivery.Created,
+			&delivery.Updated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// DeliveryRequest retrieves the endpoint of the product and builds the payload for a delivery attempt.
+func (q *DeliveryQueries) DeliveryRequest(ctx context.Context, delivery *models.CartDelivery) (*models.DigitalAPI, *models.CartDeliveryRequest, error) {
+	api := &models.DigitalAPI{}
+	err := q.DB.QueryRowContext(ctx, `SELECT url, secret FROM digital_api WHERE product_id = ?`, delivery.ProductID).Scan(&api.URL, &api.Secret)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, errors.ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	var email sql.NullString
+	var cartJSON string
+	if err := q.DB.QueryRowContext(ctx, `SELECT email, cart FROM cart WHERE id = ?`, delivery.CartID).Scan(&email, &cartJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, errors.ErrCartNotFound
+		}
+		return nil, nil, err
+	}
+
+	products := []models.CartProduct{}
+	if err := json.Unmarshal([]byte(cartJSON), &products); err != nil {
+		return nil, nil, err
+	}
+
+	request := &models.CartDeliveryRequest{
+		CartID:    delivery.CartID,
+		ProductID: delivery.ProductID,
+		Email:     email.String,
+		Quantity:  1,
+		Attempt:   delivery.Attempts,
+		TimeStamp: time.Now().Unix(),
+	}
+	for _, product := range deliveryLines(products) {
+		if product.ProductID == delivery.ProductID {
+			request.Quantity = keyQuantity(product)
+			request.VariantID = product.VariantID
+		}
+	}
+
+	return api, request, nil
+}
+
+// UpdateCartDelivery stores the outcome of a delivery attempt.
+func (q *DeliveryQueries) UpdateCartDelivery(ctx context.Context, delivery *models.CartDelivery) error {
+	query := `
+	UPDATE cart_delivery SET 
+		status = ?, 
+		attempts = ?, 
+		next_attempt = datetime(?, 'unixepoch'), 
+		content = ?, 
+		error = ?, 
+		updated = datetime('now')
+	WHERE id = ?
+	`
+	_, err := q.DB.ExecContext(ctx, query, delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.Content, delivery.Error, delivery.ID)
+	return err
+}
+
+// RetryCartDeliveries schedules the failed deliveries of a cart for an immediate attempt
+// and returns the number of deliveries that were rescheduled.
+func (q *DeliveryQueries) RetryCartDeliveries(ctx context.Context, cartID string) (int64, error) {
+	query := `UPDATE cart_delivery SET status = ?, next_attempt = datetime('now'), updated = datetime('now') WHERE cart_id = ? AND status = ?`
+	res, err := q.DB.ExecContext(ctx, query, models.CartDeliveryPending, cartID, models.CartDeliveryFailed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// addCartDeliveries queues a delivery for every "api" product of a paid cart, including the ones in bundles.
+func addCartDeliveries(ctx context.Context, tx *sql.Tx, cartID string) error {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT DISTINCT product.id
+	FROM cart, json_tree(cart.cart)
+	JOIN product ON product.id = json_tree.value
+	WHERE cart.id = ? AND json_tree.key = 'id' AND product.digital = 'api'
+	`, cartID)
+	if err != nil {
+		return err
+	}
+
+	productIDs := []string{}
+	for rows.Next() {
+		var productID string
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	query := `INSERT OR IGNORE INTO cart_delivery (id, cart_id, product_id) VALUES (?, ?, ?)`
+	for _, productID := range productIDs {
+		if _, err := tx.ExecContext(ctx, query, security.RandomString(), cartID, productID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+)
+
+func TestClaimCartDelivery(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO cart (id, email, amount_total, currency, payment_status, cart) VALUES ('c00000000000001', 'buyer@mail.com', 100, 'USD', 'paid', '[]')`)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO cart_delivery (id, cart_id, product_id) VALUES ('d00000000000001', 'c00000000000001', 'p00000000000001')`)
+	require.NoError(t, err)
+
+	pending := func() []models.CartDelivery {
+		deliveries, err := db.PendingDeliveries(ctx, "c00000000000001")
+		require.NoError(t, err)
+		return deliveries
+	}
+
+	// two callers read the same pending delivery, only the first one may call the endpoint
+	first, second := pending(), pending()
+	require.Len(t, first, 1)
+	claimed, err := db.ClaimCartDelivery(ctx, &first[0])
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, 1, first[0].Attempts)
+	assert.Equal(t, models.CartDeliveryProcessing, first[0].Status)
+
+	claimed, err = db.ClaimCartDelivery(ctx, &second[0])
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Empty(t, pending())
+
+	released, err := db.ReleaseCartDeliveries(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), released)
+
+	// the delivery is attempted again, the claim that was lost does not match anymore
+	claimed, err = db.ClaimCartDelivery(ctx, &second[0])
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	again := pending()
+	require.Len(t, again, 1)
+	assert.Equal(t, 1, again[0].Attempts)
+	claimed, err = db.ClaimCartDelivery(ctx, &again[0])
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, 2, again[0].Attempts)
+}
@@ -0,0 +1,118 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// DownloadQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to the download log of purchased files.
+type DownloadQueries struct {
+	*sql.DB
+}
+
+// AddDownload records a download of a file when the limits of its product allow it.
+// The download counter and the distinct IP addresses are counted per purchase and product,
+// downloads reset by an administrator are not taken into account.
+// It returns errors.ErrDownloadLimit when the limit has been reached.
+func (q *DownloadQueries) AddDownload(ctx context.Context, file *models.DownloadFile, download *models.Download) error {
+	download.ID = security.RandomString()
+	download.ProductID = file.ProductID
+	download.FileID = file.ID
+	download.Counted = true
+
+	// the limits are checked by the insert itself, parallel requests can not pass them together
+	query := `
+	INSERT INTO download (id, cart_id, product_id, file_id, ip, user_agent)
+	SELECT ?, ?, ?, ?, ?, ?
+	FROM (
+		SELECT 
+			COUNT(*) AS count, 
+			COUNT(DISTINCT ip) AS ips, 
+			COALESCE(MAX(ip = ?), 0) AS known_ip
+		FROM download
+		WHERE cart_id = ? AND product_id = ? AND counted = 1
+	)
+	WHERE (? = 0 OR count < ?) AND (? = 0 OR known_ip OR ips < ?)
+	`
+	res, err := q.DB.ExecContext(ctx, query,
+		download.ID, download.CartID, download.ProductID, download.FileID, download.IP, download.UserAgent,
+		download.IP, download.CartID, download.ProductID,
+		file.Limit, file.Limit, file.IPLimit, file.IPLimit,
+	)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		download.Counted = false
+		return errors.ErrDownloadLimit
+	}
+	return nil
+}
+
+// UncountDownload stops counting a recorded download against the limits when the file
+// could not be served after all. The download stays in the log.
+func (q *DownloadQueries) UncountDownload(ctx context.Context, id string) error {
+	_, err := q.DB.ExecContext(ctx, `UPDATE download SET counted = 0 WHERE id = ?`, id)
+	return err
+}
+
+// CartDownloads retrieves the download log of a cart, newest first.
+func (q *DownloadQueries) CartDownloads(ctx context.Context, cartID string) ([]models.Download, error) {
+	downloads := []models.Download{}
+
+	query := `
+	SELECT download.id, download.cart_id, download.product_id, download.file_id, COALESCE(digital_file.orig_name, ''), 
+		download.ip, download.user_agent, download.counted, strftime('%s', download.created)
+	FROM download
+	LEFT JOIN digital_file ON digital_file.id = download.file_id
+	WHERE download.cart_id = ?
+	ORDER BY download.created DESC, download.rowid DESC
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		download := models.Download{}
+		err := rows.Scan(
+			&download.ID,
+			&download.CartID,
+			&download.ProductID,
+			&download.FileID,
+			&download.FileName,
+			&download.IP,
+			&download.UserAgent,
+			&download.Counted,
+			&download.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, download)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return downloads, nil
+}
+
+// ResetDownloads stops counting the previous downloads of a cart against the limits
+// and returns the number of downloads that were reset. The log itself is kept.
+func (q *DownloadQueries) ResetDownloads(ctx context.Context, cartID string) (int64, error) {
+	result, err := q.DB.ExecContext(ctx, `UPDATE download SET counted = 0 WHERE cart_id = ? AND counted = 1`, cartID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
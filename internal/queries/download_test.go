@@ -0,0 +1,74 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+func TestAddDownload(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+	const cartID = "c00000000000001"
+
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO cart (id, email, amount_total, currency, payment_status, cart) VALUES (?, 'buyer@mail.com', 100, 'USD', 'paid', '[]')`, cartID)
+	require.NoError(t, err)
+
+	file := &models.DownloadFile{File: models.File{ID: "f00000000000001"}, ProductID: "p00000000000001", Limit: 3}
+	count := func() (count int) {
+		require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM download WHERE cart_id = ?`, cartID).Scan(&count))
+		return count
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		// parallel requests can not download more often than the limit allows
+		var wg sync.WaitGroup
+		results := make([]error, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = db.AddDownload(ctx, file, &models.Download{CartID: cartID, IP: "10.0.0.1"})
+			}(i)
+		}
+		wg.Wait()
+
+		limited := 0
+		for _, err := range results {
+			if err != nil {
+				assert.Equal(t, errors.ErrDownloadLimit, err)
+				limited++
+			}
+		}
+		assert.Equal(t, 7, limited)
+		assert.Equal(t, 3, count())
+	})
+
+	t.Run("uncount", func(t *testing.T) {
+		// a download that could not be served gives the buyer the attempt back
+		download := &models.Download{CartID: cartID, IP: "10.0.0.1"}
+		assert.Equal(t, errors.ErrDownloadLimit, db.AddDownload(ctx, file, download))
+
+		downloads, err := db.CartDownloads(ctx, cartID)
+		require.NoError(t, err)
+		require.NoError(t, db.UncountDownload(ctx, downloads[0].ID))
+		require.NoError(t, db.AddDownload(ctx, file, download))
+		assert.Equal(t, 4, count())
+	})
+
+	t.Run("ip limit", func(t *testing.T) {
+		file := &models.DownloadFile{File: models.File{ID: "f00000000000002"}, ProductID: "p00000000000002", IPLimit: 2}
+		for i := 1; i <= 2; i++ {
+			require.NoError(t, db.AddDownload(ctx, file, &models.Download{CartID: cartID, IP: fmt.Sprintf("10.0.1.%d", i)}))
+		}
+		require.NoError(t, db.AddDownload(ctx, file, &models.Download{CartID: cartID, IP: "10.0.1.1"}))
+		assert.Equal(t, errors.ErrDownloadLimit, db.AddDownload(ctx, file, &models.Download{CartID: cartID, IP: "10.0.1.3"}))
+	})
+}
@@ -0,0 +1,162 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+	"github.com/shurco/litecart/pkg/storage"
+)
+
+// slugLength is the longest slug a product is accepted with.
+const slugLength = 20
+
+// DuplicateProduct copies a product with its settings, categories and images under a
+// free slug made of its own. The copy is inactive and has nothing to deliver yet, the
+// keys and files of a product are never shared.
+func (q *ProductQueries) DuplicateProduct(ctx context.Context, id string) (*models.Product, error) {
+	fs, err := Storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var slug string
+	if err := tx.QueryRowContext(ctx, `SELECT slug FROM product WHERE id = ?`, id).Scan(&slug); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	copySlug, err := freeSlug(ctx, tx, slug, "copy")
+	if err != nil {
+		return nil, err
+	}
+
+	copyID := security.RandomString()
+	query := `
+			INSERT INTO product (
+					id, name, desc, slug, amount, metadata, attribute, digital, active, seo, brief,
+					download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end
+			)
+			SELECT ?, name, desc, ?, amount, metadata, attribute, digital, FALSE, seo, brief,
+					download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end
+			FROM product WHERE id = ?
+	`
+	if _, err := tx.ExecContext(ctx, query, copyID, copySlug, id); err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO product_category (product_id, category_id) SELECT ?, category_id FROM product_category WHERE product_id = ?`
+	if _, err := tx.ExecContext(ctx, query, copyID, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT name, ext, orig_name FROM product_image WHERE product_id = ? ORDER BY rowid`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.File
+	for rows.Next() {
+		var image models.File
+		if err := rows.Scan(&image.Name, &image.Ext, &image.OrigName); err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		name := uuid.New().String()
+		copied, err := copyImage(ctx, fs, image, name)
+		if err != nil {
+			return nil, err
+		}
+		if !copied {
+			continue
+		}
+
+		query := `INSERT INTO product_image (id, product_id, name, ext, orig_name) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, security.RandomString(), copyID, name, image.Ext, image.OrigName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return q.Product(ctx, true, copyID)
+}
+
+// freeSlug returns the slug with the suffix, or with the suffix and the first number
+// that makes it unique. The slug is cut to keep the result within the length of a slug.
+func freeSlug(ctx context.Context, tx *sql.Tx, slug, suffix string) (string, error) {
+	for i := 1; ; i++ {
+		tail := "-" + suffix
+		if i > 1 {
+			tail = fmt.Sprintf("-%s-%d", suffix, i)
+		}
+		base := slug
+		if len(base)+len(tail) > slugLength {
+			base = strings.TrimRight(base[:max(slugLength-len(tail), 0)], "-")
+		}
+		candidate := base + tail
+
+		var taken bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM product WHERE slug = ?)`, candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+}
+
+// copyImage stores the image and its resized copies under a new name, the original
+// stands in for a missing copy. An image whose original is gone is not copied.
+func copyImage(ctx context.Context, fs storage.Storage, image models.File, name string) (bool, error) {
+	for _, size := range []string{"", "_sm", "_md"} {
+		src := UploadKey(image.Name + size + "." + image.Ext)
+		if _, err := fs.Stat(ctx, src); err == storage.ErrNotFound {
+			if size == "" {
+				return false, nil
+			}
+			src = UploadKey(image.Name + "." + image.Ext)
+		} else if err != nil {
+			return false, err
+		}
+
+		if err := copyObject(ctx, fs, src, UploadKey(name+size+"."+image.Ext)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// copyObject copies a stored object to another key.
+func copyObject(ctx context.Context, fs storage.Storage, src, dst string) error {
+	r, object, err := fs.Get(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return fs.Put(ctx, dst, r, object.Size)
+}
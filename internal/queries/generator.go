@@ -0,0 +1,154 @@
+package queries
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/license"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// UpdateDigitalGenerator sets how the keys of a "generated" product are made.
+func (q *ProductQueries) UpdateDigitalGenerator(ctx context.Context, productID string, generator *models.DigitalGenerator) error {
+	query := `
+	INSERT INTO digital_generator (id, product_id, mode, pattern, charset, checksum) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (product_id) DO UPDATE SET mode = excluded.mode, pattern = excluded.pattern, charset = excluded.charset, checksum = excluded.checksum
+	`
+	_, err := q.DB.ExecContext(ctx, query, security.RandomString(), productID, generator.Mode, generator.Pattern, generator.Charset, generator.Checksum)
+	return err
+}
+
+// LicenseKey returns the key that signs the licenses of "generated" products.
+func (q *SettingQueries) LicenseKey(ctx context.Context) (ed25519.PrivateKey, error) {
+	return licenseKey(ctx, q.DB)
+}
+
+func licenseKey(ctx context.Context, db queryer) (ed25519.PrivateKey, error) {
+	var value string
+	if err := db.QueryRowContext(ctx, `SELECT value FROM setting WHERE key = 'license_seed'`).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrSettingNotFound
+		}
+		return nil, err
+	}
+
+	seed, err := hex.DecodeString(value)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid license seed")
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// generateKeys issues the keys of the "generated" products of a paid cart and stores them
+// as sold digital data of the chosen variant, so they are delivered and shown like the
+// preloaded ones. Products that already got their keys are skipped.
+func generateKeys(ctx context.Context, tx *sql.Tx, cartID string) error {
+	var email sql.NullString
+	var cartJSON string
+	if err := tx.QueryRowContext(ctx, `SELECT email, cart FROM cart WHERE id = ?`, cartID).Scan(&email, &cartJSON); err != nil {
+		return err
+	}
+
+	products := []models.CartProduct{}
+	if err := json.Unmarshal([]byte(cartJSON), &products); err != nil {
+		return err
+	}
+
+	for _, product := range deliveryLines(products) {
+		generator := models.DigitalGenerator{}
+		var held int
+		query := `
+		SELECT mode, pattern, charset, checksum, (
+			SELECT COUNT(*) FROM digital_data WHERE product_id = ? AND cart_id = ? AND ` + variantScope("digital_data") + `
+		)
+		FROM digital_generator
+		JOIN product ON product.id = digital_generator.product_id AND product.digital = 'generated'
+		WHERE digital_generator.product_id = ?
+		`
+		err := tx.QueryRowContext(ctx, query, product.ProductID, cartID, product.VariantID, product.ProductID).
+			Scan(&generator.Mode, &generator.Pattern, &generator.Charset, &generator.Checksum, &held)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return err
+		}
+		if held > 0 {
+			continue
+		}
+
+		keys := []string{}
+		switch generator.Mode {
+		case "signed":
+			key, err := licenseKey(ctx, tx)
+			if err != nil {
+				return err
+			}
+			signed, err := license.Sign(key, license.Claims{
+				Email:     email.String,
+				ProductID: product.ProductID,
+				Quantity:  keyQuantity(product),
+				Issued:    time.Now().Unix(),
+			})
+			if err != nil {
+				return err
+			}
+			keys = append(keys, signed)
+
+		default:
+			charset := generator.Charset
+			if charset == "" {
+				charset = license.DefaultCharset
+			}
+			for len(keys) < keyQuantity(product) {
+				key, err := uniqueKey(ctx, tx, product.ProductID, generator.Pattern, charset, generator.Checksum)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, key)
+			}
+		}
+
+		for _, key := range keys {
+			query := `INSERT INTO digital_data (id, product_id, variant_id, content, cart_id) VALUES (?, ?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, security.RandomString(), product.ProductID, nullString(product.VariantID), key, cartID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// uniqueKey generates a key that was not issued for the product before.
+// Short patterns run out of combinations, so the number of tries is limited.
+func uniqueKey(ctx context.Context, tx *sql.Tx, productID, pattern, charset string, checksum bool) (string, error) {
+	for i := 0; i < 10; i++ {
+		key, err := license.Generate(pattern, charset, checksum)
+		if err != nil {
+			return "", err
+		}
+
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM digital_data WHERE product_id = ? AND content = ?)`
+		if err := tx.QueryRowContext(ctx, query, productID, key).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("no unique key left for pattern %s", pattern)
+}
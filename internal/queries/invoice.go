@@ -0,0 +1,161 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// InvoiceQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to invoices of paid carts.
+type InvoiceQueries struct {
+	*sql.DB
+}
+
+// Invoice retrieves the invoice issued for a cart.
+func (q *InvoiceQueries) Invoice(ctx context.Context, cartID string) (*models.Invoice, error) {
+	query := `
+	SELECT id, cart_id, number, seller, buyer, items, amount_total, currency, strftime('%s', created)
+	FROM invoice
+	WHERE cart_id = ?
+	`
+
+	var seller, buyer, items string
+	invoice := &models.Invoice{}
+	err := q.DB.QueryRowContext(ctx, query, cartID).Scan(
+		&invoice.ID,
+		&invoice.CartID,
+		&invoice.Number,
+		&seller,
+		&buyer,
+		&items,
+		&invoice.AmountTotal,
+		&invoice.Currency,
+		&invoice.Created,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(seller), &invoice.Seller); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(buyer), &invoice.Buyer); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(items), &invoice.Items); err != nil {
+		return nil, err
+	}
+	invoice.Code = models.InvoiceCode(invoice.Seller.Prefix, invoice.Number)
+
+	return invoice, nil
+}
+
+// addInvoice issues an invoice for a paid cart inside the given transaction.
+// The seller details, buyer details and items are copied into the invoice,
+// so later changes to the settings or products do not alter it.
+// Nothing is done when the cart already has an invoice.
+func addInvoice(ctx context.Context, tx *sql.Tx, cartID string) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM invoice WHERE cart_id = ?)`, cartID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	var email sql.NullString
+	var cartJSON, billingJSON, currency string
+	var amountTotal int
+	query := `SELECT email, cart, billing, amount_total, currency FROM cart WHERE id = ?`
+	if err := tx.QueryRowContext(ctx, query, cartID).Scan(&email, &cartJSON, &billingJSON, &amountTotal, &currency); err != nil {
+		return err
+	}
+
+	buyer := models.InvoiceBuyer{Email: email.String}
+	if err := json.Unmarshal([]byte(billingJSON), &buyer.Billing); err != nil {
+		return err
+	}
+
+	seller := models.Seller{}
+	fields := map[string]*string{
+		"invoice_prefix":         &seller.Prefix,
+		"invoice_seller_name":    &seller.Name,
+		"invoice_seller_address": &seller.Address,
+		"invoice_seller_vat_id":  &seller.VatID,
+		"email":                  &seller.Email,
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT key, value FROM setting WHERE key IN ('invoice_prefix', 'invoice_seller_name', 'invoice_seller_address', 'invoice_seller_vat_id', 'email')`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		*fields[key] = value.String
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	cartProducts := []models.CartProduct{}
+	if err := json.Unmarshal([]byte(cartJSON), &cartProducts); err != nil {
+		return err
+	}
+
+	items := []models.InvoiceItem{}
+	for _, cartProduct := range cartProducts {
+		item := models.InvoiceItem{Quantity: cartProduct.Quantity}
+		query := `
+		SELECT CASE WHEN product_variant.id IS NULL THEN product.name ELSE product.name || ' - ' || product_variant.name END,
+			COALESCE(product_variant.amount, product.amount)
+		FROM product
+		LEFT JOIN product_variant ON product_variant.id = ? AND product_variant.product_id = product.id
+		WHERE product.id = ?
+		`
+		err := tx.QueryRowContext(ctx, query, cartProduct.VariantID, cartProduct.ProductID).Scan(&item.Name, &item.Amount)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			item.Name = cartProduct.ProductID
+		}
+		if cartProduct.Amount != nil {
+			item.Amount = *cartProduct.Amount
+		}
+		items = append(items, item)
+	}
+
+	byteSeller, err := json.Marshal(seller)
+	if err != nil {
+		return err
+	}
+	byteBuyer, err := json.Marshal(buyer)
+	if err != nil {
+		return err
+	}
+	byteItems, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO invoice (id, cart_id, number, seller, buyer, items, amount_total, currency)
+	VALUES (?, ?, (SELECT COALESCE(MAX(number), 0) + 1 FROM invoice), ?, ?, ?, ?, ?)
+	`
+	_, err = tx.ExecContext(ctx, query, security.RandomString(), cartID, string(byteSeller), string(byteBuyer), string(byteItems), amountTotal, currency)
+	return err
+}
@@ -0,0 +1,147 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// LicenseQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to the verification and activation of sold keys.
+type LicenseQueries struct {
+	*sql.DB
+}
+
+// VerifyLicense looks up a key sold through a cart. The key is valid while the cart is paid,
+// so refunded purchases are reported with their status. When the request asks for an activation
+// of a valid key, the instance is registered unless the activation limit of the product is reached,
+// an instance that is already registered is not counted twice.
+// It returns errors.ErrLicenseNotFound when the key was never sold and errors.ErrActivationLimit,
+// together with the license, when the activation is refused.
+func (q *LicenseQueries) VerifyLicense(ctx context.Context, request *models.LicenseVerify, ip string) (*models.License, error) {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT 
+		digital_data.id, 
+		digital_data.cart_id, 
+		product.id, 
+		product.name, 
+		product.activation_limit, 
+		COALESCE(cart.payment_status, ''), 
+		strftime('%s', cart.created),
+		(SELECT COUNT(*) FROM license_activation WHERE license_activation.digital_data_id = digital_data.id)
+	FROM digital_data
+	JOIN cart ON cart.id = digital_data.cart_id
+	JOIN product ON product.id = digital_data.product_id
+	WHERE digital_data.content = ? AND digital_data.reserved_until IS NULL AND (? = '' OR digital_data.product_id = ?)
+	ORDER BY cart.payment_status = 'paid' DESC, cart.created DESC
+	LIMIT 1
+	`
+
+	var dataID, cartID string
+	license := &models.License{}
+	err = tx.QueryRowContext(ctx, query, request.Key, request.ProductID, request.ProductID).Scan(
+		&dataID,
+		&cartID,
+		&license.ProductID,
+		&license.ProductName,
+		&license.ActivationLimit,
+		&license.Status,
+		&license.Purchased,
+		&license.Activations,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrLicenseNotFound
+		}
+		return nil, err
+	}
+	license.Valid = license.Status == litepay.PAID
+
+	if !request.Activate || !license.Valid {
+		return license, nil
+	}
+
+	var activated bool
+	query = `SELECT EXISTS(SELECT 1 FROM license_activation WHERE digital_data_id = ? AND instance = ?)`
+	if err := tx.QueryRowContext(ctx, query, dataID, request.Instance).Scan(&activated); err != nil {
+		return nil, err
+	}
+	if activated {
+		return license, nil
+	}
+
+	if license.ActivationLimit > 0 && license.Activations >= license.ActivationLimit {
+		return license, errors.ErrActivationLimit
+	}
+
+	query = `INSERT INTO license_activation (id, digital_data_id, cart_id, instance, ip) VALUES (?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, security.RandomString(), dataID, cartID, request.Instance, ip); err != nil {
+		return nil, err
+	}
+	license.Activations++
+
+	return license, tx.Commit()
+}
+
+// CartActivations retrieves the activations of the keys sold through a cart, newest first.
+func (q *LicenseQueries) CartActivations(ctx context.Context, cartID string) ([]models.LicenseActivation, error) {
+	activations := []models.LicenseActivation{}
+
+	query := `
+	SELECT license_activation.id, license_activation.cart_id, digital_data.product_id, digital_data.content, 
+		license_activation.instance, license_activation.ip, strftime('%s', license_activation.created)
+	FROM license_activation
+	JOIN digital_data ON digital_data.id = license_activation.digital_data_id
+	WHERE license_activation.cart_id = ?
+	ORDER BY license_activation.created DESC, license_activation.rowid DESC
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		activation := models.LicenseActivation{}
+		err := rows.Scan(
+			&activation.ID,
+			&activation.CartID,
+			&activation.ProductID,
+			&activation.Key,
+			&activation.Instance,
+			&activation.IP,
+			&activation.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activations = append(activations, activation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return activations, nil
+}
+
+// ResetActivations removes the activations of the keys sold through a cart
+// and returns the number of activations that were removed.
+func (q *LicenseQueries) ResetActivations(ctx context.Context, cartID string) (int64, error) {
+	result, err := q.DB.ExecContext(ctx, `DELETE FROM license_activation WHERE cart_id = ?`, cartID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,212 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/storage"
+)
+
+// MaintenanceQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries that remove or expire stale data.
+type MaintenanceQueries struct {
+	*sql.DB
+}
+
+// Cleanup purges expired sessions, moves unpaid carts older than retention days
+// to the expired status and releases the licence keys held by expired carts.
+// It returns the number of rows changed by every step.
+func (q *MaintenanceQueries) Cleanup(ctx context.Context, retention int) (*models.Cleanup, error) {
+	report := &models.Cleanup{}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM session WHERE expires <= ?`, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if report.Sessions, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, COALESCE(payment_status, '') FROM cart WHERE (payment_status IN (?, ?) OR payment_status IS NULL) AND created < datetime('now', ?)`
+	rows, err := tx.QueryContext(ctx, query, litepay.NEW, litepay.UNPAID, fmt.Sprintf("-%d days", retention))
+	if err != nil {
+		return nil, err
+	}
+
+	stale := map[string]string{}
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stale[id] = status
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	for id, status := range stale {
+		if _, err := tx.ExecContext(ctx, `UPDATE cart SET payment_status = ?, updated = datetime('now') WHERE id = ?`, litepay.EXPIRED, id); err != nil {
+			return nil, err
+		}
+
+		err := addCartEvent(ctx, tx, &models.CartEvent{
+			CartID: id,
+			Event:  models.CartEventStatusChanged,
+			Actor:  models.CartActorReconciliationJob,
+			Payload: map[string]any{
+				"from": status,
+				"to":   litepay.EXPIRED,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	report.ExpiredCarts = int64(len(stale))
+
+	// only reservations are released, a key sold to a cart stays sold whatever the
+	// cart became later, keys of carts that no longer exist are freed on their own
+	query = `
+	UPDATE digital_data SET cart_id = NULL, reserved_until = NULL
+	WHERE cart_id IS NOT NULL
+		AND (reserved_until IS NOT NULL AND (cart_id IN (SELECT id FROM cart WHERE payment_status = ?) OR reserved_until <= datetime('now'))
+			OR cart_id NOT IN (SELECT id FROM cart))
+	`
+	res, err = tx.ExecContext(ctx, query, litepay.EXPIRED)
+	if err != nil {
+		return nil, err
+	}
+	if report.ReleasedKeys, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// CollectGarbage removes the stored files that no product image or digital file refers to.
+// Files changed within the grace period are kept, an upload is stored before its row is
+// inserted. Watermarked copies are kept while both their file and their cart exist.
+// With dryRun the orphans are only reported.
+func (q *MaintenanceQueries) CollectGarbage(ctx context.Context, grace time.Duration, dryRun bool) (*models.Garbage, error) {
+	report := &models.Garbage{Orphans: []models.Orphan{}, DryRun: dryRun}
+
+	fs, err := Storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the objects are listed before the rows are read, so a file added in between
+	// is either younger than the grace period or already has its row
+	objects := []storage.Object{}
+	for _, prefix := range []string{uploadPrefix, digitalPrefix} {
+		list, err := fs.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, list...)
+	}
+
+	used := map[string]bool{}
+	rows, err := q.DB.QueryContext(ctx, `SELECT name, ext FROM product_image`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, ext string
+		if err := rows.Scan(&name, &ext); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, suffix := range []string{"", "_sm", "_md"} {
+			used[UploadKey(name+suffix+"."+ext)] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	files := map[string]bool{}
+	rows, err = q.DB.QueryContext(ctx, `SELECT name, ext FROM digital_file`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, ext string
+		if err := rows.Scan(&name, &ext); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		used[DigitalKey(name, ext)] = true
+		files[name+"."+ext] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	carts := map[string]bool{}
+	rows, err = q.DB.QueryContext(ctx, `SELECT id FROM cart`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		carts[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	deadline := time.Now().Add(-grace)
+	for _, object := range objects {
+		if used[object.Key] || object.Modified.After(deadline) {
+			continue
+		}
+		if stamped, ok := strings.CutPrefix(object.Key, watermarkPrefix); ok {
+			cartID, file, _ := strings.Cut(stamped, "/")
+			if carts[cartID] && files[file] {
+				continue
+			}
+		}
+
+		report.Orphans = append(report.Orphans, models.Orphan{Key: object.Key, Size: object.Size, Modified: object.Modified.Unix()})
+		report.Size += object.Size
+		if dryRun {
+			continue
+		}
+		if err := fs.Delete(ctx, object.Key); err != nil {
+			return report, err
+		}
+		report.Removed++
+	}
+
+	return report, nil
+}
@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanupKeys(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+
+	// a paid cart moved to expired by the admin keeps its sold key, the reservation
+	// of an expired checkout and the key of a removed cart are released
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `
+		INSERT INTO cart (id, email, amount_total, currency, payment_status, cart) VALUES
+			('c00000000000001', 'buyer@mail.com', 100, 'USD', 'expired', '[]'),
+			('c00000000000002', 'buyer@mail.com', 100, 'USD', 'expired', '[]')
+	`)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `
+		INSERT INTO digital_data (id, product_id, content, cart_id, reserved_until) VALUES
+			('k00000000000001', 'p00000000000001', 'SOLD', 'c00000000000001', NULL),
+			('k00000000000002', 'p00000000000001', 'RESERVED', 'c00000000000002', datetime('now', '+1 hour')),
+			('k00000000000003', 'p00000000000001', 'ORPHAN', 'c00000000000099', NULL)
+	`)
+	require.NoError(t, err)
+
+	report, err := db.Cleanup(ctx, 30)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.ReleasedKeys)
+
+	var cartID string
+	require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COALESCE(cart_id, '') FROM digital_data WHERE id = 'k00000000000001'`).Scan(&cartID))
+	assert.Equal(t, "c00000000000001", cartID)
+}
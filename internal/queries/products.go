@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"os"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/shurco/litecart/internal/models"
 	"github.com/shurco/litecart/pkg/errors"
 	"github.com/shurco/litecart/pkg/security"
+	"github.com/shurco/litecart/pkg/strutil"
 )
 
 // ProductQueries is a struct that embeds a pointer to an sql.DB.
@@ -20,62 +23,156 @@ type ProductQueries struct {
 	*sql.DB
 }
 
-// ListProducts retrieves a list of products from the database.
-func (q *ProductQueries) ListProducts(ctx context.Context, private bool, idList ...models.CartProduct) (*models.Products, error) {
+// ListProducts retrieves a list of products from the database narrowed by the filter,
+// and by the products of a cart when idList is given.
+func (q *ProductQueries) ListProducts(ctx context.Context, private bool, filter models.ProductFilter, idList ...models.CartProduct) (*models.Products, error) {
 	currency, err := db.GetSettingByKey(ctx, "currency")
 	if err != nil {
 		return nil, err
 	}
 
 	products := &models.Products{
+		Page:     filter.Page,
+		Limit:    filter.Limit,
 		Currency: currency["currency"].Value.(string),
 	}
 
-	query := `
-			SELECT DISTINCT
-			  product.id,
-				product.name,
-				product.brief,
-				product.slug,
-				product.amount,
-				product.active,
-				product.digital,
-				EXISTS(SELECT 1 FROM digital_data WHERE digital_data.product_id = product.id AND digital_data.cart_id IS NULL) OR
-				EXISTS(SELECT 1 FROM digital_file WHERE digital_file.product_id = product.id) AS digital_filled,
-				(SELECT json_group_array(json_object('id', product_image.id, 'name', product_image.name, 'ext', product_image.ext)) as images FROM product_image WHERE product_id = product.id GROUP BY id LIMIT 1) as image,
-				strftime('%s', created)
-			FROM product
-		`
+	var params []any
+	var querySearch, queryCount string
+	columnSearch, columnSnippet := `0 AS fts_rowid, 0 AS rank`, `''`
+	if filter.Query != "" {
+		match := ftsQuery(filter.Query)
+		if match == "" {
+			return products, nil
+		}
 
-	queryPublic := ` 
-			LEFT JOIN digital_data ON digital_data.product_id = product.id
-			LEFT JOIN digital_file ON digital_file.product_id = product.id
-			WHERE (digital_data.content IS NOT NULL AND digital_data.cart_id IS NULL OR digital_file.orig_name IS NOT NULL) 
-			AND product.deleted = 0 AND product.active = 1
+		// name weighs the most, the product_id column is not indexed
+		params = append(params, match)
+		columnSearch = `search.rowid AS fts_rowid, search.rank`
+		querySearch = `
+			JOIN (
+				SELECT rowid, product_id, bm25(product_fts, 0, 10.0, 5.0, 1.0, 3.0) AS rank
+				FROM product_fts
+				WHERE product_fts MATCH ?
+			) AS search ON search.product_id = product.id
 		`
+		queryCount = ` JOIN (SELECT product_id FROM product_fts WHERE product_fts MATCH ?) AS search ON search.product_id = product.id`
+
+		// snippets are only cut for the products of the page
+		columnSnippet = `(SELECT snippet(product_fts, -1, char(1), char(2), '` + strutil.Ellipsis + `', 16) FROM product_fts WHERE product_fts MATCH ? AND rowid = product.fts_rowid)`
+	}
+
+	var conditions []string
+	if private {
+		params = append(params, filter.Trash)
+		conditions = append(conditions, `product.deleted = ?`)
+	} else {
+		conditions = append(conditions, `product.deleted = 0 AND product.active = 1 AND `+productFilled)
+	}
 
-	var params []any
-	var queryAddon string
 	if len(idList) > 0 {
 		for _, item := range idList {
 			params = append(params, item.ProductID)
 		}
-		queryAddon = fmt.Sprintf("AND product.id IN (%s)", strings.Repeat("?, ", len(idList)-1)+"?")
+		conditions = append(conditions, fmt.Sprintf("product.id IN (%s)", strings.Repeat("?, ", len(idList)-1)+"?"))
 	}
 
-	if !private {
-		query += queryPublic
+	if filter.Category != "" {
+		params = append(params, filter.Category)
+		conditions = append(conditions, `product.id IN (SELECT product_id FROM product_category WHERE category_id IN (`+categoryTree(private)+`))`)
+	}
+
+	var queryWhere string
+	if len(conditions) > 0 {
+		queryWhere = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Count total records
+	err = q.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM product`+queryCount+queryWhere, params...).Scan(&products.Total)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if products.Total == 0 {
+		return products, nil
+	}
+
+	limit, offset := -1, 0
+	if filter.Limit > 0 {
+		limit = filter.Limit
+		if filter.Page > 1 {
+			offset = (filter.Page - 1) * filter.Limit
+		}
+	}
+	params = append(params, limit, offset)
+	if filter.Query != "" {
+		params = append(params, params[0])
+	}
+
+	// the page is cut out first, images and the filled flag are only
+	// looked up for the products on it
+	queryOrder := productOrder(filter)
+	columnFilled := `0`
+	if private {
+		columnFilled = productFilled
+	}
+
+	columnImage := `(SELECT json_array(json_object('id', product_image.id, 'name', product_image.name, 'ext', product_image.ext)) FROM product_image WHERE product_image.product_id = product.id ORDER BY product_image.rowid LIMIT 1)`
+	if len(filter.Fields) > 0 && !slices.Contains(filter.Fields, "images") {
+		columnImage = `NULL`
 	}
 
-	rows, err := q.DB.QueryContext(ctx, query+queryAddon, params...)
+	query := `
+			WITH listed AS (
+				SELECT
+					product.id,
+					product.name,
+					product.brief,
+					product.slug,
+					product.amount,
+					` + productPrice + ` AS price,
+					` + productOnSale + ` AS on_sale,
+					product.sale_amount,
+					product.sale_start,
+					product.sale_end,
+					product.active,
+					product.digital,
+					product.created,
+					` + columnSearch + `
+				FROM product` + querySearch + queryWhere + `
+				ORDER BY ` + queryOrder + `
+				LIMIT ? OFFSET ?
+			)
+			SELECT
+				product.id,
+				product.name,
+				product.brief,
+				product.slug,
+				product.amount,
+				product.price,
+				product.on_sale,
+				product.sale_amount,
+				strftime('%s', product.sale_start),
+				strftime('%s', product.sale_end),
+				product.active,
+				product.digital,
+				` + columnFilled + ` AS digital_filled,
+				` + columnImage + ` AS image,
+				` + columnSnippet + ` AS snippet,
+				strftime('%s', product.created)
+			FROM listed AS product
+			ORDER BY ` + queryOrder
+
+	rows, err := q.DB.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var image, digitalType sql.NullString
-		var digitalFilled sql.NullBool
+		var image, digitalType, snippet sql.NullString
+		var digitalFilled, onSale sql.NullBool
+		var saleAmount, saleStart, saleEnd sql.NullInt64
 		product := models.Product{}
 		err := rows.Scan(
 			&product.ID,
@@ -83,20 +180,32 @@ func (q *ProductQueries) ListProducts(ctx context.Context, private bool, idList
 			&product.Brief,
 			&product.Slug,
 			&product.Amount,
+			&product.Price,
+			&onSale,
+			&saleAmount,
+			&saleStart,
+			&saleEnd,
 			&product.Active,
 			&digitalType,
 			&digitalFilled,
 			&image,
+			&snippet,
 			&product.Created,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		if image.Valid && image.String != `[{"id":null,"name":null,"ext":null}]` {
+		if image.Valid {
 			json.Unmarshal([]byte(image.String), &product.Images)
 		}
 
+		if snippet.String != "" {
+			product.Snippet = strutil.Highlight(snippet.String)
+		}
+
+		product.Sale = productSale(private, onSale.Bool, saleAmount, saleStart, saleEnd)
+
 		product.Digital.Type = digitalType.String
 		if private && digitalType.Valid {
 			product.Digital.Filled = digitalFilled.Bool
@@ -109,17 +218,140 @@ func (q *ProductQueries) ListProducts(ctx context.Context, private bool, idList
 		return nil, err
 	}
 
-	// Count total records
-	query = `SELECT COUNT(DISTINCT product.id) FROM product`
+	if len(filter.Fields) == 0 || slices.Contains(filter.Fields, "variants") {
+		if err := q.listVariants(ctx, private, products.Products); err != nil {
+			return nil, err
+		}
+	}
+
+	return products, nil
+}
+
+// listVariants fills in the variants of the listed products with a single query.
+func (q *ProductQueries) listVariants(ctx context.Context, private bool, products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	index := map[string]int{}
+	params := []any{}
+	for i, product := range products {
+		index[product.ID] = i
+		params = append(params, product.ID)
+	}
+
+	query := `
+			SELECT id, product_id, name, COALESCE(sku, ''), amount, sort, active, strftime('%s', created), strftime('%s', updated)
+			FROM product_variant
+			WHERE product_id IN (` + strings.Repeat("?, ", len(params)-1) + `?)`
 	if !private {
-		query += queryPublic
+		query += ` AND active = 1`
 	}
-	err = q.DB.QueryRowContext(ctx, query+queryAddon, params...).Scan(&products.Total)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
+	query += ` ORDER BY sort, rowid`
+
+	rows, err := q.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	return products, nil
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return err
+		}
+		i := index[variant.ProductID]
+		products[i].Variants = append(products[i].Variants, *variant)
+	}
+
+	return rows.Err()
+}
+
+// productFilled is true when a product has something left to deliver.
+const productFilled = `(
+	EXISTS(SELECT 1 FROM digital_data WHERE digital_data.product_id = product.id AND digital_data.content != '' AND ` + freeKey + `) OR
+	EXISTS(SELECT 1 FROM digital_file WHERE digital_file.product_id = product.id AND digital_file.active = 1) OR
+	EXISTS(SELECT 1 FROM digital_api WHERE digital_api.product_id = product.id) OR
+	EXISTS(SELECT 1 FROM digital_generator WHERE digital_generator.product_id = product.id) OR
+	product.digital = 'bundle' AND ` + bundleFilled + `
+)`
+
+// productOnSale is true while the scheduled sale of a product runs.
+const productOnSale = `(
+	product.sale_amount IS NOT NULL AND
+	(product.sale_start IS NULL OR product.sale_start <= datetime('now')) AND
+	(product.sale_end IS NULL OR product.sale_end > datetime('now'))
+)`
+
+// productPrice is the price a product is sold at right now.
+const productPrice = `(CASE WHEN ` + productOnSale + ` THEN product.sale_amount ELSE product.amount END)`
+
+// productSale returns the scheduled sale of a product. The admin sees every
+// sale, buyers only the running one so that upcoming prices stay hidden.
+func productSale(private, onSale bool, amount, start, end sql.NullInt64) *models.Sale {
+	if !amount.Valid || !private && !onSale {
+		return nil
+	}
+
+	return &models.Sale{
+		Amount: int(amount.Int64),
+		Start:  start.Int64,
+		End:    end.Int64,
+	}
+}
+
+// saleColumns returns the sale_amount, sale_start and sale_end values of a sale,
+// the missing ones are NULL.
+func saleColumns(sale *models.Sale) (amount, start, end sql.NullInt64) {
+	if sale == nil {
+		return
+	}
+	amount = sql.NullInt64{Int64: int64(sale.Amount), Valid: true}
+	start = sql.NullInt64{Int64: sale.Start, Valid: sale.Start > 0}
+	end = sql.NullInt64{Int64: sale.End, Valid: sale.End > 0}
+	return
+}
+
+// productOrder returns the ORDER BY clause of a product list. The newest
+// products come first unless a search ranks them by relevance, the id
+// breaks ties so that pages never overlap.
+func productOrder(filter models.ProductFilter) string {
+	column, order := "product.created", "DESC"
+	switch filter.Sort {
+	case "price":
+		// price is a column of the list, the amount while no sale runs
+		column, order = "price", "ASC"
+	case "name":
+		column, order = "product.name", "ASC"
+	case "":
+		// rank is a column of the search, not of the product table
+		if filter.Query != "" {
+			column, order = "rank", "ASC"
+		}
+	}
+
+	if filter.Order != "" {
+		order = strings.ToUpper(filter.Order)
+	}
+
+	return column + " " + order + ", product.id " + order
+}
+
+// ftsQuery turns the words of a search request into an FTS5 query that
+// matches the products containing all of them, every word as a prefix.
+func ftsQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 16 {
+		words = words[:16]
+	}
+
+	for i, word := range words {
+		words[i] = `"` + word + `"*`
+	}
+
+	return strings.Join(words, " ")
 }
 
 // Product retrieves a product by its ID, with the option to fetch private or public data.
@@ -134,12 +366,24 @@ func (q *ProductQueries) Product(ctx context.Context, private bool, id string) (
 				product.desc, 
 				product.slug, 
 				product.amount,
+				` + productPrice + `,
+				` + productOnSale + `,
+				product.sale_amount,
+				strftime('%s', product.sale_start),
+				strftime('%s', product.sale_end),
 				product.active,
 				product.metadata, 
 				product.attribute, 
 				product.digital,
 				product.seo, 
+				product.download_limit,
+				product.download_ip_limit,
+				product.download_ttl,
+				product.activation_limit,
+				product.watermark,
 				json_group_array(json_object('id', pi.id, 'name', pi.name, 'ext', pi.ext)) as images,
+				(SELECT json_group_array(category_id) FROM product_category WHERE product_id = product.id) as categories,
+				` + productRating + ` as rating,
 				strftime('%s', product.created), 
 				strftime('%s', product.updated)
 			FROM product 
@@ -148,14 +392,12 @@ func (q *ProductQueries) Product(ctx context.Context, private bool, id string) (
 	if private {
 		query += ` WHERE product.id = ?`
 	} else {
-		query += ` LEFT JOIN digital_data ON digital_data.product_id = product.id   
-										 LEFT JOIN digital_file ON digital_file.product_id = product.id 
-										 WHERE (digital_data.content IS NOT NULL AND digital_data.cart_id IS NULL OR digital_file.orig_name IS NOT NULL) AND
-										 product.slug = ? AND product.active = 1`
+		query += ` WHERE ` + productFilled + ` AND product.slug = ? AND product.active = 1 AND product.deleted = 0`
 	}
 
-	var images, metadata, attributes, digitalType, seo sql.NullString
-	var updated sql.NullInt64
+	var images, categories, rating, metadata, attributes, digitalType, seo sql.NullString
+	var updated, saleAmount, saleStart, saleEnd sql.NullInt64
+	var onSale bool
 
 	err := q.DB.QueryRowContext(ctx, query, id).
 		Scan(
@@ -165,12 +407,24 @@ func (q *ProductQueries) Product(ctx context.Context, private bool, id string) (
 			&product.Description,
 			&product.Slug,
 			&product.Amount,
+			&product.Price,
+			&onSale,
+			&saleAmount,
+			&saleStart,
+			&saleEnd,
 			&product.Active,
 			&metadata,
 			&attributes,
 			&digitalType,
 			&seo,
+			&product.DownloadLimit,
+			&product.DownloadIPLimit,
+			&product.DownloadTTL,
+			&product.ActivationLimit,
+			&product.Watermark,
 			&images,
+			&categories,
+			&rating,
 			&product.Created,
 			&updated,
 		)
@@ -193,16 +447,33 @@ func (q *ProductQueries) Product(ctx context.Context, private bool, id string) (
 		json.Unmarshal([]byte(attributes.String), &product.Attributes)
 	}
 
+	if categories.Valid {
+		json.Unmarshal([]byte(categories.String), &product.Categories)
+	}
+
 	if metadata.Valid {
 		json.Unmarshal([]byte(metadata.String), &product.Metadata)
 	}
 
+	if rating.Valid {
+		product.Rating = &models.Rating{}
+		json.Unmarshal([]byte(rating.String), product.Rating)
+		if product.Rating.Count == 0 {
+			product.Rating = nil
+		}
+	}
+
 	product.Digital.Type = digitalType.String
+	product.Sale = productSale(private, onSale, saleAmount, saleStart, saleEnd)
 
 	if seo.Valid {
 		json.Unmarshal([]byte(seo.String), &product.Seo)
 	}
 
+	if product.Variants, err = q.ProductVariants(ctx, private, product.ID); err != nil {
+		return nil, err
+	}
+
 	return product, nil
 }
 
@@ -220,13 +491,22 @@ func (q *ProductQueries) AddProduct(ctx context.Context, product *models.Product
 		return nil, err
 	}
 
+	saleAmount, saleStart, saleEnd := saleColumns(product.Sale)
+
 	query := `
 			INSERT INTO product (
-					id, name, amount, slug, metadata, attribute, brief, desc, digital, active
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE)
+					id, name, amount, slug, metadata, attribute, brief, desc, digital, active, download_limit, download_ip_limit, download_ttl, activation_limit, watermark,
+					sale_amount, sale_start, sale_end
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?, ?, ?, ?, ?, datetime(?, 'unixepoch'), datetime(?, 'unixepoch'))
 			RETURNING strftime('%s', created)
 	`
-	stmt, err := q.DB.PrepareContext(ctx, query)
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -235,11 +515,21 @@ func (q *ProductQueries) AddProduct(ctx context.Context, product *models.Product
 	err = stmt.QueryRowContext(ctx,
 		product.ID, product.Name, product.Amount, product.Slug,
 		metadata, attributes, product.Brief, product.Description, product.Digital.Type,
+		product.DownloadLimit, product.DownloadIPLimit, product.DownloadTTL, product.ActivationLimit, product.Watermark,
+		saleAmount, saleStart, saleEnd,
 	).Scan(&product.Created)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := setProductCategories(ctx, tx, product.ID, product.Categories); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return product, nil
 }
 
@@ -260,7 +550,26 @@ func (q *ProductQueries) UpdateProduct(ctx context.Context, product *models.Prod
 		return err
 	}
 
-	stmt, err := q.DB.PrepareContext(ctx, `
+	// the price of a variant replaces the one of the product, a sale of the product would be ignored
+	if product.Sale != nil {
+		var variants bool
+		if err := q.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM product_variant WHERE product_id = ?)`, product.ID).Scan(&variants); err != nil {
+			return err
+		}
+		if variants {
+			return errors.ErrVariantSale
+		}
+	}
+
+	saleAmount, saleStart, saleEnd := saleColumns(product.Sale)
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
 			UPDATE product SET 
 				name = ?, 
 				brief = ?, 
@@ -270,6 +579,14 @@ func (q *ProductQueries) UpdateProduct(ctx context.Context, product *models.Prod
 				metadata = ?, 
 				attribute = ?, 
 				seo = ?, 
+				download_limit = ?, 
+				download_ip_limit = ?, 
+				download_ttl = ?, 
+				activation_limit = ?, 
+				watermark = ?, 
+				sale_amount = ?, 
+				sale_start = datetime(?, 'unixepoch'), 
+				sale_end = datetime(?, 'unixepoch'), 
 				updated = datetime('now') 
 			WHERE id = ?
 		`)
@@ -287,36 +604,65 @@ func (q *ProductQueries) UpdateProduct(ctx context.Context, product *models.Prod
 		metadata,
 		attributes,
 		seo,
+		product.DownloadLimit,
+		product.DownloadIPLimit,
+		product.DownloadTTL,
+		product.ActivationLimit,
+		product.Watermark,
+		saleAmount,
+		saleStart,
+		saleEnd,
 		product.ID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := setProductCategories(ctx, tx, product.ID, product.Categories); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setProductCategories replaces the categories the product is listed in.
+func setProductCategories(ctx context.Context, tx *sql.Tx, productID string, categories []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_category WHERE product_id = ?`, productID); err != nil {
+		return err
+	}
+
+	for _, categoryID := range categories {
+		query := `INSERT OR IGNORE INTO product_category (product_id, category_id) SELECT ?, id FROM category WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, productID, categoryID); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// DeleteProduct removes a product from the database based on its ID.
+// DeleteProduct moves a product to the trash, it is hidden from the shop and kept with
+// its images and keys until it is restored or purged.
 func (q *ProductQueries) DeleteProduct(ctx context.Context, id string) error {
-	_, err := q.DB.ExecContext(ctx, `DELETE FROM product WHERE id = ?`, id)
-	return err
+	query := `UPDATE product SET deleted = 1, active = 0, updated = datetime('now') WHERE id = ? AND deleted = 0`
+	result, err := q.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrProductNotFound
+	}
+	return nil
 }
 
 // IsProduct checks if a product with the given slug exists and is active,
-// and also has associated digital data or file that meets certain conditions.
+// and also has something left to deliver.
 func (q *ProductQueries) IsProduct(ctx context.Context, slug string) bool {
 	var exists bool
 	query := `
 			SELECT EXISTS (
 				SELECT 1 FROM product 
-				WHERE product.slug = ? AND product.active = 1 AND (
-					EXISTS (
-						SELECT 1 FROM digital_data 
-						WHERE digital_data.product_id = product.id 
-						AND digital_data.content IS NOT NULL 
-						AND digital_data.cart_id IS NULL
-					) OR EXISTS (
-						SELECT 1 FROM digital_file 
-						WHERE digital_file.product_id = product.id 
-						AND digital_file.orig_name IS NOT NULL
-					)
-				)
+				WHERE product.slug = ? AND product.active = 1 AND product.deleted = 0 AND ` + productFilled + `
 			)
 	`
 	err := q.DB.QueryRowContext(ctx, query, slug).Scan(&exists)
@@ -326,7 +672,7 @@ func (q *ProductQueries) IsProduct(ctx context.Context, slug string) bool {
 // UpdateActive toggles the 'active' status of a product and updates its 'updated' timestamp.
 // It takes a context and an ID as arguments, and returns an error if the operation fails.
 func (q *ProductQueries) UpdateActive(ctx context.Context, id string) error {
-	query := `UPDATE product SET active = NOT active, updated = datetime('now') WHERE id = ?`
+	query := `UPDATE product SET active = NOT active, updated = datetime('now') WHERE id = ? AND deleted = 0`
 	_, err := q.DB.ExecContext(ctx, query, id)
 	return err
 }
@@ -399,15 +745,21 @@ func (q *ProductQueries) DeleteImage(ctx context.Context, productID, imageID str
 		return err
 	}
 
-	filePaths := []string{
-		fmt.Sprintf("./lc_uploads/%s.%s", name, ext),
-		fmt.Sprintf("./lc_uploads/%s_sm.%s", name, ext),
+	fs, err := Storage(ctx)
+	if err != nil {
+		return err
+	}
+
+	fileKeys := []string{
+		UploadKey(fmt.Sprintf("%s.%s", name, ext)),
+		UploadKey(fmt.Sprintf("%s_sm.%s", name, ext)),
+		UploadKey(fmt.Sprintf("%s_md.%s", name, ext)),
 	}
 
 	var removeErrors []error
-	for _, filePath := range filePaths {
-		if err := os.Remove(filePath); err != nil {
-			removeErrors = append(removeErrors, fmt.Errorf("failed to remove file %s: %w", filePath, err))
+	for _, fileKey := range fileKeys {
+		if err := fs.Delete(ctx, fileKey); err != nil {
+			removeErrors = append(removeErrors, fmt.Errorf("failed to remove file %s: %w", fileKey, err))
 		}
 	}
 
@@ -429,12 +781,13 @@ func (q *ProductQueries) ProductDigital(ctx context.Context, productID string) (
 	query := `
 			SELECT 
 					p.digital,
-					df.id, df.name, df.ext,
-					dd.id, dd.content, dd.cart_id
+					df.id, df.name, df.ext, df.orig_name, df.lineage_id, df.version, df.changelog, df.active, df.variant_id,
+					dd.id, dd.content, dd.cart_id, dd.variant_id
 			FROM product p
 			LEFT JOIN digital_file df ON p.id = df.product_id
 			LEFT JOIN digital_data dd ON p.id = dd.product_id
 			WHERE p.id = ?
+			ORDER BY df.rowid, dd.rowid
 	`
 
 	rows, err := q.DB.QueryContext(ctx, query, productID)
@@ -445,13 +798,14 @@ func (q *ProductQueries) ProductDigital(ctx context.Context, productID string) (
 
 	var digitalType sql.NullString
 	for rows.Next() {
-		var fileID, fileName, fileExt sql.NullString
-		var dataID, dataContent, cartID sql.NullString
+		var fileID, fileName, fileExt, fileOrigName, lineageID, version, changelog, fileVariantID sql.NullString
+		var active sql.NullBool
+		var dataID, dataContent, cartID, dataVariantID sql.NullString
 
 		err := rows.Scan(
 			&digitalType,
-			&fileID, &fileName, &fileExt,
-			&dataID, &dataContent, &cartID,
+			&fileID, &fileName, &fileExt, &fileOrigName, &lineageID, &version, &changelog, &active, &fileVariantID,
+			&dataID, &dataContent, &cartID, &dataVariantID,
 		)
 		if err != nil {
 			return nil, err
@@ -463,17 +817,24 @@ func (q *ProductQueries) ProductDigital(ctx context.Context, productID string) (
 
 		if fileID.Valid {
 			file := models.File{
-				ID:   fileID.String,
-				Name: fileName.String,
-				Ext:  fileExt.String,
+				ID:        fileID.String,
+				Name:      fileName.String,
+				Ext:       fileExt.String,
+				OrigName:  fileOrigName.String,
+				LineageID: lineageID.String,
+				Version:   version.String,
+				Changelog: changelog.String,
+				Active:    active.Bool,
+				VariantID: fileVariantID.String,
 			}
 			digital.Files = append(digital.Files, file)
 		}
 		if dataID.Valid {
 			data := models.Data{
-				ID:      dataID.String,
-				Content: dataContent.String,
-				CartID:  cartID.String,
+				ID:        dataID.String,
+				Content:   dataContent.String,
+				CartID:    cartID.String,
+				VariantID: dataVariantID.String,
 			}
 			digital.Data = append(digital.Data, data)
 		}
@@ -483,35 +844,158 @@ func (q *ProductQueries) ProductDigital(ctx context.Context, productID string) (
 		return nil, err
 	}
 
+	if digital.Type == "api" {
+		api := &models.DigitalAPI{}
+		err := q.DB.QueryRowContext(ctx, `SELECT url, secret FROM digital_api WHERE product_id = ?`, productID).Scan(&api.URL, &api.Secret)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			digital.API = api
+		}
+	}
+
+	if digital.Type == "generated" {
+		generator := &models.DigitalGenerator{}
+		query := `SELECT mode, pattern, charset, checksum FROM digital_generator WHERE product_id = ?`
+		err := q.DB.QueryRowContext(ctx, query, productID).Scan(&generator.Mode, &generator.Pattern, &generator.Charset, &generator.Checksum)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			digital.Generator = generator
+		}
+	}
+
+	if digital.Type == "bundle" {
+		bundle, err := q.productBundle(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		digital.Bundle = bundle
+	}
+
 	return digital, nil
 }
 
-// AddDigitalFile associates a digital file with a product in the database.
-func (q *ProductQueries) AddDigitalFile(ctx context.Context, productID, fileUUID, fileExt, origName string) (*models.File, error) {
+// UpdateDigitalAPI sets the endpoint that delivers an "api" product.
+// A random secret is generated when none is given.
+func (q *ProductQueries) UpdateDigitalAPI(ctx context.Context, productID string, api *models.DigitalAPI) error {
+	if api.Secret == "" {
+		api.Secret = security.RandomString() + security.RandomString()
+	}
+
+	query := `
+	INSERT INTO digital_api (id, product_id, url, secret) VALUES (?, ?, ?, ?)
+	ON CONFLICT (product_id) DO UPDATE SET url = excluded.url, secret = excluded.secret
+	`
+	_, err := q.DB.ExecContext(ctx, query, security.RandomString(), productID, api.URL, api.Secret)
+	return err
+}
+
+// AddDigitalFile associates a digital file with a product, or one of its variants, in the database.
+// When replaceID is set the file becomes the active version of the lineage of
+// that file and takes over its variant, the previous versions are kept but no longer delivered.
+func (q *ProductQueries) AddDigitalFile(ctx context.Context, productID, variantID, fileUUID, fileExt, origName, replaceID string) (*models.File, error) {
 	file := &models.File{
-		ID:   security.RandomString(),
-		Name: fileUUID,
-		Ext:  fileExt,
+		ID:        security.RandomString(),
+		Name:      fileUUID,
+		Ext:       fileExt,
+		OrigName:  origName,
+		Active:    true,
+		VariantID: variantID,
 	}
+	file.LineageID = file.ID
 
-	query := `INSERT INTO digital_file (id, product_id, name, ext, orig_name) VALUES (?, ?, ?, ?, ?)`
-	_, err := q.DB.ExecContext(ctx, query, file.ID, productID, file.Name, file.Ext, origName)
+	tx, err := q.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return file, nil
+	if err := hasVariant(ctx, tx, productID, variantID); err != nil {
+		return nil, err
+	}
+
+	if replaceID != "" {
+		var replaced sql.NullString
+		query := `SELECT lineage_id, variant_id FROM digital_file WHERE id = ? AND product_id = ?`
+		if err := tx.QueryRowContext(ctx, query, replaceID, productID).Scan(&file.LineageID, &replaced); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.ErrNotFound
+			}
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE digital_file SET active = 0 WHERE lineage_id = ?`, file.LineageID); err != nil {
+			return nil, err
+		}
+		file.VariantID = replaced.String
+	}
+
+	query := `INSERT INTO digital_file (id, product_id, variant_id, name, ext, orig_name, lineage_id, created) VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`
+	_, err = tx.ExecContext(ctx, query, file.ID, productID, nullString(file.VariantID), file.Name, file.Ext, origName, file.LineageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, tx.Commit()
 }
 
-// AddDigitalData adds a new digital data record associated with a product.
-func (q *ProductQueries) AddDigitalData(ctx context.Context, productID, content string) (*models.Data, error) {
+// UpdateDigitalFile sets the version label, changelog and active flag of a digital file.
+// Activating a file deactivates the other versions of its lineage. The active version
+// can not be deactivated, past buyers would be left without a file, so errors.ErrActiveVersion
+// is returned and another version has to be activated instead.
+func (q *ProductQueries) UpdateDigitalFile(ctx context.Context, productID, fileID string, version *models.FileVersion) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lineageID string
+	var active bool
+	query := `SELECT lineage_id, active FROM digital_file WHERE id = ? AND product_id = ?`
+	if err := tx.QueryRowContext(ctx, query, fileID, productID).Scan(&lineageID, &active); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrNotFound
+		}
+		return err
+	}
+
+	if version.Active != nil {
+		if active && !*version.Active {
+			return errors.ErrActiveVersion
+		}
+		if *version.Active {
+			if _, err := tx.ExecContext(ctx, `UPDATE digital_file SET active = 0 WHERE lineage_id = ? AND id != ?`, lineageID, fileID); err != nil {
+				return err
+			}
+		}
+		active = *version.Active
+	}
+
+	query = `UPDATE digital_file SET version = ?, changelog = ?, active = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, version.Version, version.Changelog, active, fileID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddDigitalData adds a new digital data record associated with a product, or one of its variants.
+func (q *ProductQueries) AddDigitalData(ctx context.Context, productID, variantID, content string) (*models.Data, error) {
 	file := &models.Data{
-		ID:      security.RandomString(),
-		Content: content,
+		ID:        security.RandomString(),
+		Content:   content,
+		VariantID: variantID,
+	}
+
+	if err := hasVariant(ctx, q.DB, productID, variantID); err != nil {
+		return nil, err
 	}
 
-	query := `INSERT INTO digital_data (id, product_id, content) VALUES (?, ?, ?)`
-	_, err := q.DB.ExecContext(ctx, query, file.ID, productID, file.Content)
+	query := `INSERT INTO digital_data (id, product_id, variant_id, content) VALUES (?, ?, ?, ?)`
+	_, err := q.DB.ExecContext(ctx, query, file.ID, productID, nullString(variantID), file.Content)
 	if err != nil {
 		return nil, err
 	}
@@ -519,6 +1003,121 @@ func (q *ProductQueries) AddDigitalData(ctx context.Context, productID, content
 	return file, nil
 }
 
+// ImportDigitalData inserts a batch of digital data records of a product, or one of its variants, in one transaction.
+// Empty lines are skipped, lines that repeat an existing key or an earlier line are reported
+// as duplicates and lines that are too long or not valid UTF-8 are reported as invalid.
+func (q *ProductQueries) ImportDigitalData(ctx context.Context, productID, variantID string, lines []string) (*models.DigitalImport, error) {
+	report := &models.DigitalImport{
+		Duplicate: []int{},
+		Invalid:   []int{},
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var digitalType sql.NullString
+	if err := tx.QueryRowContext(ctx, `SELECT digital FROM product WHERE id = ?`, productID).Scan(&digitalType); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrProductNotFound
+		}
+		return nil, err
+	}
+	if digitalType.String != "data" {
+		return nil, errors.ErrDigitalNotData
+	}
+
+	if err := hasVariant(ctx, tx, productID, variantID); err != nil {
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+	rows, err := tx.QueryContext(ctx, `SELECT content FROM digital_data WHERE product_id = ?`, productID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[content] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO digital_data (id, product_id, variant_id, content) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for i, line := range lines {
+		key := strings.TrimSpace(line)
+		switch {
+		case key == "":
+			continue
+		case len(key) > 254 || !utf8.ValidString(key):
+			report.Invalid = append(report.Invalid, i+1)
+			continue
+		case existing[key]:
+			report.Duplicate = append(report.Duplicate, i+1)
+			continue
+		}
+
+		if _, err := stmt.ExecContext(ctx, security.RandomString(), productID, nullString(variantID), key); err != nil {
+			return nil, err
+		}
+		existing[key] = true
+		report.Inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ExportDigitalData retrieves the digital data records of a product.
+// The assigned flag selects the keys that belong to a cart, nil selects all of them.
+func (q *ProductQueries) ExportDigitalData(ctx context.Context, productID string, assigned *bool) ([]models.Data, error) {
+	// freeKey is NULL rather than false for a sold key, which has no reservation
+	free := `COALESCE(` + freeKey + `, FALSE)`
+	query := `SELECT id, content, CASE WHEN ` + free + ` THEN '' ELSE cart_id END FROM digital_data WHERE product_id = ? AND content != ''`
+	if assigned != nil {
+		if *assigned {
+			query += ` AND NOT ` + free
+		} else {
+			query += ` AND ` + free
+		}
+	}
+	query += ` ORDER BY rowid`
+
+	rows, err := q.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.Data{}
+	for rows.Next() {
+		key := models.Data{}
+		if err := rows.Scan(&key.ID, &key.Content, &key.CartID); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
 // UpdateDigital updates the content of a digital data record in the database.
 func (q *ProductQueries) UpdateDigital(ctx context.Context, digital *models.Data) error {
 	query := `UPDATE digital_data SET content = ? WHERE id = ?`
@@ -528,16 +1127,16 @@ func (q *ProductQueries) UpdateDigital(ctx context.Context, digital *models.Data
 
 func (q *ProductQueries) DeleteDigital(ctx context.Context, productID, digitalID string) error {
 	var digitalType string
-	var name, ext sql.NullString
+	var name, ext, lineageID sql.NullString
 
 	query := `
-				SELECT p.digital, df.name, df.ext
+				SELECT p.digital, df.name, df.ext, df.lineage_id
 				FROM product p
 				LEFT JOIN digital_file df ON df.id = ? AND df.product_id = p.id
 				WHERE p.id = ?
 		`
 
-	err := q.DB.QueryRowContext(ctx, query, digitalID, productID).Scan(&digitalType, &name, &ext)
+	err := q.DB.QueryRowContext(ctx, query, digitalID, productID).Scan(&digitalType, &name, &ext, &lineageID)
 	if err != nil {
 		return err
 	}
@@ -549,9 +1148,35 @@ func (q *ProductQueries) DeleteDigital(ctx context.Context, productID, digitalID
 			return fmt.Errorf("deleting from digital_file: %w", err)
 		}
 
-		filePath := fmt.Sprintf("./lc_digitals/%s.%s", name.String, ext.String)
-		if err := os.Remove(filePath); err != nil {
-			return fmt.Errorf("failed to remove file %s: %w", filePath, err)
+		// the latest remaining version takes over when the active one is deleted
+		query = `
+				UPDATE digital_file SET active = 1
+				WHERE id = (SELECT id FROM digital_file WHERE lineage_id = ? ORDER BY created DESC, rowid DESC LIMIT 1)
+					AND NOT EXISTS (SELECT 1 FROM digital_file WHERE lineage_id = ? AND active = 1)
+		`
+		if _, err := q.DB.ExecContext(ctx, query, lineageID.String, lineageID.String); err != nil {
+			return fmt.Errorf("activating the previous version: %w", err)
+		}
+
+		fs, err := Storage(ctx)
+		if err != nil {
+			return err
+		}
+
+		fileKey := DigitalKey(name.String, ext.String)
+		if err := fs.Delete(ctx, fileKey); err != nil {
+			return fmt.Errorf("failed to remove file %s: %w", fileKey, err)
+		}
+
+		// watermarked copies made for buyers
+		stamped, err := fs.List(ctx, watermarkPrefix)
+		if err != nil {
+			return fmt.Errorf("listing watermarked files: %w", err)
+		}
+		for _, object := range stamped {
+			if strings.HasSuffix(object.Key, "/"+name.String+"."+ext.String) {
+				fs.Delete(ctx, object.Key)
+			}
 		}
 
 	case "data":
@@ -0,0 +1,321 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/migrations"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+// newTestProducts opens a fresh database in a temporary folder and fills it
+// with count products. Every fifth product has nothing to deliver and every
+// seventh one is inactive, neither of them is listed publicly.
+func newTestProducts(tb testing.TB, count int) (public int) {
+	tb.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(tb, err)
+	require.NoError(tb, os.Chdir(tb.TempDir()))
+	require.NoError(tb, os.MkdirAll("lc_base", 0o775))
+	require.NoError(tb, New(migrations.Embed()))
+	tb.Cleanup(func() {
+		db.ProductQueries.DB.Close()
+		os.Chdir(wd)
+	})
+
+	ctx := context.Background()
+	tx, err := db.ProductQueries.DB.BeginTx(ctx, nil)
+	require.NoError(tb, err)
+	defer tx.Rollback()
+
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("p%014d", i)
+		active := i%7 != 0
+		_, err := tx.ExecContext(ctx, `INSERT INTO product (id, name, desc, brief, slug, amount, digital, active, created) VALUES (?, ?, ?, ?, ?, ?, 'api', ?, datetime('now', ?))`,
+			id, fmt.Sprintf("Product %d", i), "mountain trail guide", "brief", fmt.Sprintf("product-%d", i), 100+i%50*10, active, fmt.Sprintf("-%d minutes", i))
+		require.NoError(tb, err)
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO product_image (id, product_id, name, ext, orig_name) VALUES (?, ?, ?, 'png', 'image.png')`,
+			fmt.Sprintf("i%014d", i), id, fmt.Sprintf("image-%d", i))
+		require.NoError(tb, err)
+
+		if i%5 == 0 {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO digital_api (id, product_id, url, secret) VALUES (?, ?, 'https://example.com', 'secret')`,
+			fmt.Sprintf("a%014d", i), id)
+		require.NoError(tb, err)
+
+		if active {
+			public++
+		}
+	}
+	require.NoError(tb, tx.Commit())
+
+	return public
+}
+
+func TestListProducts(t *testing.T) {
+	const count = 120
+	public := newTestProducts(t, count)
+	ctx := context.Background()
+
+	t.Run("total", func(t *testing.T) {
+		products, err := db.ListProducts(ctx, false, models.ProductFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, public, products.Total)
+		assert.Len(t, products.Products, 10)
+		assert.Len(t, products.Products[0].Images, 1)
+
+		products, err = db.ListProducts(ctx, true, models.ProductFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, count, products.Total)
+	})
+
+	t.Run("newest first", func(t *testing.T) {
+		products, err := db.ListProducts(ctx, true, models.ProductFilter{Page: 1, Limit: 3})
+		require.NoError(t, err)
+		ids := []string{}
+		for _, product := range products.Products {
+			ids = append(ids, product.ID)
+		}
+		assert.Equal(t, []string{"p00000000000000", "p00000000000001", "p00000000000002"}, ids)
+	})
+
+	t.Run("stable pages", func(t *testing.T) {
+		for _, order := range []string{"asc", "desc"} {
+			seen := map[string]bool{}
+			last := -1
+			for page := 1; ; page++ {
+				products, err := db.ListProducts(ctx, false, models.ProductFilter{Sort: "price", Order: order, Page: page, Limit: 7})
+				require.NoError(t, err)
+				if len(products.Products) == 0 {
+					break
+				}
+				for _, product := range products.Products {
+					assert.False(t, seen[product.ID], product.ID)
+					seen[product.ID] = true
+					if last >= 0 && order == "asc" {
+						assert.GreaterOrEqual(t, product.Amount, last)
+					}
+					if last >= 0 && order == "desc" {
+						assert.LessOrEqual(t, product.Amount, last)
+					}
+					last = product.Amount
+				}
+			}
+			assert.Len(t, seen, public)
+		}
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		products, err := db.ListProducts(ctx, false, models.ProductFilter{})
+		require.NoError(t, err)
+		assert.Len(t, products.Products, public)
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		fields := []string{"id", "amount"}
+		products, err := db.ListProducts(ctx, false, models.ProductFilter{Page: 1, Limit: 2, Fields: fields})
+		require.NoError(t, err)
+		assert.Empty(t, products.Products[0].Images)
+
+		selected, err := products.Select(fields)
+		require.NoError(t, err)
+		assert.Equal(t, public, selected["total"])
+		assert.Equal(t, []map[string]any{
+			{"id": "p00000000000001", "amount": float64(110)},
+			{"id": "p00000000000002", "amount": float64(120)},
+		}, selected["products"])
+	})
+
+	t.Run("search", func(t *testing.T) {
+		products, err := db.ListProducts(ctx, false, models.ProductFilter{Query: "mountain", Sort: "name", Page: 2, Limit: 5})
+		require.NoError(t, err)
+		assert.Equal(t, public, products.Total)
+		assert.Len(t, products.Products, 5)
+		assert.NotEmpty(t, products.Products[0].Snippet)
+	})
+}
+
+func BenchmarkListProducts(b *testing.B) {
+	newTestProducts(b, 10000)
+	ctx := context.Background()
+
+	benchmarks := []struct {
+		name    string
+		private bool
+		filter  models.ProductFilter
+	}{
+		{"public first page", false, models.ProductFilter{Page: 1, Limit: 20}},
+		{"public deep page by price", false, models.ProductFilter{Sort: "price", Page: 300, Limit: 20}},
+		{"private first page", true, models.ProductFilter{Page: 1, Limit: 20}},
+		{"public search", false, models.ProductFilter{Query: "mountain", Page: 1, Limit: 20}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ListProducts(ctx, bm.private, bm.filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestProductSale(t *testing.T) {
+	newTestProducts(t, 4)
+	ctx := context.Background()
+
+	// product 1 is on sale, the sale of product 2 has not started and the one of product 3 is over
+	sales := map[string]*models.Sale{
+		"p00000000000001": {Amount: 50, Start: time.Now().Add(-time.Hour).Unix()},
+		"p00000000000002": {Amount: 60, Start: time.Now().Add(time.Hour).Unix()},
+		"p00000000000003": {Amount: 70, End: time.Now().Add(-time.Hour).Unix()},
+	}
+	for id, sale := range sales {
+		product, err := db.Product(ctx, true, id)
+		require.NoError(t, err)
+		product.Sale = sale
+		require.NoError(t, db.UpdateProduct(ctx, product))
+	}
+
+	products, err := db.ListProducts(ctx, false, models.ProductFilter{Sort: "price"})
+	require.NoError(t, err)
+	require.Len(t, products.Products, 3)
+
+	prices := map[string]int{}
+	for _, product := range products.Products {
+		prices[product.ID] = product.Price
+	}
+	assert.Equal(t, map[string]int{"p00000000000001": 50, "p00000000000002": 120, "p00000000000003": 130}, prices)
+	assert.Equal(t, "p00000000000001", products.Products[0].ID)
+	assert.Equal(t, 110, products.Products[0].Amount)
+	assert.Equal(t, sales["p00000000000001"].Start, products.Products[0].Sale.Start)
+	assert.Nil(t, products.Products[1].Sale)
+
+	product, err := db.Product(ctx, true, "p00000000000002")
+	require.NoError(t, err)
+	assert.Equal(t, 120, product.Price)
+	assert.Equal(t, sales["p00000000000002"], product.Sale)
+
+	product.Sale = nil
+	require.NoError(t, db.UpdateProduct(ctx, product))
+	product, err = db.Product(ctx, true, "p00000000000002")
+	require.NoError(t, err)
+	assert.Nil(t, product.Sale)
+}
+
+func TestDigitalDataExport(t *testing.T) {
+	newTestProducts(t, 3)
+	ctx := context.Background()
+	const productID = "p00000000000001"
+
+	_, err := db.ImportDigitalData(ctx, productID, "", []string{"KEY-1"})
+	assert.Equal(t, errors.ErrDigitalNotData, err)
+
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'data' WHERE id = ?`, productID)
+	require.NoError(t, err)
+	report, err := db.ImportDigitalData(ctx, productID, "", []string{"KEY-1", "KEY-2", "KEY-3"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Inserted)
+
+	// one key is sold and one is held by a reservation that has lapsed
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE digital_data SET cart_id = 'c00000000000001' WHERE content = 'KEY-1'`)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE digital_data SET cart_id = 'c00000000000002', reserved_until = datetime('now', '-1 hour') WHERE content = 'KEY-2'`)
+	require.NoError(t, err)
+
+	contents := func(assigned *bool) map[string]string {
+		keys, err := db.ExportDigitalData(ctx, productID, assigned)
+		require.NoError(t, err)
+		listed := map[string]string{}
+		for _, key := range keys {
+			listed[key.Content] = key.CartID
+		}
+		return listed
+	}
+	assigned, free := true, false
+	assert.Equal(t, map[string]string{"KEY-1": "c00000000000001", "KEY-2": "", "KEY-3": ""}, contents(nil))
+	assert.Equal(t, map[string]string{"KEY-1": "c00000000000001"}, contents(&assigned))
+	assert.Equal(t, map[string]string{"KEY-2": "", "KEY-3": ""}, contents(&free))
+}
+
+func TestUpdateDigitalFile(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+	const productID = "p00000000000001"
+
+	query := `INSERT INTO digital_file (id, product_id, name, ext, orig_name, lineage_id, active) VALUES (?, ?, ?, 'zip', 'file.zip', 'f00000000000001', ?)`
+	_, err := db.ProductQueries.DB.ExecContext(ctx, query, "f00000000000001", productID, "file-1", true)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, query, "f00000000000002", productID, "file-2", false)
+	require.NoError(t, err)
+
+	activeFiles := func() []string {
+		rows, err := db.ProductQueries.DB.QueryContext(ctx, `SELECT id FROM digital_file WHERE lineage_id = 'f00000000000001' AND active = 1 ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		var idList []string
+		for rows.Next() {
+			var id string
+			require.NoError(t, rows.Scan(&id))
+			idList = append(idList, id)
+		}
+		return idList
+	}
+	yes, no := true, false
+
+	require.NoError(t, db.UpdateDigitalFile(ctx, productID, "f00000000000001", &models.FileVersion{Version: "1.1"}))
+	assert.Equal(t, []string{"f00000000000001"}, activeFiles())
+
+	assert.Equal(t, errors.ErrActiveVersion, db.UpdateDigitalFile(ctx, productID, "f00000000000001", &models.FileVersion{Version: "1.1", Active: &no}))
+	assert.Equal(t, []string{"f00000000000001"}, activeFiles())
+
+	require.NoError(t, db.UpdateDigitalFile(ctx, productID, "f00000000000002", &models.FileVersion{Version: "2.0", Active: &no}))
+	assert.Equal(t, []string{"f00000000000001"}, activeFiles())
+
+	require.NoError(t, db.UpdateDigitalFile(ctx, productID, "f00000000000002", &models.FileVersion{Version: "2.0", Active: &yes}))
+	assert.Equal(t, []string{"f00000000000002"}, activeFiles())
+
+	assert.Equal(t, errors.ErrNotFound, db.UpdateDigitalFile(ctx, "p00000000000000", "f00000000000002", &models.FileVersion{}))
+}
+
+func TestProductFilledKeys(t *testing.T) {
+	newTestProducts(t, 6)
+	ctx := context.Background()
+	const productID = "p00000000000005"
+
+	listed := func() bool {
+		products, err := db.ListProducts(ctx, false, models.ProductFilter{})
+		require.NoError(t, err)
+		for _, product := range products.Products {
+			if product.ID == productID {
+				return true
+			}
+		}
+		return false
+	}
+
+	// an empty key can not be sold, the product is listed once it has a real one
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'data' WHERE id = ?`, productID)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO digital_data (id, product_id, content) VALUES ('k00000000000001', ?, '')`, productID)
+	require.NoError(t, err)
+	assert.False(t, listed())
+	assert.Equal(t, errors.ErrOutOfStock, db.CheckStock(ctx, []models.CartProduct{{ProductID: productID, Quantity: 1}}))
+
+	_, err = db.ImportDigitalData(ctx, productID, "", []string{"KEY-1"})
+	require.NoError(t, err)
+	assert.True(t, listed())
+	assert.NoError(t, db.CheckStock(ctx, []models.CartProduct{{ProductID: productID, Quantity: 1}}))
+}
@@ -11,14 +11,23 @@ import (
 var db *Base
 
 // Define the structure 'Base' that aggregates various queries related to different modules like
-// settings, authentication, installation, pages, products, and cart management.
+// settings, authentication, installation, pages, categories, products, cart, customer management, invoices, downloads, deliveries, licenses, releases and maintenance.
 type Base struct {
 	SettingQueries
 	AuthQueries
 	InstallQueries
 	PageQueries
+	CategoryQueries
 	ProductQueries
 	CartQueries
+	CustomerQueries
+	MaintenanceQueries
+	InvoiceQueries
+	DownloadQueries
+	DeliveryQueries
+	LicenseQueries
+	ReleaseQueries
+	ReviewQueries
 }
 
 // New initializes the application's database and returns an error if any occurs during the process.
@@ -31,12 +40,21 @@ func New(embed embed.FS) (err error) {
 	}
 
 	db = &Base{
-		AuthQueries:    AuthQueries{DB: sqlite},
-		InstallQueries: InstallQueries{DB: sqlite},
-		SettingQueries: SettingQueries{DB: sqlite},
-		PageQueries:    PageQueries{DB: sqlite},
-		ProductQueries: ProductQueries{DB: sqlite},
-		CartQueries:    CartQueries{DB: sqlite},
+		AuthQueries:        AuthQueries{DB: sqlite},
+		InstallQueries:     InstallQueries{DB: sqlite},
+		SettingQueries:     SettingQueries{DB: sqlite},
+		PageQueries:        PageQueries{DB: sqlite},
+		CategoryQueries:    CategoryQueries{DB: sqlite},
+		ProductQueries:     ProductQueries{DB: sqlite},
+		CartQueries:        CartQueries{DB: sqlite},
+		CustomerQueries:    CustomerQueries{DB: sqlite},
+		MaintenanceQueries: MaintenanceQueries{DB: sqlite},
+		InvoiceQueries:     InvoiceQueries{DB: sqlite},
+		DownloadQueries:    DownloadQueries{DB: sqlite},
+		DeliveryQueries:    DeliveryQueries{DB: sqlite},
+		LicenseQueries:     LicenseQueries{DB: sqlite},
+		ReleaseQueries:     ReleaseQueries{DB: sqlite},
+		ReviewQueries:      ReviewQueries{DB: sqlite},
 	}
 	return
 }
@@ -0,0 +1,193 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// ReleaseQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to the letters about new versions of files.
+type ReleaseQueries struct {
+	*sql.DB
+}
+
+// QueueReleaseNotices queues a letter about the active file of a product for every
+// past paying buyer of that product, or of the variant the file belongs to, and
+// returns the number of letters queued.
+// Each email is notified once per file, through its latest paid cart, and
+// customers who unsubscribed are skipped.
+func (q *ReleaseQueries) QueueReleaseNotices(ctx context.Context, productID, fileID string) (int64, error) {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var active bool
+	var variantID sql.NullString
+	query := `SELECT active, variant_id FROM digital_file WHERE id = ? AND product_id = ?`
+	if err := tx.QueryRowContext(ctx, query, fileID, productID).Scan(&active, &variantID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.ErrNotFound
+		}
+		return 0, err
+	}
+	if !active {
+		return 0, errors.ErrNotFound
+	}
+
+	query = `
+	SELECT cart.id, cart.email
+	FROM cart
+	LEFT JOIN customer ON customer.id = cart.customer_id
+	WHERE cart.payment_status = ? 
+		AND COALESCE(cart.email, '') != '' 
+		AND COALESCE(customer.unsubscribed, 0) = 0
+		AND EXISTS (
+			SELECT 1 FROM json_tree(cart.cart)
+			WHERE json_tree.key = 'id' AND json_tree.value = ?
+				AND (? IS NULL OR json_extract(cart.cart, json_tree.path || '.variant_id') = ?)
+		)
+	ORDER BY cart.created DESC, cart.rowid DESC
+	`
+	rows, err := tx.QueryContext(ctx, query, litepay.PAID, productID, variantID, variantID)
+	if err != nil {
+		return 0, err
+	}
+
+	buyers := map[string]string{}
+	order := []string{}
+	for rows.Next() {
+		var cartID, email string
+		if err := rows.Scan(&cartID, &email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		email = strings.ToLower(strings.TrimSpace(email))
+		if _, ok := buyers[email]; ok {
+			continue
+		}
+		buyers[email] = cartID
+		order = append(order, email)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	var queued int64
+	query = `
+	INSERT INTO release_notice (id, product_id, file_id, cart_id, email) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (file_id, email) DO NOTHING
+	`
+	for _, email := range order {
+		result, err := tx.ExecContext(ctx, query, security.RandomString(), productID, fileID, buyers[email], email)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		queued += affected
+	}
+
+	return queued, tx.Commit()
+}
+
+// PendingReleaseNotices retrieves at most limit queued letters, oldest first.
+func (q *ReleaseQueries) PendingReleaseNotices(ctx context.Context, limit int) ([]models.ReleaseNotice, error) {
+	notices := []models.ReleaseNotice{}
+
+	query := `
+	SELECT id, product_id, file_id, cart_id, email, status
+	FROM release_notice
+	WHERE status = ?
+	ORDER BY created, rowid
+	LIMIT ?
+	`
+
+	rows, err := q.DB.QueryContext(ctx, query, models.ReleaseNoticePending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		notice := models.ReleaseNotice{}
+		if err := rows.Scan(&notice.ID, &notice.ProductID, &notice.FileID, &notice.CartID, &notice.Email, &notice.Status); err != nil {
+			return nil, err
+		}
+		notices = append(notices, notice)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notices, nil
+}
+
+// UpdateReleaseNotice records the result of sending a queued letter.
+func (q *ReleaseQueries) UpdateReleaseNotice(ctx context.Context, notice *models.ReleaseNotice) error {
+	query := `UPDATE release_notice SET status = ?, error = ?, sent = CASE WHEN ? = 'sent' THEN datetime('now') END WHERE id = ?`
+	_, err := q.DB.ExecContext(ctx, query, notice.Status, notice.Error, notice.Status, notice.ID)
+	return err
+}
+
+// ReleaseLetter builds the letter of a queued notice with a download link to the new version.
+func (q *ReleaseQueries) ReleaseLetter(ctx context.Context, notice *models.ReleaseNotice) (*models.MessageMail, error) {
+	var productName, version, changelog string
+	var downloadTTL int
+	query := `
+	SELECT product.name, product.download_ttl, digital_file.version, digital_file.changelog
+	FROM digital_file
+	JOIN product ON product.id = digital_file.product_id
+	WHERE digital_file.id = ?
+	`
+	if err := q.DB.QueryRowContext(ctx, query, notice.FileID).Scan(&productName, &downloadTTL, &version, &changelog); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	mailLetter, err := db.GetSettingByKey(ctx, "site_name", "mail_letter_release", "domain", "jwt_secret")
+	if err != nil {
+		return nil, err
+	}
+	letterTemplate := models.Letter{}
+	if err := json.Unmarshal([]byte(mailLetter["mail_letter_release"].Value.(string)), &letterTemplate); err != nil {
+		return nil, err
+	}
+
+	delivery, err := GetSettingByGroup[models.Delivery](ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := mailLetter["domain"].Value.(string)
+	secret := mailLetter["jwt_secret"].Value.(string)
+
+	mail := &models.MessageMail{
+		To:     notice.Email,
+		Letter: letterTemplate,
+		Data: map[string]string{
+			"Site_Name":    mailLetter["site_name"].Value.(string),
+			"Product_Name": productName,
+			"Version":      version,
+			"Changelog":    changelog,
+			"Download_URL": DownloadURL(domain, secret, notice.CartID, notice.FileID, downloadExpires(downloadTTL, delivery.LinkTTL)),
+		},
+	}
+
+	return mail, nil
+}
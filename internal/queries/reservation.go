@@ -0,0 +1,162 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+// A key is free when no cart holds it or when the reservation of the cart has lapsed.
+// Reserved keys have reserved_until set, sold keys keep cart_id with reserved_until NULL.
+const freeKey = `(digital_data.cart_id IS NULL OR digital_data.reserved_until <= datetime('now'))`
+
+// CheckStock verifies that enough free keys are left for every key product of a cart,
+// counting the keys of the chosen variant and the shared ones. It returns errors.ErrOutOfStock otherwise.
+func (q *CartQueries) CheckStock(ctx context.Context, products []models.CartProduct) error {
+	for _, product := range deliveryLines(products) {
+		var digitalType sql.NullString
+		var free int
+		query := `
+		SELECT product.digital, (
+			SELECT COUNT(*) FROM digital_data 
+			WHERE digital_data.product_id = product.id AND digital_data.content != '' AND ` + freeKey + ` AND ` + variantScope("digital_data") + `
+		)
+		FROM product
+		WHERE product.id = ?
+		`
+		if err := q.DB.QueryRowContext(ctx, query, product.VariantID, product.ProductID).Scan(&digitalType, &free); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.ErrProductNotFound
+			}
+			return err
+		}
+
+		if digitalType.String == "data" && free < keyQuantity(product) {
+			return errors.ErrOutOfStock
+		}
+	}
+	return nil
+}
+
+// reserveKeys holds free keys for every key product of a cart until the reservation ttl
+// in minutes runs out. It returns errors.ErrOutOfStock when a product has not enough keys left.
+func reserveKeys(ctx context.Context, tx *sql.Tx, cartID string, products []models.CartProduct, ttl int) error {
+	for _, product := range deliveryLines(products) {
+		var digitalType sql.NullString
+		if err := tx.QueryRowContext(ctx, `SELECT digital FROM product WHERE id = ?`, product.ProductID).Scan(&digitalType); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.ErrProductNotFound
+			}
+			return err
+		}
+		if digitalType.String != "data" {
+			continue
+		}
+
+		claimed, err := claimKeys(ctx, tx, cartID, product, keyQuantity(product), fmt.Sprintf("+%d minutes", ttl))
+		if err != nil {
+			return err
+		}
+		if claimed < int64(keyQuantity(product)) {
+			return errors.ErrOutOfStock
+		}
+	}
+	return nil
+}
+
+// confirmKeys makes the reservations of a paid cart permanent. Keys whose reservation
+// lapsed and went to another buyer are replaced with free ones while stock lasts.
+func confirmKeys(ctx context.Context, tx *sql.Tx, cartID string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE digital_data SET reserved_until = NULL WHERE cart_id = ?`, cartID); err != nil {
+		return err
+	}
+
+	var cartJSON string
+	if err := tx.QueryRowContext(ctx, `SELECT cart FROM cart WHERE id = ?`, cartID).Scan(&cartJSON); err != nil {
+		return err
+	}
+	products := []models.CartProduct{}
+	if err := json.Unmarshal([]byte(cartJSON), &products); err != nil {
+		return err
+	}
+
+	for _, product := range deliveryLines(products) {
+		var digitalType sql.NullString
+		var held int
+		query := `SELECT digital, (SELECT COUNT(*) FROM digital_data WHERE product_id = product.id AND cart_id = ? AND ` + variantScope("digital_data") + `) FROM product WHERE id = ?`
+		if err := tx.QueryRowContext(ctx, query, cartID, product.VariantID, product.ProductID).Scan(&digitalType, &held); err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return err
+		}
+
+		if digitalType.String == "data" && held < keyQuantity(product) {
+			if _, err := claimKeys(ctx, tx, cartID, product, keyQuantity(product)-held, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseKeys returns the keys reserved by a cart that will not be paid to the stock.
+func releaseKeys(ctx context.Context, exec execer, cartID string) (int64, error) {
+	res, err := exec.ExecContext(ctx, `UPDATE digital_data SET cart_id = NULL, reserved_until = NULL WHERE cart_id = ? AND reserved_until IS NOT NULL`, cartID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// claimKeys assigns up to count free keys of a cart product to a cart and returns how many were assigned.
+// The keys of the chosen variant go first, the shared ones after them. The keys are reserved until
+// the given datetime modifier, or sold when it is empty.
+func claimKeys(ctx context.Context, tx *sql.Tx, cartID string, product models.CartProduct, count int, modifier string) (int64, error) {
+	query := `
+	UPDATE digital_data SET cart_id = ?, reserved_until = CASE WHEN ? = '' THEN NULL ELSE datetime('now', ?) END
+	WHERE id IN (
+		SELECT id FROM digital_data 
+		WHERE digital_data.product_id = ? AND digital_data.content != '' AND ` + freeKey + ` AND ` + variantScope("digital_data") + `
+		ORDER BY digital_data.variant_id IS NULL
+		LIMIT ?
+	)
+	`
+	res, err := tx.ExecContext(ctx, query, cartID, modifier, modifier, product.ProductID, product.VariantID, count)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// soldKeys retrieves the keys of a cart product that were sold to a cart.
+func soldKeys(ctx context.Context, tx *sql.Tx, cartID string, product models.CartProduct) ([]models.Data, error) {
+	query := `SELECT id, content FROM digital_data WHERE cart_id = ? AND product_id = ? AND reserved_until IS NULL AND ` + variantScope("digital_data")
+	rows, err := tx.QueryContext(ctx, query, cartID, product.ProductID, product.VariantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.Data{}
+	for rows.Next() {
+		key := models.Data{CartID: cartID}
+		if err := rows.Scan(&key.ID, &key.Content); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// keyQuantity returns the number of keys a cart product needs.
+func keyQuantity(product models.CartProduct) int {
+	if product.Quantity < 1 {
+		return 1
+	}
+	return product.Quantity
+}
@@ -0,0 +1,328 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/litepay"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// ReviewQueries is a struct that embeds a pointer to an sql.DB.
+// It groups the queries related to the reviews buyers leave on products.
+type ReviewQueries struct {
+	*sql.DB
+}
+
+// productRating is the average and the number of the approved reviews of a product.
+const productRating = `(
+	SELECT json_object('value', ROUND(AVG(product_review.rating), 1), 'count', COUNT(*))
+	FROM product_review
+	WHERE product_review.product_id = product.id AND product_review.status = 'approved'
+)`
+
+// reviewProducts lists the products of the cart that can be reviewed, bundles are
+// reviewed as a whole and deleted products are left out.
+const reviewProducts = `
+	SELECT DISTINCT product.id, product.name, product.slug
+	FROM cart, json_each(cart.cart) AS item
+	JOIN product ON product.id = json_extract(item.value, '$.id') AND product.deleted = 0
+	WHERE cart.id = ? AND cart.payment_status = ?
+`
+
+// reviewWindow is the number of days after the review delay during which
+// a paid cart is still asked for a review, so turning the requests on does
+// not write to every buyer of the past.
+const reviewWindow = 7
+
+// ReviewCarts returns the paid carts whose buyers are due a letter asking for a review,
+// the delay is counted in days from the payment. Each cart is asked once.
+func (q *ReviewQueries) ReviewCarts(ctx context.Context, delay int) ([]models.Cart, error) {
+	carts := []models.Cart{}
+
+	query := `
+	SELECT cart.id, cart.email, cart.customer_id
+	FROM cart
+	JOIN customer ON customer.id = cart.customer_id
+	WHERE cart.payment_status = ?
+		AND COALESCE(cart.email, '') != ''
+		AND COALESCE(cart.updated, cart.created) <= datetime('now', ?)
+		AND COALESCE(cart.updated, cart.created) > datetime('now', ?)
+		AND customer.unsubscribed = 0
+		AND EXISTS (
+			SELECT 1 FROM json_each(cart.cart) AS item
+			JOIN product ON product.id = json_extract(item.value, '$.id') AND product.deleted = 0
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM cart_event
+			WHERE cart_event.cart_id = cart.id
+				AND cart_event.event = ?
+				AND json_extract(cart_event.payload, '$.letter') = 'mail_letter_review'
+		)
+	ORDER BY cart.created
+	`
+	rows, err := q.DB.QueryContext(ctx, query,
+		litepay.PAID,
+		fmt.Sprintf("-%d days", delay),
+		fmt.Sprintf("-%d days", delay+reviewWindow),
+		models.CartEventEmailSent,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cart := models.Cart{}
+		if err := rows.Scan(&cart.ID, &cart.Email, &cart.CustomerID); err != nil {
+			return nil, err
+		}
+		carts = append(carts, cart)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return carts, nil
+}
+
+// ReviewLetter builds the letter asking the buyer of a cart to review the products bought.
+func (q *ReviewQueries) ReviewLetter(ctx context.Context, cart *models.Cart, reviewURL, unsubscribeURL string) (*models.MessageMail, error) {
+	products, err := q.ReviewProducts(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(products))
+	for i, product := range products {
+		names[i] = product.Name
+	}
+
+	mailLetter, err := db.GetSettingByKey(ctx, "site_name", "mail_letter_review")
+	if err != nil {
+		return nil, err
+	}
+	letterTemplate := models.Letter{}
+	if err := json.Unmarshal([]byte(mailLetter["mail_letter_review"].Value.(string)), &letterTemplate); err != nil {
+		return nil, err
+	}
+
+	mail := &models.MessageMail{
+		To:     cart.Email,
+		Letter: letterTemplate,
+		Data: map[string]string{
+			"Site_Name":       mailLetter["site_name"].Value.(string),
+			"Product_Name":    strings.Join(names, ", "),
+			"Review_URL":      reviewURL,
+			"Unsubscribe_URL": unsubscribeURL,
+		},
+	}
+
+	return mail, nil
+}
+
+// ReviewProducts returns the products of a paid cart with the reviews left on them.
+func (q *ReviewQueries) ReviewProducts(ctx context.Context, cartID string) ([]models.ReviewProduct, error) {
+	products := []models.ReviewProduct{}
+
+	query := `
+	SELECT listed.id, listed.name, listed.slug,
+		product_review.id, product_review.name, product_review.rating, product_review.text, product_review.status,
+		strftime('%s', product_review.created)
+	FROM (` + reviewProducts + `) AS listed
+	LEFT JOIN product_review ON product_review.product_id = listed.id AND product_review.cart_id = ?
+	ORDER BY listed.name
+	`
+	rows, err := q.DB.QueryContext(ctx, query, cartID, litepay.PAID, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reviewID, name, text, status sql.NullString
+		var rating, created sql.NullInt64
+		product := models.ReviewProduct{}
+		if err := rows.Scan(&product.ProductID, &product.Name, &product.Slug, &reviewID, &name, &rating, &text, &status, &created); err != nil {
+			return nil, err
+		}
+		if reviewID.Valid {
+			product.Review = &models.Review{
+				ID:        reviewID.String,
+				ProductID: product.ProductID,
+				Name:      name.String,
+				Rating:    int(rating.Int64),
+				Text:      text.String,
+				Status:    models.ReviewStatus(status.String),
+				Created:   created.Int64,
+			}
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// AddReview saves the review of a product bought in a paid cart, a review left before
+// for the same product and cart is replaced and goes back to moderation.
+// errors.ErrReviewNotBought is returned when the cart does not hold the product.
+func (q *ReviewQueries) AddReview(ctx context.Context, cartID string, review *models.Review) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	query := `SELECT COALESCE(cart.email, '') FROM (` + reviewProducts + `) AS listed JOIN cart ON cart.id = ? WHERE listed.id = ?`
+	if err := tx.QueryRowContext(ctx, query, cartID, litepay.PAID, cartID, review.ProductID).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrReviewNotBought
+		}
+		return err
+	}
+
+	review.ID = security.RandomString()
+	review.Email = email
+	review.Status = models.ReviewPending
+
+	query = `
+	INSERT INTO product_review (id, product_id, cart_id, email, name, rating, text)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (product_id, cart_id) DO UPDATE SET
+		name = excluded.name, rating = excluded.rating, text = excluded.text,
+		status = 'pending', updated = datetime('now')
+	RETURNING id, strftime('%s', created)
+	`
+	err = tx.QueryRowContext(ctx, query, review.ID, review.ProductID, cartID, email, review.Name, review.Rating, review.Text).
+		Scan(&review.ID, &review.Created)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListReviews retrieves a page of reviews, newest first. The admin gets every review
+// matching the filter, otherwise only the approved reviews of the product are listed
+// with their rating and without the emails of the buyers.
+func (q *ReviewQueries) ListReviews(ctx context.Context, private bool, productID string, filter models.ReviewFilter) (*models.Reviews, error) {
+	reviews := &models.Reviews{
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+		Reviews: []models.Review{},
+	}
+
+	var params []any
+	var conditions []string
+	if !private {
+		filter.Status = models.ReviewApproved
+		conditions = append(conditions, `product.active = 1 AND product.deleted = 0`)
+	}
+	if productID != "" {
+		params = append(params, productID)
+		conditions = append(conditions, `product_review.product_id = ?`)
+	}
+	if filter.Status != "" {
+		params = append(params, filter.Status)
+		conditions = append(conditions, `product_review.status = ?`)
+	}
+
+	var queryWhere string
+	if len(conditions) > 0 {
+		queryWhere = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var average sql.NullFloat64
+	queryFrom := ` FROM product_review JOIN product ON product.id = product_review.product_id` + queryWhere
+	query := `SELECT COUNT(*), ROUND(AVG(product_review.rating), 1)` + queryFrom
+	if err := q.DB.QueryRowContext(ctx, query, params...).Scan(&reviews.Total, &average); err != nil {
+		return nil, err
+	}
+	if !private && reviews.Total > 0 {
+		reviews.Rating = &models.Rating{Value: average.Float64, Count: reviews.Total}
+	}
+	if reviews.Total == 0 {
+		return reviews, nil
+	}
+
+	limit, offset := -1, 0
+	if filter.Limit > 0 {
+		limit = filter.Limit
+		if filter.Page > 1 {
+			offset = (filter.Page - 1) * filter.Limit
+		}
+	}
+	params = append(params, limit, offset)
+
+	query = `
+	SELECT product_review.id, product_review.product_id, product.name, product_review.email, product_review.name,
+		product_review.rating, product_review.text, product_review.status, product_review.reply,
+		strftime('%s', product_review.created), strftime('%s', product_review.updated)` + queryFrom + `
+	ORDER BY product_review.created DESC, product_review.rowid DESC
+	LIMIT ? OFFSET ?
+	`
+	rows, err := q.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var updated sql.NullInt64
+		review := models.Review{}
+		err := rows.Scan(
+			&review.ID, &review.ProductID, &review.ProductName, &review.Email, &review.Name,
+			&review.Rating, &review.Text, &review.Status, &review.Reply,
+			&review.Created, &updated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		review.Updated = updated.Int64
+		if !private {
+			review.Email, review.ProductName, review.Status = "", "", ""
+		}
+		reviews.Reviews = append(reviews.Reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// UpdateReviewStatus approves or rejects a review.
+func (q *ReviewQueries) UpdateReviewStatus(ctx context.Context, id string, status models.ReviewStatus) error {
+	query := `UPDATE product_review SET status = ?, updated = datetime('now') WHERE id = ?`
+	return q.updateReview(ctx, query, status, id)
+}
+
+// UpdateReviewReply sets the answer of the shop to a review, an empty reply removes it.
+func (q *ReviewQueries) UpdateReviewReply(ctx context.Context, id, reply string) error {
+	query := `UPDATE product_review SET reply = ?, updated = datetime('now') WHERE id = ?`
+	return q.updateReview(ctx, query, reply, id)
+}
+
+func (q *ReviewQueries) updateReview(ctx context.Context, query string, args ...any) error {
+	result, err := q.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrReviewNotFound
+	}
+
+	return nil
+}
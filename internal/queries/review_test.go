@@ -0,0 +1,104 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+func TestProductReviews(t *testing.T) {
+	newTestProducts(t, 4)
+	ctx := context.Background()
+	const cartID, productID, otherID = "c00000000000001", "p00000000000001", "p00000000000002"
+
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `INSERT INTO customer (id, email) VALUES ('u00000000000001', 'buyer@mail.com')`)
+	require.NoError(t, err)
+	_, err = db.ProductQueries.DB.ExecContext(ctx, `
+		INSERT INTO cart (id, email, amount_total, currency, payment_status, cart, customer_id, created, updated)
+		VALUES (?, 'buyer@mail.com', 100, 'USD', 'paid', ?, 'u00000000000001', datetime('now', '-5 days'), datetime('now', '-4 days'))
+	`, cartID, `[{"id": "`+productID+`"}]`)
+	require.NoError(t, err)
+
+	t.Run("request", func(t *testing.T) {
+		carts, err := db.ReviewCarts(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, carts, 1)
+		assert.Equal(t, cartID, carts[0].ID)
+
+		carts, err = db.ReviewCarts(ctx, 5)
+		require.NoError(t, err)
+		assert.Empty(t, carts)
+
+		require.NoError(t, db.AddCartEvent(ctx, &models.CartEvent{
+			CartID:  cartID,
+			Event:   models.CartEventEmailSent,
+			Actor:   models.CartActorReviewJob,
+			Payload: map[string]any{"letter": "mail_letter_review", "to": "buyer@mail.com"},
+		}))
+		carts, err = db.ReviewCarts(ctx, 3)
+		require.NoError(t, err)
+		assert.Empty(t, carts)
+	})
+
+	var reviewID string
+	t.Run("add", func(t *testing.T) {
+		err := db.AddReview(ctx, cartID, &models.Review{ProductID: otherID, Rating: 5})
+		assert.Equal(t, errors.ErrReviewNotBought, err)
+
+		review := &models.Review{ProductID: productID, Name: "Buyer", Rating: 4, Text: "good"}
+		require.NoError(t, db.AddReview(ctx, cartID, review))
+		reviewID = review.ID
+
+		require.NoError(t, db.UpdateReviewStatus(ctx, reviewID, models.ReviewApproved))
+		review = &models.Review{ProductID: productID, Name: "Buyer", Rating: 2, Text: "changed my mind"}
+		require.NoError(t, db.AddReview(ctx, cartID, review))
+		assert.Equal(t, reviewID, review.ID)
+
+		products, err := db.ReviewProducts(ctx, cartID)
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+		require.NotNil(t, products[0].Review)
+		assert.Equal(t, 2, products[0].Review.Rating)
+		assert.Equal(t, models.ReviewPending, products[0].Review.Status)
+	})
+
+	t.Run("moderate", func(t *testing.T) {
+		reviews, err := db.ListReviews(ctx, false, productID, models.ReviewFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Zero(t, reviews.Total)
+		product, err := db.Product(ctx, true, productID)
+		require.NoError(t, err)
+		assert.Nil(t, product.Rating)
+
+		require.NoError(t, db.UpdateReviewStatus(ctx, reviewID, models.ReviewApproved))
+		require.NoError(t, db.UpdateReviewReply(ctx, reviewID, "thank you"))
+		assert.Equal(t, errors.ErrReviewNotFound, db.UpdateReviewReply(ctx, "r00000000000000", "thank you"))
+
+		reviews, err = db.ListReviews(ctx, false, productID, models.ReviewFilter{Status: models.ReviewRejected, Page: 1, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, reviews.Reviews, 1)
+		assert.Equal(t, &models.Rating{Value: 2, Count: 1}, reviews.Rating)
+		assert.Equal(t, "thank you", reviews.Reviews[0].Reply)
+		assert.Empty(t, reviews.Reviews[0].Email)
+
+		reviews, err = db.ListReviews(ctx, true, "", models.ReviewFilter{Status: models.ReviewApproved, Page: 1, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, reviews.Reviews, 1)
+		assert.Equal(t, "buyer@mail.com", reviews.Reviews[0].Email)
+		assert.Equal(t, "Product 1", reviews.Reviews[0].ProductName)
+
+		product, err = db.Product(ctx, false, "product-1")
+		require.NoError(t, err)
+		assert.Equal(t, &models.Rating{Value: 2, Count: 1}, product.Rating)
+
+		require.NoError(t, db.DeleteProduct(ctx, productID))
+		reviews, err = db.ListReviews(ctx, false, productID, models.ReviewFilter{Page: 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Zero(t, reviews.Total)
+	})
+}
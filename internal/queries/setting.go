@@ -72,6 +72,48 @@ func (q *SettingQueries) GroupFieldMap(settings any) map[string]any {
 		return map[string]any{
 			"webhook_url": &s.Url,
 		}
+	case *models.Recovery:
+		return map[string]any{
+			"recovery_active":    &s.Active,
+			"recovery_delay":     &s.Delay,
+			"recovery_frequency": &s.Frequency,
+		}
+	case *models.ReviewRequest:
+		return map[string]any{
+			"review_active": &s.Active,
+			"review_delay":  &s.Delay,
+		}
+	case *models.Seller:
+		return map[string]any{
+			"invoice_prefix":         &s.Prefix,
+			"invoice_seller_name":    &s.Name,
+			"invoice_seller_address": &s.Address,
+			"invoice_seller_vat_id":  &s.VatID,
+		}
+	case *models.Delivery:
+		return map[string]any{
+			"delivery_attachments":         &s.Attachments,
+			"delivery_attachment_max_size": &s.AttachmentMaxSize,
+			"delivery_link_ttl":            &s.LinkTTL,
+			"delivery_reservation_ttl":     &s.ReservationTTL,
+			"delivery_notice_batch":        &s.NoticeBatch,
+		}
+	case *models.Storage:
+		return map[string]any{
+			"storage_driver":     &s.Driver,
+			"storage_endpoint":   &s.Endpoint,
+			"storage_region":     &s.Region,
+			"storage_bucket":     &s.Bucket,
+			"storage_access_key": &s.AccessKey,
+			"storage_secret_key": &s.SecretKey,
+			"storage_path_style": &s.PathStyle,
+			"storage_presign":    &s.Presign,
+		}
+	case *models.Maintenance:
+		return map[string]any{
+			"cart_retention": &s.CartRetention,
+			"gc_grace":       &s.GCGrace,
+		}
 	case *models.Mail:
 		return map[string]any{
 			"mail_sender_name":  &s.SenderName,
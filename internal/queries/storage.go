@@ -0,0 +1,89 @@
+package queries
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/storage"
+)
+
+const (
+	// presignTTL is the lifetime of the direct links to objects of an s3 storage.
+	presignTTL = 15 * time.Minute
+
+	// the folders of the images and the digital files
+	uploadPrefix  = "lc_uploads/"
+	digitalPrefix = "lc_digitals/"
+	// watermarkPrefix is where the copies of digital files stamped for buyers are kept.
+	watermarkPrefix = digitalPrefix + "watermark/"
+)
+
+var storageCache struct {
+	sync.Mutex
+	config  models.Storage
+	storage storage.Storage
+}
+
+// Storage returns the storage of uploads and digital files configured in the settings.
+// The storage is built again only when the settings change, so every instance
+// sharing the database switches to a new backend on its next request.
+func Storage(ctx context.Context) (storage.Storage, error) {
+	config, err := GetSettingByGroup[models.Storage](ctx, DB())
+	if err != nil {
+		return nil, err
+	}
+
+	storageCache.Lock()
+	defer storageCache.Unlock()
+
+	if storageCache.storage != nil && storageCache.config == *config {
+		return storageCache.storage, nil
+	}
+
+	fs, err := NewStorage(config)
+	if err != nil {
+		return nil, err
+	}
+	storageCache.config, storageCache.storage = *config, fs
+
+	return fs, nil
+}
+
+// NewStorage builds the storage described by the settings, the local disk
+// is used unless the s3 driver is chosen.
+func NewStorage(config *models.Storage) (storage.Storage, error) {
+	if config.Driver != "s3" {
+		return storage.NewLocal("."), nil
+	}
+
+	s3Config := storage.S3Config{
+		Endpoint:  config.Endpoint,
+		Region:    config.Region,
+		Bucket:    config.Bucket,
+		AccessKey: config.AccessKey,
+		SecretKey: config.SecretKey,
+		PathStyle: config.PathStyle,
+	}
+	if config.Presign {
+		s3Config.PresignTTL = presignTTL
+	}
+
+	return storage.NewS3(s3Config)
+}
+
+// UploadKey returns the storage key of an uploaded image.
+func UploadKey(name string) string {
+	return uploadPrefix + name
+}
+
+// DigitalKey returns the storage key of a digital file.
+func DigitalKey(name, ext string) string {
+	return digitalPrefix + name + "." + ext
+}
+
+// WatermarkKey returns the storage key of the copy of a digital file stamped for a cart.
+func WatermarkKey(cartID, name, ext string) string {
+	return watermarkPrefix + cartID + "/" + name + "." + ext
+}
@@ -0,0 +1,141 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/litepay"
+)
+
+// RestoreProduct takes a product out of the trash, it stays inactive until it is
+// activated again.
+func (q *ProductQueries) RestoreProduct(ctx context.Context, id string) error {
+	query := `UPDATE product SET deleted = 0, updated = datetime('now') WHERE id = ? AND deleted = 1`
+	result, err := q.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+// productInCart is true for a product of a cart that is not expired or canceled, including
+// the products of a bundle. Its keys and files are delivered to the buyers from the product,
+// and a cart still waiting for its payment may be paid later on.
+const productInCart = `product.id IN (
+	SELECT json_tree.value FROM cart, json_tree(cart.cart)
+	WHERE cart.payment_status NOT IN ('` + string(litepay.EXPIRED) + `', '` + string(litepay.CANCELED) + `') AND json_tree.key = 'id'
+)`
+
+// PurgeProduct removes a product in the trash for good, with its images, files and keys.
+// errors.ErrProductSold is returned for a product of a cart that is not expired or canceled.
+func (q *ProductQueries) PurgeProduct(ctx context.Context, id string) error {
+	purged, kept, err := q.purgeProducts(ctx, id)
+	if err != nil {
+		return err
+	}
+	if kept > 0 {
+		return errors.ErrProductSold
+	}
+	if purged == 0 {
+		return errors.ErrProductNotFound
+	}
+	return nil
+}
+
+// EmptyTrash removes every product in the trash for good and returns how many were removed,
+// and how many were kept because they are in a cart that is not expired or canceled.
+func (q *ProductQueries) EmptyTrash(ctx context.Context) (purged, kept int, err error) {
+	return q.purgeProducts(ctx)
+}
+
+// purgeProducts deletes the products in the trash, all of them when no id is given.
+// Their rows go with the product, the stored images and files are removed after.
+// Products of carts that are not expired or canceled are kept, so their buyers do not lose the purchase.
+func (q *ProductQueries) purgeProducts(ctx context.Context, idList ...string) (int, int, error) {
+	var params []any
+	queryWhere := `deleted = 1`
+	if len(idList) > 0 {
+		for _, id := range idList {
+			params = append(params, id)
+		}
+		queryWhere += fmt.Sprintf(" AND id IN (%s)", strings.Repeat("?, ", len(idList)-1)+"?")
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var kept int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(id) FROM product WHERE `+queryWhere+` AND `+productInCart, params...).Scan(&kept); err != nil {
+		return 0, 0, err
+	}
+	queryWhere += ` AND NOT ` + productInCart
+
+	query := `
+			SELECT 'image', name, ext FROM product_image WHERE product_id IN (SELECT id FROM product WHERE ` + queryWhere + `)
+			UNION ALL
+			SELECT 'file', name, ext FROM digital_file WHERE product_id IN (SELECT id FROM product WHERE ` + queryWhere + `)
+	`
+	rows, err := tx.QueryContext(ctx, query, append(params, params...)...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var fileKeys []string
+	for rows.Next() {
+		var kind, name, ext string
+		if err := rows.Scan(&kind, &name, &ext); err != nil {
+			return 0, 0, err
+		}
+		if kind == "file" {
+			fileKeys = append(fileKeys, DigitalKey(name, ext))
+			continue
+		}
+		fileKeys = append(fileKeys,
+			UploadKey(fmt.Sprintf("%s.%s", name, ext)),
+			UploadKey(fmt.Sprintf("%s_sm.%s", name, ext)),
+			UploadKey(fmt.Sprintf("%s_md.%s", name, ext)),
+		)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM product WHERE `+queryWhere, params...)
+	if err != nil {
+		return 0, 0, err
+	}
+	purged, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	fs, err := Storage(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// files left behind are collected by the garbage collection later on
+	var removeErrors []string
+	for _, fileKey := range fileKeys {
+		if err := fs.Delete(ctx, fileKey); err != nil {
+			removeErrors = append(removeErrors, fmt.Sprintf("%s: %v", fileKey, err))
+		}
+	}
+	if len(removeErrors) > 0 {
+		return int(purged), kept, fmt.Errorf("one or more files could not be removed: %s", strings.Join(removeErrors, "; "))
+	}
+
+	return int(purged), kept, nil
+}
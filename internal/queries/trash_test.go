@@ -0,0 +1,136 @@
+package queries
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+func TestProductTrash(t *testing.T) {
+	newTestProducts(t, 6)
+	ctx := context.Background()
+	const productID, otherID = "p00000000000001", "p00000000000002"
+
+	listed := func(trash bool) []string {
+		products, err := db.ListProducts(ctx, true, models.ProductFilter{Trash: trash})
+		require.NoError(t, err)
+		var idList []string
+		for _, product := range products.Products {
+			idList = append(idList, product.ID)
+		}
+		return idList
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, db.DeleteProduct(ctx, productID))
+		assert.Equal(t, errors.ErrProductNotFound, db.DeleteProduct(ctx, productID))
+
+		assert.NotContains(t, listed(false), productID)
+		assert.Equal(t, []string{productID}, listed(true))
+		assert.False(t, db.IsProduct(ctx, "product-1"))
+
+		require.NoError(t, db.UpdateActive(ctx, productID))
+		product, err := db.Product(ctx, true, productID)
+		require.NoError(t, err)
+		assert.False(t, product.Active)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, db.RestoreProduct(ctx, productID))
+		assert.Equal(t, errors.ErrProductNotFound, db.RestoreProduct(ctx, productID))
+		assert.Contains(t, listed(false), productID)
+		assert.Empty(t, listed(true))
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		assert.Equal(t, errors.ErrProductNotFound, db.PurgeProduct(ctx, productID))
+
+		require.NoError(t, db.DeleteProduct(ctx, productID))
+		require.NoError(t, db.DeleteProduct(ctx, otherID))
+		require.NoError(t, db.PurgeProduct(ctx, productID))
+		assert.Equal(t, []string{otherID}, listed(true))
+
+		purged, kept, err := db.EmptyTrash(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Zero(t, kept)
+		assert.Empty(t, listed(true))
+
+		var images int
+		require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_image WHERE product_id IN (?, ?)`, productID, otherID).Scan(&images))
+		assert.Zero(t, images)
+	})
+
+	t.Run("sold", func(t *testing.T) {
+		// a paid cart and a cart waiting for its payment keep their products, a canceled one does not
+		const soldID, pendingID, canceledID = "p00000000000000", "p00000000000004", "p00000000000005"
+		_, err := db.ProductQueries.DB.ExecContext(ctx, `
+			INSERT INTO cart (id, email, amount_total, currency, payment_status, cart) VALUES
+				('c00000000000001', 'buyer@mail.com', 100, 'USD', 'paid', ?),
+				('c00000000000002', 'buyer@mail.com', 100, 'USD', 'new', ?),
+				('c00000000000003', 'buyer@mail.com', 100, 'USD', 'canceled', ?)
+		`, `[{"id": "`+soldID+`"}]`, `[{"id": "`+pendingID+`"}]`, `[{"id": "`+canceledID+`"}]`)
+		require.NoError(t, err)
+
+		for _, id := range []string{soldID, pendingID, canceledID} {
+			require.NoError(t, db.DeleteProduct(ctx, id))
+		}
+		assert.Equal(t, errors.ErrProductSold, db.PurgeProduct(ctx, soldID))
+		assert.Equal(t, errors.ErrProductSold, db.PurgeProduct(ctx, pendingID))
+
+		purged, kept, err := db.EmptyTrash(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Equal(t, 2, kept)
+		assert.ElementsMatch(t, []string{soldID, pendingID}, listed(true))
+
+		var images int
+		require.NoError(t, db.ProductQueries.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_image WHERE product_id = ?`, soldID).Scan(&images))
+		assert.Equal(t, 1, images)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		const sourceID = "p00000000000003"
+		fs, err := Storage(ctx)
+		require.NoError(t, err)
+		require.NoError(t, fs.Put(ctx, UploadKey("image-3.png"), strings.NewReader("png"), 3))
+		require.NoError(t, fs.Put(ctx, UploadKey("image-3_sm.png"), strings.NewReader("sm"), 2))
+
+		product, err := db.DuplicateProduct(ctx, sourceID)
+		require.NoError(t, err)
+		assert.NotEqual(t, sourceID, product.ID)
+		assert.Equal(t, "product-3-copy", product.Slug)
+		assert.Equal(t, "Product 3", product.Name)
+		assert.False(t, product.Active)
+		require.Len(t, product.Images, 1)
+		assert.NotEqual(t, "image-3", product.Images[0].Name)
+
+		for _, size := range []string{"", "_sm", "_md"} {
+			_, err := fs.Stat(ctx, UploadKey(product.Images[0].Name+size+".png"))
+			assert.NoError(t, err)
+		}
+
+		product, err = db.DuplicateProduct(ctx, sourceID)
+		require.NoError(t, err)
+		assert.Equal(t, "product-3-copy-2", product.Slug)
+
+		_, err = db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET slug = 'mountain-trail-guide' WHERE id = ?`, sourceID)
+		require.NoError(t, err)
+		product, err = db.DuplicateProduct(ctx, sourceID)
+		require.NoError(t, err)
+		assert.Equal(t, "mountain-trail-copy", product.Slug)
+		product, err = db.DuplicateProduct(ctx, sourceID)
+		require.NoError(t, err)
+		assert.Equal(t, "mountain-trai-copy-2", product.Slug)
+		assert.NoError(t, product.Validate())
+
+		_, err = db.DuplicateProduct(ctx, productID)
+		assert.Equal(t, errors.ErrProductNotFound, err)
+	})
+}
@@ -0,0 +1,254 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+	"github.com/shurco/litecart/pkg/security"
+)
+
+// variantScope narrows the files or keys of a table to the ones delivered with
+// the variant given as parameter. Files and keys without a variant are shared by
+// all variants, an empty parameter selects only them.
+func variantScope(table string) string {
+	return `(` + table + `.variant_id IS NULL OR ` + table + `.variant_id = ?)`
+}
+
+// ProductVariants retrieves the variants of a product in their sort order,
+// the public list leaves out the inactive ones.
+func (q *ProductQueries) ProductVariants(ctx context.Context, private bool, productID string) ([]models.ProductVariant, error) {
+	query := `
+			SELECT id, product_id, name, COALESCE(sku, ''), amount, sort, active, strftime('%s', created), strftime('%s', updated)
+			FROM product_variant
+			WHERE product_id = ?
+	`
+	if !private {
+		query += ` AND active = 1`
+	}
+	query += ` ORDER BY sort, rowid`
+
+	rows, err := q.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []models.ProductVariant{}
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, *variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// AddVariant inserts a new variant of a product and returns it with the created timestamp.
+func (q *ProductQueries) AddVariant(ctx context.Context, variant *models.ProductVariant) (*models.ProductVariant, error) {
+	variant.ID = security.RandomString()
+
+	if err := q.checkVariant(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	// the price of a variant replaces the one of the product, a sale of the product would be ignored
+	var sale bool
+	if err := q.DB.QueryRowContext(ctx, `SELECT sale_amount IS NOT NULL FROM product WHERE id = ?`, variant.ProductID).Scan(&sale); err != nil {
+		return nil, err
+	}
+	if sale {
+		return nil, errors.ErrVariantSale
+	}
+
+	query := `INSERT INTO product_variant (id, product_id, name, sku, amount, sort, active) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING strftime('%s', created)`
+	err := q.DB.QueryRowContext(ctx, query,
+		variant.ID, variant.ProductID, variant.Name, nullString(variant.SKU), variant.Amount, variant.Sort, variant.Active,
+	).Scan(&variant.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+// UpdateVariant updates the details of a variant of a product.
+func (q *ProductQueries) UpdateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	if err := q.checkVariant(ctx, variant); err != nil {
+		return err
+	}
+
+	query := `UPDATE product_variant SET name = ?, sku = ?, amount = ?, sort = ?, active = ?, updated = datetime('now') WHERE id = ? AND product_id = ?`
+	result, err := q.DB.ExecContext(ctx, query,
+		variant.Name, nullString(variant.SKU), variant.Amount, variant.Sort, variant.Active, variant.ID, variant.ProductID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrVariantNotFound
+	}
+
+	return nil
+}
+
+// DeleteVariant removes a variant together with its files and unsold keys. Keys that
+// were already sold stay with their carts, the stored files are left to the garbage
+// collection.
+func (q *ProductQueries) DeleteVariant(ctx context.Context, productID, variantID string) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM product_variant WHERE id = ? AND product_id = ?`, variantID, productID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrVariantNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM digital_file WHERE variant_id = ?`, variantID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM digital_data WHERE variant_id = ? AND `+freeKey, variantID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE digital_data SET variant_id = NULL WHERE variant_id = ?`, variantID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateDigitalVariant assigns a file, with all its versions, or a key of a product
+// to one of its variants. An empty variant shares it between all variants.
+func (q *ProductQueries) UpdateDigitalVariant(ctx context.Context, productID, digitalID, variantID string) error {
+	if err := hasVariant(ctx, q.DB, productID, variantID); err != nil {
+		return err
+	}
+
+	query := `
+			UPDATE digital_file SET variant_id = ?
+			WHERE lineage_id = (SELECT lineage_id FROM digital_file WHERE id = ? AND product_id = ?)
+	`
+	result, err := q.DB.ExecContext(ctx, query, nullString(variantID), digitalID, productID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return nil
+	}
+
+	result, err = q.DB.ExecContext(ctx, `UPDATE digital_data SET variant_id = ? WHERE id = ? AND product_id = ?`, nullString(variantID), digitalID, productID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// CartVariant retrieves the active variant a cart product refers to. Nil is returned
+// for products without variants, errors.ErrVariantRequired when the product has
+// variants but none was chosen.
+func (q *ProductQueries) CartVariant(ctx context.Context, product models.CartProduct) (*models.ProductVariant, error) {
+	if product.VariantID == "" {
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM product_variant WHERE product_id = ? AND active = 1)`
+		if err := q.DB.QueryRowContext(ctx, query, product.ProductID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.ErrVariantRequired
+		}
+		return nil, nil
+	}
+
+	query := `
+			SELECT id, product_id, name, COALESCE(sku, ''), amount, sort, active, strftime('%s', created), strftime('%s', updated)
+			FROM product_variant
+			WHERE id = ? AND product_id = ? AND active = 1
+	`
+	variant, err := scanVariant(q.DB.QueryRowContext(ctx, query, product.VariantID, product.ProductID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrVariantNotFound
+		}
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+// checkVariant makes sure the product of a variant exists and the sku is free.
+func (q *ProductQueries) checkVariant(ctx context.Context, variant *models.ProductVariant) error {
+	var product, sku bool
+	query := `SELECT EXISTS(SELECT 1 FROM product WHERE id = ?), EXISTS(SELECT 1 FROM product_variant WHERE sku = ? AND id != ?)`
+	if err := q.DB.QueryRowContext(ctx, query, variant.ProductID, variant.SKU, variant.ID).Scan(&product, &sku); err != nil {
+		return err
+	}
+	if !product {
+		return errors.ErrProductNotFound
+	}
+	if sku {
+		return errors.ErrVariantExists
+	}
+	return nil
+}
+
+// hasVariant returns errors.ErrVariantNotFound unless the variant belongs to the product,
+// an empty variant always passes.
+func hasVariant(ctx context.Context, exec queryRower, productID, variantID string) error {
+	if variantID == "" {
+		return nil
+	}
+
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM product_variant WHERE id = ? AND product_id = ?)`
+	if err := exec.QueryRowContext(ctx, query, variantID, productID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrVariantNotFound
+	}
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func scanVariant(row rowScanner) (*models.ProductVariant, error) {
+	variant := &models.ProductVariant{}
+	var updated sql.NullInt64
+
+	err := row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.Name,
+		&variant.SKU,
+		&variant.Amount,
+		&variant.Sort,
+		&variant.Active,
+		&variant.Created,
+		&updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Valid {
+		variant.Updated = updated.Int64
+	}
+
+	return variant, nil
+}
@@ -0,0 +1,124 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shurco/litecart/internal/models"
+	"github.com/shurco/litecart/pkg/errors"
+)
+
+func TestProductVariants(t *testing.T) {
+	newTestProducts(t, 2)
+	ctx := context.Background()
+	const productID = "p00000000000001"
+
+	_, err := db.ProductQueries.DB.ExecContext(ctx, `UPDATE product SET digital = 'data' WHERE id = ?`, productID)
+	require.NoError(t, err)
+
+	pdf, err := db.AddVariant(ctx, &models.ProductVariant{ProductID: productID, Name: "PDF", SKU: "BOOK-PDF", Amount: 900, Active: true})
+	require.NoError(t, err)
+	epub, err := db.AddVariant(ctx, &models.ProductVariant{ProductID: productID, Name: "EPUB", Amount: 700, Sort: 1, Active: true})
+	require.NoError(t, err)
+
+	t.Run("sku", func(t *testing.T) {
+		_, err := db.AddVariant(ctx, &models.ProductVariant{ProductID: productID, Name: "Copy", SKU: "BOOK-PDF", Active: true})
+		assert.Equal(t, errors.ErrVariantExists, err)
+
+		_, err = db.AddVariant(ctx, &models.ProductVariant{ProductID: "p00000000000099", Name: "Lost", Active: true})
+		assert.Equal(t, errors.ErrProductNotFound, err)
+	})
+
+	t.Run("product", func(t *testing.T) {
+		product, err := db.Product(ctx, true, productID)
+		require.NoError(t, err)
+		require.Len(t, product.Variants, 2)
+		assert.Equal(t, "PDF", product.Variants[0].Name)
+
+		products, err := db.ListProducts(ctx, true, models.ProductFilter{})
+		require.NoError(t, err)
+		for _, product := range products.Products {
+			if product.ID == productID {
+				assert.Len(t, product.Variants, 2)
+			}
+		}
+	})
+
+	t.Run("sale", func(t *testing.T) {
+		product, err := db.Product(ctx, true, productID)
+		require.NoError(t, err)
+		product.Sale = &models.Sale{Amount: 500}
+		assert.Equal(t, errors.ErrVariantSale, db.UpdateProduct(ctx, product))
+
+		const otherID = "p00000000000000"
+		other, err := db.Product(ctx, true, otherID)
+		require.NoError(t, err)
+		other.Sale = &models.Sale{Amount: 50}
+		require.NoError(t, db.UpdateProduct(ctx, other))
+		_, err = db.AddVariant(ctx, &models.ProductVariant{ProductID: otherID, Name: "Other", Active: true})
+		assert.Equal(t, errors.ErrVariantSale, err)
+	})
+
+	t.Run("cart variant", func(t *testing.T) {
+		_, err := db.CartVariant(ctx, models.CartProduct{ProductID: productID})
+		assert.Equal(t, errors.ErrVariantRequired, err)
+
+		_, err = db.CartVariant(ctx, models.CartProduct{ProductID: productID, VariantID: "v00000000000000"})
+		assert.Equal(t, errors.ErrVariantNotFound, err)
+
+		variant, err := db.CartVariant(ctx, models.CartProduct{ProductID: productID, VariantID: epub.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 700, variant.Amount)
+
+		variant, err = db.CartVariant(ctx, models.CartProduct{ProductID: "p00000000000000"})
+		require.NoError(t, err)
+		assert.Nil(t, variant)
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		_, err := db.ImportDigitalData(ctx, productID, pdf.ID, []string{"PDF-1"})
+		require.NoError(t, err)
+		_, err = db.ImportDigitalData(ctx, productID, "", []string{"SHARED-1"})
+		require.NoError(t, err)
+
+		assert.Equal(t, errors.ErrOutOfStock, db.CheckStock(ctx, []models.CartProduct{{ProductID: productID, VariantID: epub.ID, Quantity: 2}}))
+		assert.NoError(t, db.CheckStock(ctx, []models.CartProduct{{ProductID: productID, VariantID: pdf.ID, Quantity: 2}}))
+
+		tx, err := db.ProductQueries.DB.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		epubLine := models.CartProduct{ProductID: productID, VariantID: epub.ID}
+		claimed, err := claimKeys(ctx, tx, "c00000000000001", epubLine, 1, "")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, claimed)
+
+		pdfLine := models.CartProduct{ProductID: productID, VariantID: pdf.ID}
+		claimed, err = claimKeys(ctx, tx, "c00000000000002", pdfLine, 2, "")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, claimed)
+
+		keys, err := soldKeys(ctx, tx, "c00000000000001", epubLine)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "SHARED-1", keys[0].Content)
+
+		keys, err = soldKeys(ctx, tx, "c00000000000002", pdfLine)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "PDF-1", keys[0].Content)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, db.DeleteVariant(ctx, productID, pdf.ID))
+		assert.Equal(t, errors.ErrVariantNotFound, db.DeleteVariant(ctx, productID, pdf.ID))
+
+		digital, err := db.ProductDigital(ctx, productID)
+		require.NoError(t, err)
+		require.Len(t, digital.Data, 1)
+		assert.Equal(t, "SHARED-1", digital.Data[0].Content)
+	})
+}
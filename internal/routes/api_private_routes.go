@@ -32,17 +32,45 @@ func ApiPrivateRoutes(c *fiber.App) {
 	pages.Patch("/:page_id<len(15)>/content", handlers.UpdatePageContent)
 	pages.Patch("/:page_id<len(15)>/active", handlers.UpdatePageActive)
 
+	categories := c.Group("/api/_/categories", middleware.JWTProtected())
+	categories.Get("/", handlers.Categories)
+	categories.Post("/", handlers.AddCategory)
+	categories.Get("/:category_id<len(15)>", handlers.Category)
+	categories.Patch("/:category_id<len(15)>", handlers.UpdateCategory)
+	categories.Delete("/:category_id<len(15)>", handlers.DeleteCategory)
+	categories.Patch("/:category_id<len(15)>/active", handlers.UpdateCategoryActive)
+
 	product := c.Group("/api/_/products", middleware.JWTProtected())
 	product.Get("/", handlers.Products)
 	product.Post("/", handlers.AddProduct)
+	product.Get("/export", handlers.ExportProducts)
+	product.Post("/import", handlers.ImportProducts)
+	product.Delete("/trash", handlers.EmptyProductTrash)
 	product.Get("/:product_id<len(15)>", handlers.Product)
 	product.Patch("/:product_id<len(15)>", handlers.UpdateProduct)
 	product.Delete("/:product_id<len(15)>", handlers.DeleteProduct)
 	product.Patch("/:product_id<len(15)>/active", handlers.UpdateProductActive)
+	product.Post("/:product_id<len(15)>/restore", handlers.RestoreProduct)
+	product.Delete("/:product_id<len(15)>/purge", handlers.PurgeProduct)
+	product.Post("/:product_id<len(15)>/duplicate", handlers.DuplicateProduct)
+
+	product.Get("/:product_id<len(15)>/variants", handlers.ProductVariants)
+	product.Post("/:product_id<len(15)>/variants", handlers.AddProductVariant)
+	product.Patch("/:product_id<len(15)>/variants/:variant_id<len(15)>", handlers.UpdateProductVariant)
+	product.Delete("/:product_id<len(15)>/variants/:variant_id<len(15)>", handlers.DeleteProductVariant)
 
 	product.Get("/:product_id<len(15)>/digital", handlers.ProductDigital)
 	product.Post("/:product_id<len(15)>/digital", handlers.AddProductDigital)
+	product.Post("/:product_id<len(15)>/digital/import", handlers.ImportProductDigital)
+	product.Get("/:product_id<len(15)>/digital/export", handlers.ExportProductDigital)
+	product.Patch("/:product_id<len(15)>/digital/api", handlers.UpdateProductDigitalAPI)
+	product.Patch("/:product_id<len(15)>/digital/generator", handlers.UpdateProductDigitalGenerator)
+	product.Patch("/:product_id<len(15)>/digital/bundle", handlers.UpdateProductDigitalBundle)
 	product.Patch("/:product_id<len(15)>/digital/:digital_id<len(15)>", handlers.UpdateProductDigital)
+	product.Post("/:product_id<len(15)>/digital/:digital_id<len(15)>/version", handlers.AddProductDigitalVersion)
+	product.Patch("/:product_id<len(15)>/digital/:digital_id<len(15)>/version", handlers.UpdateProductDigitalVersion)
+	product.Patch("/:product_id<len(15)>/digital/:digital_id<len(15)>/variant", handlers.UpdateProductDigitalVariant)
+	product.Post("/:product_id<len(15)>/digital/:digital_id<len(15)>/notify", handlers.NotifyProductDigitalRelease)
 	product.Delete("/:product_id<len(15)>/digital/:digital_id<len(15)>", handlers.DeleteProductDigital)
 
 	product.Get("/:product_id<len(15)>/image", handlers.ProductImages)
@@ -52,5 +80,22 @@ func ApiPrivateRoutes(c *fiber.App) {
 	// carts
 	carts := c.Group("/api/_/carts", middleware.JWTProtected())
 	carts.Get("/", handlers.Carts)
+	carts.Get("/:cart_id<len(15)>", handlers.Cart)
+	carts.Patch("/:cart_id<len(15)>/status", handlers.UpdateCartStatus)
+	carts.Get("/:cart_id<len(15)>/invoice", handlers.CartInvoice)
+	carts.Delete("/:cart_id<len(15)>/downloads", handlers.ResetCartDownloads)
+	carts.Delete("/:cart_id<len(15)>/activations", handlers.ResetCartActivations)
+	carts.Post("/:cart_id<len(15)>/delivery", handlers.RetryCartDelivery)
 	carts.Post("/:cart_id<len(15)>/mail", handlers.CartSendMail)
+
+	// customers
+	customers := c.Group("/api/_/customers", middleware.JWTProtected())
+	customers.Get("/", handlers.Customers)
+	customers.Get("/:customer_id<len(15)>", handlers.Customer)
+
+	// reviews
+	reviews := c.Group("/api/_/reviews", middleware.JWTProtected())
+	reviews.Get("/", handlers.Reviews)
+	reviews.Patch("/:review_id<len(15)>/status", handlers.UpdateReviewStatus)
+	reviews.Patch("/:review_id<len(15)>/reply", handlers.UpdateReviewReply)
 }
@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 
 	handlers "github.com/shurco/litecart/internal/handlers/public"
+	"github.com/shurco/litecart/internal/middleware"
 )
 
 // ApiPublicRoutes is ...
@@ -13,9 +16,29 @@ func ApiPublicRoutes(c *fiber.App) {
 	c.Get("/api/settings", handlers.Settings)
 	c.Get("/api/pages/:page_slug", handlers.Page)
 
+	c.Get("/api/categories", handlers.Categories)
+	c.Get("/api/categories/:category_slug", handlers.Category)
+
 	product := c.Group("/api/products")
 	product.Get("/", handlers.Products)
 	product.Get("/:product_id", handlers.Product)
+	product.Get("/:product_id/reviews", handlers.ProductReviews)
+
+	review := c.Group("/api/reviews")
+	review.Get("/:token", handlers.ReviewProducts)
+	review.Post("/:token", middleware.Limiter(30, time.Minute), handlers.AddReview)
 
 	c.Get("/api/cart/payment", handlers.PaymentList)
+	c.Get("/api/cart/recover/:token", handlers.CartRecover)
+
+	c.Post("/api/licenses/verify", middleware.Limiter(30, time.Minute), handlers.LicenseVerify)
+
+	customerSign := c.Group("/api/customer/sign")
+	customerSign.Post("/in", handlers.CustomerSignIn)
+	customerSign.Get("/in/:token", handlers.CustomerSignInToken)
+	customerSign.Post("/out", middleware.CustomerProtected(), handlers.CustomerSignOut)
+
+	customer := c.Group("/api/customer", middleware.CustomerProtected())
+	customer.Get("/", handlers.Customer)
+	customer.Get("/purchases", handlers.CustomerPurchases)
 }
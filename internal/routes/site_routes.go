@@ -14,16 +14,19 @@ func SiteRoutes(c *fiber.App) {
 	})
 
 	// catalog section
-	c.Get("/products/:product_slug", func(c *fiber.Ctx) error {
-		productSlug := c.Params("product_slug")
+	c.Get("/products/:product_slug", handlers.ProductPage)
+
+	c.Get("/categories/:category_slug", func(c *fiber.Ctx) error {
+		categorySlug := c.Params("category_slug")
 		db := queries.DB()
 
-		if !db.IsProduct(c.Context(), productSlug) {
+		category, err := db.Category(c.Context(), false, categorySlug)
+		if err != nil {
 			return c.Status(fiber.StatusNotFound).Render("404", fiber.Map{}, "layouts/clear")
 		}
 
-		return c.Render("product", fiber.Map{
-			"ProductSlug": productSlug,
+		return c.Render("category", fiber.Map{
+			"Category": category,
 		}, "layouts/main")
 	})
 
@@ -32,6 +35,16 @@ func SiteRoutes(c *fiber.App) {
 		return c.Render("cart", nil, "layouts/main")
 	})
 
+	// customer section
+	c.Get("/account", func(c *fiber.Ctx) error {
+		return c.Render("account", nil, "layouts/main")
+	})
+	c.Get("/unsubscribe/:token", handlers.CustomerUnsubscribe)
+	c.Get("/review/:token", handlers.ReviewPage)
+
+	// download section
+	c.Get("/download/:token", handlers.Download)
+
 	payment := c.Group("/cart/payment")
 	payment.Post("/", handlers.Payment)
 	payment.Post("/callback", handlers.PaymentCallback)
@@ -72,7 +72,36 @@ func SendPaymentHook(resData *Payment) error {
 		if err := <-errCh; err != nil {
 			return err
 		}
+
+		if resData.Data.CartID != "" {
+			err := db.AddCartEvent(ctx, &models.CartEvent{
+				CartID: resData.Data.CartID,
+				Event:  models.CartEventWebhookSent,
+				Actor:  eventActor(resData.Event),
+				Payload: map[string]any{
+					"event":  resData.Event,
+					"status": resData.Data.PaymentStatus,
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
+
+// eventActor maps a webhook event to the actor that triggered it.
+func eventActor(event Event) models.CartActor {
+	switch event {
+	case PAYMENT_CALLBACK:
+		return models.CartActorProviderCallback
+	case PAYMENT_SUCCESS:
+		return models.CartActorSuccessRedirect
+	case PAYMENT_CANCEL:
+		return models.CartActorCancelRedirect
+	default:
+		return models.CartActorCheckout
+	}
+}
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE customer (
+	id       TEXT PRIMARY KEY NOT NULL,
+	email    TEXT UNIQUE NOT NULL,
+	created  TIMESTAMP DEFAULT (datetime('now')),
+	updated  TIMESTAMP
+);
+CREATE INDEX idx_customer_email ON customer (email);
+
+ALTER TABLE cart ADD COLUMN "customer_id" TEXT DEFAULT NULL;
+CREATE INDEX idx_cart_customer_id ON cart (customer_id);
+
+INSERT INTO customer (id, email, created)
+SELECT lower(substr(hex(randomblob(8)), 1, 15)), lower(trim(email)), MIN(created)
+FROM cart
+WHERE email IS NOT NULL AND trim(email) != ''
+GROUP BY lower(trim(email));
+
+UPDATE cart SET customer_id = (SELECT id FROM customer WHERE customer.email = lower(trim(cart.email)))
+WHERE email IS NOT NULL AND trim(email) != '';
+
+INSERT INTO setting VALUES ('mTVFF1Ck3Pggx3K', 'mail_letter_login', '{"subject":"Sign in to {{.Site_Name}}","text":"Hello,\n\nUse the link below to sign in to your account on the [{{.Site_Name}}] website and see all your purchases:\n\n{{.Login_URL}}\n\nThe link is valid for 15 minutes. If you did not request it, just ignore this message.\n\nBest regards,","html":""}');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id = 'mTVFF1Ck3Pggx3K';
+DROP INDEX idx_cart_customer_id;
+ALTER TABLE cart DROP COLUMN "customer_id";
+DROP TABLE customer;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE cart_event (
+	id       TEXT PRIMARY KEY NOT NULL,
+	cart_id  TEXT NOT NULL,
+	event    TEXT NOT NULL,
+	actor    TEXT NOT NULL,
+	payload  JSON DEFAULT '{}' NOT NULL,
+	created  TIMESTAMP DEFAULT (datetime('now')),
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_cart_event_cart_id ON cart_event (cart_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE cart_event;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE customer ADD COLUMN "unsubscribed" BOOLEAN DEFAULT FALSE NOT NULL;
+
+INSERT INTO setting VALUES ('Rz4pWq8NcY2bLhT', 'recovery_active', 'false');
+INSERT INTO setting VALUES ('kD7vHs3XmE9uQaF', 'recovery_delay', '24');
+INSERT INTO setting VALUES ('Pn2GtJ6wZr5oVcM', 'recovery_frequency', '72');
+INSERT INTO setting VALUES ('bW8yKe4LfS1xNdU', 'mail_letter_recovery', '{"subject":"Your cart on {{.Site_Name}} is waiting","text":"Hello,\n\nYou left some items in your cart on the [{{.Site_Name}}] website. The total is {{.Amount_Payment}}.\n\nYou can complete your order here:\n\n{{.Payment_URL}}\n\nIf you no longer want to receive these reminders, unsubscribe here:\n\n{{.Unsubscribe_URL}}\n\nBest regards,","html":""}');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id IN ('Rz4pWq8NcY2bLhT', 'kD7vHs3XmE9uQaF', 'Pn2GtJ6wZr5oVcM', 'bW8yKe4LfS1xNdU');
+ALTER TABLE customer DROP COLUMN "unsubscribed";
+-- +goose StatementEnd
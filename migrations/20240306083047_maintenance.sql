@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO setting VALUES ('Hq3XcV7nTb2LmZe', 'cart_retention', '30');
+CREATE INDEX idx_session_expires ON session (expires);
+CREATE INDEX idx_digital_data_cart_id ON digital_data (cart_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_digital_data_cart_id;
+DROP INDEX idx_session_expires;
+DELETE FROM setting WHERE id = 'Hq3XcV7nTb2LmZe';
+-- +goose StatementEnd
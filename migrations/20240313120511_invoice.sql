@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cart ADD COLUMN "billing" JSON DEFAULT '{}' NOT NULL;
+
+CREATE TABLE invoice (
+	id            TEXT PRIMARY KEY NOT NULL,
+	cart_id       TEXT UNIQUE NOT NULL,
+	number        INTEGER UNIQUE NOT NULL,
+	seller        JSON DEFAULT '{}' NOT NULL,
+	buyer         JSON DEFAULT '{}' NOT NULL,
+	items         JSON DEFAULT '[]' NOT NULL,
+	amount_total  NUMERIC NOT NULL,
+	currency      TEXT NOT NULL,
+	created       TIMESTAMP DEFAULT (datetime('now')),
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+CREATE INDEX idx_invoice_cart_id ON invoice (cart_id);
+
+INSERT INTO setting VALUES ('Vt6NsQ2jLx8RcWa', 'invoice_prefix', 'INV-');
+INSERT INTO setting VALUES ('gE3mZp9KdT5yHbS', 'invoice_seller_name', '');
+INSERT INTO setting VALUES ('Yc7RwL4fUq1XnPk', 'invoice_seller_address', '');
+INSERT INTO setting VALUES ('aJ5hTe8VzM2sGdQ', 'invoice_seller_vat_id', '');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id IN ('Vt6NsQ2jLx8RcWa', 'gE3mZp9KdT5yHbS', 'Yc7RwL4fUq1XnPk', 'aJ5hTe8VzM2sGdQ');
+DROP TABLE invoice;
+ALTER TABLE cart DROP COLUMN "billing";
+-- +goose StatementEnd
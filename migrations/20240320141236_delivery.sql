@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO setting VALUES ('Nf4TxB9qWe2KjZr', 'delivery_attachments', 'false');
+INSERT INTO setting VALUES ('uL6GzP1mRy8DsVc', 'delivery_attachment_max_size', '5');
+INSERT INTO setting VALUES ('Xe9HkC3wQn7TaLb', 'delivery_link_ttl', '72');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id IN ('Nf4TxB9qWe2KjZr', 'uL6GzP1mRy8DsVc', 'Xe9HkC3wQn7TaLb');
+-- +goose StatementEnd
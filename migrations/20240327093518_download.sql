@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product ADD COLUMN "download_limit" INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE product ADD COLUMN "download_ip_limit" INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE product ADD COLUMN "download_ttl" INTEGER DEFAULT 0 NOT NULL;
+
+CREATE TABLE download (
+	id          TEXT PRIMARY KEY NOT NULL,
+	cart_id     TEXT NOT NULL,
+	product_id  TEXT NOT NULL,
+	file_id     TEXT NOT NULL,
+	ip          TEXT NOT NULL,
+	user_agent  TEXT DEFAULT '' NOT NULL,
+	counted     BOOLEAN DEFAULT TRUE NOT NULL,
+	created     TIMESTAMP DEFAULT (datetime('now')),
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_download_cart_id ON download (cart_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE download;
+ALTER TABLE product DROP COLUMN "download_ttl";
+ALTER TABLE product DROP COLUMN "download_ip_limit";
+ALTER TABLE product DROP COLUMN "download_limit";
+-- +goose StatementEnd
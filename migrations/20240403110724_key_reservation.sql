@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE digital_data ADD COLUMN "reserved_until" TIMESTAMP DEFAULT NULL;
+INSERT INTO setting VALUES ('pR4wKm8ZtE2cYhN', 'delivery_reservation_ttl', '60');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id = 'pR4wKm8ZtE2cYhN';
+ALTER TABLE digital_data DROP COLUMN "reserved_until";
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE digital_api (
+	id          TEXT PRIMARY KEY NOT NULL,
+	product_id  TEXT UNIQUE NOT NULL,
+	url         TEXT NOT NULL,
+	secret      TEXT NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE cart_delivery (
+	id            TEXT PRIMARY KEY NOT NULL,
+	cart_id       TEXT NOT NULL,
+	product_id    TEXT NOT NULL,
+	status        TEXT DEFAULT 'pending' NOT NULL,
+	attempts      INTEGER DEFAULT 0 NOT NULL,
+	next_attempt  TIMESTAMP DEFAULT (datetime('now')),
+	content       TEXT DEFAULT '' NOT NULL,
+	error         TEXT DEFAULT '' NOT NULL,
+	created       TIMESTAMP DEFAULT (datetime('now')),
+	updated       TIMESTAMP,
+	UNIQUE (cart_id, product_id),
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_cart_delivery_status ON cart_delivery (status, next_attempt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE cart_delivery;
+DROP TABLE digital_api;
+-- +goose StatementEnd
@@ -0,0 +1,75 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE digital_generator (
+	id          TEXT PRIMARY KEY NOT NULL,
+	product_id  TEXT UNIQUE NOT NULL,
+	mode        TEXT DEFAULT 'pattern' NOT NULL,
+	pattern     TEXT DEFAULT 'XXXX-XXXX-XXXX' NOT NULL,
+	charset     TEXT DEFAULT '' NOT NULL,
+	checksum    BOOLEAN DEFAULT FALSE NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- the digital type is checked by the table, so the table is rebuilt to accept "generated"
+CREATE TABLE product_new (
+	id                 TEXT PRIMARY KEY NOT NULL,
+	name               TEXT NOT NULL,
+	desc               TEXT NOT NULL,
+	slug               TEXT UNIQUE NOT NULL,
+	amount             NUMERC NOT NULL,
+	metadata           JSON DEFAULT '{}' NOT NULL,
+	attribute          JSON DEFAULT '[]' NOT NULL,
+	digital            TEXT CHECK (digital == 'file' OR digital == 'data' OR digital == 'api' OR digital == 'generated'),
+	active             BOOLEAN DEFAULT TRUE NOT NULL,
+	deleted            BOOLEAN DEFAULT FALSE NOT NULL,
+	created            TIMESTAMP DEFAULT (datetime('now')),
+	updated            TIMESTAMP,
+	seo                JSON DEFAULT '{}' NOT NULL,
+	brief              TEXT NOT NULL DEFAULT '',
+	download_limit     INTEGER DEFAULT 0 NOT NULL,
+	download_ip_limit  INTEGER DEFAULT 0 NOT NULL,
+	download_ttl       INTEGER DEFAULT 0 NOT NULL
+);
+INSERT INTO product_new (id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl)
+SELECT id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl FROM product;
+DROP TABLE product;
+ALTER TABLE product_new RENAME TO product;
+CREATE INDEX idx_product_id ON product (id);
+CREATE INDEX idx_product_name ON product (name);
+CREATE INDEX idx_product_slug ON product (slug);
+
+INSERT INTO setting VALUES ('bW7nXq3LsD9kRfT', 'license_seed', lower(hex(randomblob(32))));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id = 'bW7nXq3LsD9kRfT';
+DROP TABLE digital_generator;
+
+CREATE TABLE product_new (
+	id                 TEXT PRIMARY KEY NOT NULL,
+	name               TEXT NOT NULL,
+	desc               TEXT NOT NULL,
+	slug               TEXT UNIQUE NOT NULL,
+	amount             NUMERC NOT NULL,
+	metadata           JSON DEFAULT '{}' NOT NULL,
+	attribute          JSON DEFAULT '[]' NOT NULL,
+	digital            TEXT CHECK (digital == 'file' OR digital == 'data' OR digital == 'api'),
+	active             BOOLEAN DEFAULT TRUE NOT NULL,
+	deleted            BOOLEAN DEFAULT FALSE NOT NULL,
+	created            TIMESTAMP DEFAULT (datetime('now')),
+	updated            TIMESTAMP,
+	seo                JSON DEFAULT '{}' NOT NULL,
+	brief              TEXT NOT NULL DEFAULT '',
+	download_limit     INTEGER DEFAULT 0 NOT NULL,
+	download_ip_limit  INTEGER DEFAULT 0 NOT NULL,
+	download_ttl       INTEGER DEFAULT 0 NOT NULL
+);
+INSERT INTO product_new (id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl)
+SELECT id, name, desc, slug, amount, metadata, attribute, CASE digital WHEN 'generated' THEN NULL ELSE digital END, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl FROM product;
+DROP TABLE product;
+ALTER TABLE product_new RENAME TO product;
+CREATE INDEX idx_product_id ON product (id);
+CREATE INDEX idx_product_name ON product (name);
+CREATE INDEX idx_product_slug ON product (slug);
+-- +goose StatementEnd
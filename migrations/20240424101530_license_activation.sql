@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product ADD COLUMN "activation_limit" INTEGER DEFAULT 0 NOT NULL;
+
+CREATE TABLE license_activation (
+	id               TEXT PRIMARY KEY NOT NULL,
+	digital_data_id  TEXT NOT NULL,
+	cart_id          TEXT NOT NULL,
+	instance         TEXT NOT NULL,
+	ip               TEXT DEFAULT '' NOT NULL,
+	created          TIMESTAMP DEFAULT (datetime('now')),
+	UNIQUE (digital_data_id, instance),
+	FOREIGN KEY (digital_data_id) REFERENCES digital_data(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_license_activation_cart_id ON license_activation (cart_id);
+CREATE INDEX idx_digital_data_content ON digital_data (content);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_digital_data_content;
+DROP TABLE license_activation;
+ALTER TABLE product DROP COLUMN "activation_limit";
+-- +goose StatementEnd
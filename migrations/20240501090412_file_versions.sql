@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- files that are versions of the same deliverable share the lineage of the first one
+ALTER TABLE digital_file ADD COLUMN "lineage_id" TEXT DEFAULT '' NOT NULL;
+ALTER TABLE digital_file ADD COLUMN "version" TEXT DEFAULT '' NOT NULL;
+ALTER TABLE digital_file ADD COLUMN "changelog" TEXT DEFAULT '' NOT NULL;
+ALTER TABLE digital_file ADD COLUMN "active" BOOLEAN DEFAULT TRUE NOT NULL;
+ALTER TABLE digital_file ADD COLUMN "created" TIMESTAMP;
+UPDATE digital_file SET lineage_id = id, created = datetime('now');
+CREATE INDEX idx_digital_file_lineage_id ON digital_file (lineage_id);
+
+CREATE TABLE release_notice (
+	id          TEXT PRIMARY KEY NOT NULL,
+	product_id  TEXT NOT NULL,
+	file_id     TEXT NOT NULL,
+	cart_id     TEXT NOT NULL,
+	email       TEXT NOT NULL,
+	status      TEXT DEFAULT 'pending' NOT NULL,
+	error       TEXT DEFAULT '' NOT NULL,
+	created     TIMESTAMP DEFAULT (datetime('now')),
+	sent        TIMESTAMP,
+	UNIQUE (file_id, email),
+	FOREIGN KEY (file_id) REFERENCES digital_file(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_release_notice_status ON release_notice (status, created);
+
+INSERT INTO setting VALUES ('Hq5sVn2RwK8tLcE', 'delivery_notice_batch', '50');
+INSERT INTO setting VALUES ('Tm3zJx7PbF4gWdY', 'mail_letter_release', '{"subject":"{{.Product_Name}} {{.Version}} is available","text":"Hello,\n\nA new version of {{.Product_Name}} you bought on the [{{.Site_Name}}] website is available.\n\nVersion: {{.Version}}\n\n{{.Changelog}}\n\nDownload it here:\n\n{{.Download_URL}}\n\nBest regards,","html":""}');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id IN ('Hq5sVn2RwK8tLcE', 'Tm3zJx7PbF4gWdY');
+DROP TABLE release_notice;
+DROP INDEX idx_digital_file_lineage_id;
+ALTER TABLE digital_file DROP COLUMN "created";
+ALTER TABLE digital_file DROP COLUMN "active";
+ALTER TABLE digital_file DROP COLUMN "changelog";
+ALTER TABLE digital_file DROP COLUMN "version";
+ALTER TABLE digital_file DROP COLUMN "lineage_id";
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO setting VALUES ('Kp4vRz8NcW2xYtB', 'storage_driver', 'local');
+INSERT INTO setting VALUES ('Fm7qLs3HdJ9bXwE', 'storage_endpoint', '');
+INSERT INTO setting VALUES ('Uc2nTy6PgV8rZkA', 'storage_region', '');
+INSERT INTO setting VALUES ('Wd9sMb4QxF3hLnJ', 'storage_bucket', '');
+INSERT INTO setting VALUES ('Ej5tKr7YwC2vNpG', 'storage_access_key', '');
+INSERT INTO setting VALUES ('Rh3xVg8LmT6qBzD', 'storage_secret_key', '');
+INSERT INTO setting VALUES ('Ny6wPc2JsK9fXuH', 'storage_path_style', 'false');
+INSERT INTO setting VALUES ('Ta8bZq5GvR3mWkS', 'storage_presign', 'false');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE key IN ('storage_driver', 'storage_endpoint', 'storage_region', 'storage_bucket', 'storage_access_key', 'storage_secret_key', 'storage_path_style', 'storage_presign');
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO setting VALUES ('Zg6rMx2WbN8tQcF', 'gc_grace', '24');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id = 'Zg6rMx2WbN8tQcF';
+-- +goose StatementEnd
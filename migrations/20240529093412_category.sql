@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE category (
+	id          TEXT PRIMARY KEY NOT NULL,
+	parent_id   TEXT DEFAULT NULL,
+	name        TEXT NOT NULL,
+	slug        TEXT UNIQUE NOT NULL,
+	description TEXT DEFAULT '' NOT NULL,
+	seo         JSON DEFAULT '{}' NOT NULL,
+	sort        INTEGER DEFAULT 0 NOT NULL,
+	active      BOOLEAN DEFAULT TRUE NOT NULL,
+	created     TIMESTAMP DEFAULT (datetime('now')),
+	updated     TIMESTAMP,
+	FOREIGN KEY (parent_id) REFERENCES category(id) ON UPDATE CASCADE ON DELETE SET NULL
+);
+CREATE INDEX idx_category_slug ON category (slug);
+CREATE INDEX idx_category_parent_id ON category (parent_id);
+
+CREATE TABLE product_category (
+	product_id  TEXT NOT NULL,
+	category_id TEXT NOT NULL,
+	PRIMARY KEY (product_id, category_id),
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	FOREIGN KEY (category_id) REFERENCES category(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_product_category_category_id ON product_category (category_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE product_category;
+DROP TABLE category;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE VIRTUAL TABLE product_fts USING fts5(
+	product_id UNINDEXED,
+	name,
+	brief,
+	description,
+	attributes,
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO product_fts (product_id, name, brief, description, attributes)
+SELECT id, name, brief, desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(product.attribute)) FROM product;
+
+CREATE TRIGGER product_fts_insert AFTER INSERT ON product BEGIN
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_update AFTER UPDATE OF id, name, brief, desc, attribute ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_delete AFTER DELETE ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER product_fts_delete;
+DROP TRIGGER product_fts_update;
+DROP TRIGGER product_fts_insert;
+DROP TABLE product_fts;
+-- +goose StatementEnd
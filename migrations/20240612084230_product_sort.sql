@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_product_amount ON product (amount, id);
+CREATE INDEX idx_product_created ON product (created, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_product_created;
+DROP INDEX idx_product_amount;
+-- +goose StatementEnd
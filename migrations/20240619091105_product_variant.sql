@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE product_variant (
+	id          TEXT PRIMARY KEY NOT NULL,
+	product_id  TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	sku         TEXT DEFAULT NULL UNIQUE,
+	amount      NUMERC NOT NULL,
+	sort        INTEGER DEFAULT 0 NOT NULL,
+	active      BOOLEAN DEFAULT TRUE NOT NULL,
+	created     TIMESTAMP DEFAULT (datetime('now')),
+	updated     TIMESTAMP,
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_product_variant_product_id ON product_variant (product_id);
+
+-- files and keys without a variant are delivered with every variant of the product
+ALTER TABLE digital_file ADD COLUMN variant_id TEXT DEFAULT NULL;
+ALTER TABLE digital_data ADD COLUMN variant_id TEXT DEFAULT NULL;
+CREATE INDEX idx_digital_file_variant_id ON digital_file (variant_id);
+CREATE INDEX idx_digital_data_variant_id ON digital_data (variant_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_digital_data_variant_id;
+DROP INDEX idx_digital_file_variant_id;
+ALTER TABLE digital_data DROP COLUMN variant_id;
+ALTER TABLE digital_file DROP COLUMN variant_id;
+DROP TABLE product_variant;
+-- +goose StatementEnd
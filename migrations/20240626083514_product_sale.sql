@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product ADD COLUMN sale_amount NUMERIC DEFAULT NULL;
+ALTER TABLE product ADD COLUMN sale_start TIMESTAMP DEFAULT NULL;
+ALTER TABLE product ADD COLUMN sale_end TIMESTAMP DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE product DROP COLUMN sale_end;
+ALTER TABLE product DROP COLUMN sale_start;
+ALTER TABLE product DROP COLUMN sale_amount;
+-- +goose StatementEnd
@@ -0,0 +1,118 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE product_bundle (
+	bundle_id   TEXT NOT NULL,
+	product_id  TEXT NOT NULL,
+	variant_id  TEXT DEFAULT NULL,
+	sort        INTEGER DEFAULT 0 NOT NULL,
+	PRIMARY KEY (bundle_id, product_id),
+	FOREIGN KEY (bundle_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_product_bundle_product_id ON product_bundle (product_id);
+
+-- the digital type is checked by the table, so the table is rebuilt to accept "bundle",
+-- its indexes and search triggers go with it and are created again
+CREATE TABLE product_new (
+	id                 TEXT PRIMARY KEY NOT NULL,
+	name               TEXT NOT NULL,
+	desc               TEXT NOT NULL,
+	slug               TEXT UNIQUE NOT NULL,
+	amount             NUMERC NOT NULL,
+	metadata           JSON DEFAULT '{}' NOT NULL,
+	attribute          JSON DEFAULT '[]' NOT NULL,
+	digital            TEXT CHECK (digital == 'file' OR digital == 'data' OR digital == 'api' OR digital == 'generated' OR digital == 'bundle'),
+	active             BOOLEAN DEFAULT TRUE NOT NULL,
+	deleted            BOOLEAN DEFAULT FALSE NOT NULL,
+	created            TIMESTAMP DEFAULT (datetime('now')),
+	updated            TIMESTAMP,
+	seo                JSON DEFAULT '{}' NOT NULL,
+	brief              TEXT NOT NULL DEFAULT '',
+	download_limit     INTEGER DEFAULT 0 NOT NULL,
+	download_ip_limit  INTEGER DEFAULT 0 NOT NULL,
+	download_ttl       INTEGER DEFAULT 0 NOT NULL,
+	activation_limit   INTEGER DEFAULT 0 NOT NULL,
+	watermark          BOOLEAN DEFAULT FALSE NOT NULL,
+	sale_amount        NUMERIC DEFAULT NULL,
+	sale_start         TIMESTAMP DEFAULT NULL,
+	sale_end           TIMESTAMP DEFAULT NULL
+);
+INSERT INTO product_new (id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end)
+SELECT id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end FROM product;
+DROP TABLE product;
+ALTER TABLE product_new RENAME TO product;
+CREATE INDEX idx_product_id ON product (id);
+CREATE INDEX idx_product_name ON product (name);
+CREATE INDEX idx_product_slug ON product (slug);
+CREATE INDEX idx_product_amount ON product (amount, id);
+CREATE INDEX idx_product_created ON product (created, id);
+
+CREATE TRIGGER product_fts_insert AFTER INSERT ON product BEGIN
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_update AFTER UPDATE OF id, name, brief, desc, attribute ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_delete AFTER DELETE ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE product_bundle;
+
+CREATE TABLE product_new (
+	id                 TEXT PRIMARY KEY NOT NULL,
+	name               TEXT NOT NULL,
+	desc               TEXT NOT NULL,
+	slug               TEXT UNIQUE NOT NULL,
+	amount             NUMERC NOT NULL,
+	metadata           JSON DEFAULT '{}' NOT NULL,
+	attribute          JSON DEFAULT '[]' NOT NULL,
+	digital            TEXT CHECK (digital == 'file' OR digital == 'data' OR digital == 'api' OR digital == 'generated'),
+	active             BOOLEAN DEFAULT TRUE NOT NULL,
+	deleted            BOOLEAN DEFAULT FALSE NOT NULL,
+	created            TIMESTAMP DEFAULT (datetime('now')),
+	updated            TIMESTAMP,
+	seo                JSON DEFAULT '{}' NOT NULL,
+	brief              TEXT NOT NULL DEFAULT '',
+	download_limit     INTEGER DEFAULT 0 NOT NULL,
+	download_ip_limit  INTEGER DEFAULT 0 NOT NULL,
+	download_ttl       INTEGER DEFAULT 0 NOT NULL,
+	activation_limit   INTEGER DEFAULT 0 NOT NULL,
+	watermark          BOOLEAN DEFAULT FALSE NOT NULL,
+	sale_amount        NUMERIC DEFAULT NULL,
+	sale_start         TIMESTAMP DEFAULT NULL,
+	sale_end           TIMESTAMP DEFAULT NULL
+);
+INSERT INTO product_new (id, name, desc, slug, amount, metadata, attribute, digital, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end)
+SELECT id, name, desc, slug, amount, metadata, attribute, CASE digital WHEN 'bundle' THEN NULL ELSE digital END, active, deleted, created, updated, seo, brief, download_limit, download_ip_limit, download_ttl, activation_limit, watermark, sale_amount, sale_start, sale_end FROM product;
+DROP TABLE product;
+ALTER TABLE product_new RENAME TO product;
+CREATE INDEX idx_product_id ON product (id);
+CREATE INDEX idx_product_name ON product (name);
+CREATE INDEX idx_product_slug ON product (slug);
+CREATE INDEX idx_product_amount ON product (amount, id);
+CREATE INDEX idx_product_created ON product (created, id);
+
+CREATE TRIGGER product_fts_insert AFTER INSERT ON product BEGIN
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_update AFTER UPDATE OF id, name, brief, desc, attribute ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+	INSERT INTO product_fts (product_id, name, brief, description, attributes)
+	VALUES (new.id, new.name, new.brief, new.desc, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.attribute)));
+END;
+
+CREATE TRIGGER product_fts_delete AFTER DELETE ON product BEGIN
+	DELETE FROM product_fts WHERE product_id = old.id;
+END;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a buyer reviews a product once per paid cart, a new submission replaces
+-- the previous one and goes back to moderation
+CREATE TABLE product_review (
+	id          TEXT PRIMARY KEY NOT NULL,
+	product_id  TEXT NOT NULL,
+	cart_id     TEXT NOT NULL,
+	email       TEXT NOT NULL,
+	name        TEXT DEFAULT '' NOT NULL,
+	rating      INTEGER NOT NULL CHECK (rating >= 1 AND rating <= 5),
+	text        TEXT DEFAULT '' NOT NULL,
+	status      TEXT DEFAULT 'pending' NOT NULL CHECK (status == 'pending' OR status == 'approved' OR status == 'rejected'),
+	reply       TEXT DEFAULT '' NOT NULL,
+	created     TIMESTAMP DEFAULT (datetime('now')),
+	updated     TIMESTAMP,
+	UNIQUE (product_id, cart_id),
+	FOREIGN KEY (product_id) REFERENCES product(id) ON UPDATE CASCADE ON DELETE CASCADE,
+	FOREIGN KEY (cart_id) REFERENCES cart(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_product_review_product_id ON product_review (product_id, status);
+CREATE INDEX idx_product_review_status ON product_review (status, created);
+
+INSERT INTO setting VALUES ('Vd6kQm2XsN9pRwA', 'review_active', 'false');
+INSERT INTO setting VALUES ('Jc3tLy8GhB5nZeK', 'review_delay', '3');
+INSERT INTO setting VALUES ('Wr7fDp4MxU1qHsT', 'mail_letter_review', '{"subject":"How do you like your purchase on {{.Site_Name}}?","text":"Hello,\n\nThank you for buying {{.Product_Name}} on the [{{.Site_Name}}] website.\n\nWe would be glad to hear what you think, you can rate it and leave a review here:\n\n{{.Review_URL}}\n\nIf you no longer want to receive these letters, unsubscribe here:\n\n{{.Unsubscribe_URL}}\n\nBest regards,","html":""}');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM setting WHERE id IN ('Vd6kQm2XsN9pRwA', 'Jc3tLy8GhB5nZeK', 'Wr7fDp4MxU1qHsT');
+DROP TABLE product_review;
+-- +goose StatementEnd
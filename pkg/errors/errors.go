@@ -10,9 +10,32 @@ const (
 	MsgUserPasswordNotFound = "not found user password"
 	MsgUserEmailNotFound    = "user with the given email is not found"
 
-	MsgProductNotFound = "product not found"
-	MsgPageNotFound    = "page not found"
-	MsgSettingNotFound = "setting not found"
+	MsgProductNotFound  = "product not found"
+	MsgProductSold      = "a product in a paid or pending cart can not be deleted forever, its buyers keep their downloads and keys"
+	MsgPageNotFound     = "page not found"
+	MsgCategoryNotFound = "category not found"
+	MsgCategoryExists   = "category with this slug already exists"
+	MsgCategoryLoop     = "category can not be moved into its own subcategory"
+	MsgVariantNotFound  = "variant not found"
+	MsgVariantExists    = "variant with this sku already exists"
+	MsgVariantRequired  = "a variant of the product has to be chosen"
+	MsgVariantSale      = "a product with variants can not be put on sale, change the prices of its variants instead"
+	MsgActiveVersion    = "the active version of a file can only be replaced by activating another version"
+	MsgDigitalNotData   = "keys can only be imported into a product that delivers keys"
+	MsgBundleComponent  = "a bundle can only contain other existing products, each once, that are not bundles"
+	MsgCatalogInvalid   = "catalog is not a zip archive or a json or csv list of products"
+	MsgSettingNotFound  = "setting not found"
+
+	MsgCustomerNotFound = "customer not found"
+	MsgCartNotFound     = "cart not found"
+	MsgInvoiceNotFound  = "invoice not found"
+	MsgLicenseNotFound  = "license not found"
+	MsgReviewNotFound   = "review not found"
+	MsgReviewNotBought  = "only a product of the paid cart can be reviewed"
+
+	MsgDownloadLimit   = "download limit reached"
+	MsgOutOfStock      = "not enough keys in stock"
+	MsgActivationLimit = "activation limit reached"
 )
 
 var (
@@ -23,7 +46,30 @@ var (
 	ErrUserPasswordNotFound = errors.New(MsgUserPasswordNotFound)
 	ErrUserEmailNotFound    = errors.New(MsgUserEmailNotFound)
 
-	ErrProductNotFound = errors.New(MsgProductNotFound)
-	ErrPageNotFound    = errors.New(MsgPageNotFound)
-	ErrSettingNotFound = errors.New(MsgSettingNotFound)
+	ErrProductNotFound  = errors.New(MsgProductNotFound)
+	ErrProductSold      = errors.New(MsgProductSold)
+	ErrPageNotFound     = errors.New(MsgPageNotFound)
+	ErrCategoryNotFound = errors.New(MsgCategoryNotFound)
+	ErrCategoryExists   = errors.New(MsgCategoryExists)
+	ErrCategoryLoop     = errors.New(MsgCategoryLoop)
+	ErrVariantNotFound  = errors.New(MsgVariantNotFound)
+	ErrVariantExists    = errors.New(MsgVariantExists)
+	ErrVariantRequired  = errors.New(MsgVariantRequired)
+	ErrVariantSale      = errors.New(MsgVariantSale)
+	ErrActiveVersion    = errors.New(MsgActiveVersion)
+	ErrDigitalNotData   = errors.New(MsgDigitalNotData)
+	ErrBundleComponent  = errors.New(MsgBundleComponent)
+	ErrCatalogInvalid   = errors.New(MsgCatalogInvalid)
+	ErrSettingNotFound  = errors.New(MsgSettingNotFound)
+
+	ErrCustomerNotFound = errors.New(MsgCustomerNotFound)
+	ErrCartNotFound     = errors.New(MsgCartNotFound)
+	ErrInvoiceNotFound  = errors.New(MsgInvoiceNotFound)
+	ErrLicenseNotFound  = errors.New(MsgLicenseNotFound)
+	ErrReviewNotFound   = errors.New(MsgReviewNotFound)
+	ErrReviewNotBought  = errors.New(MsgReviewNotBought)
+
+	ErrDownloadLimit   = errors.New(MsgDownloadLimit)
+	ErrOutOfStock      = errors.New(MsgOutOfStock)
+	ErrActivationLimit = errors.New(MsgActivationLimit)
 )
@@ -0,0 +1,183 @@
+package license
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// DefaultCharset leaves out the characters that are easy to confuse, like 0 and O.
+const DefaultCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Placeholder is the character of a pattern that is replaced with a random one.
+const Placeholder = 'X'
+
+var (
+	ErrInvalidPattern = errors.New("pattern needs at least four placeholders")
+	ErrInvalidCharset = errors.New("charset needs at least two distinct characters")
+	ErrInvalidLicense = errors.New("invalid license")
+)
+
+// Generate returns a key that follows the pattern, every placeholder is replaced with a random
+// character of the charset. With checksum the last placeholder holds a check character, so
+// mistyped keys can be rejected without a lookup.
+func Generate(pattern, charset string, checksum bool) (string, error) {
+	if err := validate(pattern, charset); err != nil {
+		return "", err
+	}
+
+	key := []rune(pattern)
+	max := big.NewInt(int64(len(charset)))
+	positions := placeholders(key)
+	if checksum {
+		positions = positions[:len(positions)-1]
+	}
+
+	for _, i := range positions {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		key[i] = rune(charset[n.Int64()])
+	}
+
+	if checksum {
+		key[lastPlaceholder(pattern)] = rune(check(key, positions, charset))
+	}
+
+	return string(key), nil
+}
+
+// Valid reports whether a key follows the pattern and, with checksum, carries the right check character.
+func Valid(key, pattern, charset string, checksum bool) bool {
+	if validate(pattern, charset) != nil {
+		return false
+	}
+
+	runes, tmpl := []rune(key), []rune(pattern)
+	if len(runes) != len(tmpl) {
+		return false
+	}
+
+	for i := range tmpl {
+		if tmpl[i] == Placeholder {
+			if !strings.ContainsRune(charset, runes[i]) {
+				return false
+			}
+		} else if runes[i] != tmpl[i] {
+			return false
+		}
+	}
+
+	if checksum {
+		positions := placeholders(tmpl)
+		positions = positions[:len(positions)-1]
+		return runes[lastPlaceholder(pattern)] == rune(check(runes, positions, charset))
+	}
+
+	return true
+}
+
+// Claims is the signed content of a license.
+type Claims struct {
+	Email     string `json:"email"`
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity,omitempty"`
+	Issued    int64  `json:"issued"`
+}
+
+// Sign returns a license that carries the claims and their Ed25519 signature,
+// both encoded as base64url and joined with a ".".
+func Sign(key ed25519.PrivateKey, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signature := ed25519.Sign(key, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks a license created by Sign with the public key and returns its claims.
+func Verify(key ed25519.PublicKey, license string) (*Claims, error) {
+	encoded, sig, ok := strings.Cut(license, ".")
+	if !ok {
+		return nil, ErrInvalidLicense
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidLicense
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !ed25519.Verify(key, payload, signature) {
+		return nil, ErrInvalidLicense
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidLicense
+	}
+
+	return claims, nil
+}
+
+// PublicKeyPEM encodes the public key of a signing key in PKIX form, as expected by most crypto libraries.
+func PublicKeyPEM(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func validate(pattern, charset string) error {
+	if strings.Count(pattern, string(Placeholder)) < 4 {
+		return ErrInvalidPattern
+	}
+
+	distinct := map[rune]bool{}
+	for _, r := range charset {
+		if r > 127 {
+			return ErrInvalidCharset
+		}
+		distinct[r] = true
+	}
+	if len(distinct) < 2 || len(distinct) != len(charset) {
+		return ErrInvalidCharset
+	}
+
+	return nil
+}
+
+func placeholders(pattern []rune) []int {
+	positions := []int{}
+	for i, r := range pattern {
+		if r == Placeholder {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+func lastPlaceholder(pattern string) int {
+	positions := placeholders([]rune(pattern))
+	return positions[len(positions)-1]
+}
+
+// check computes a weighted sum of the character positions in the charset,
+// so a swap of two characters changes the check character as well.
+func check(key []rune, positions []int, charset string) byte {
+	sum := 0
+	for weight, i := range positions {
+		sum += (weight + 1) * strings.IndexRune(charset, key[i])
+	}
+	return charset[sum%len(charset)]
+}
@@ -0,0 +1,72 @@
+package license
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		charset  string
+		checksum bool
+		err      error
+	}{
+		{"XXXX-XXXX-XXXX", DefaultCharset, false, nil},
+		{"XXXX-XXXX-XXXX", DefaultCharset, true, nil},
+		{"PRO-XXXXX", "0123456789", true, nil},
+		{"XXX", DefaultCharset, false, ErrInvalidPattern},
+		{"XXXX-XXXX", "A", false, ErrInvalidCharset},
+		{"XXXX-XXXX", "AAB", false, ErrInvalidCharset},
+	}
+
+	for _, tt := range cases {
+		key, err := Generate(tt.pattern, tt.charset, tt.checksum)
+		assert.Equal(t, tt.err, err)
+		if err != nil {
+			continue
+		}
+		assert.Len(t, key, len(tt.pattern))
+		assert.True(t, Valid(key, tt.pattern, tt.charset, tt.checksum), key)
+	}
+}
+
+func TestValidChecksum(t *testing.T) {
+	pattern := "XXXX-XXXX-XXXX"
+	key, err := Generate(pattern, DefaultCharset, true)
+	assert.NoError(t, err)
+
+	// change the first character to any other character of the charset
+	other := strings.Replace(DefaultCharset, key[:1], "", 1)[:1]
+	assert.False(t, Valid(other+key[1:], pattern, DefaultCharset, true))
+	assert.False(t, Valid(key[:len(key)-1], pattern, DefaultCharset, true))
+	assert.False(t, Valid(strings.ReplaceAll(key, "-", "_"), pattern, DefaultCharset, true))
+}
+
+func TestSignVerify(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	claims := Claims{Email: "user@mail.com", ProductID: "fv6c9s9cqzf36sc", Issued: 1700000000}
+	license, err := Sign(key, claims)
+	assert.NoError(t, err)
+
+	verified, err := Verify(key.Public().(ed25519.PublicKey), license)
+	assert.NoError(t, err)
+	assert.Equal(t, claims, *verified)
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	_, err = Verify(other.Public().(ed25519.PublicKey), license)
+	assert.Equal(t, ErrInvalidLicense, err)
+
+	_, err = Verify(key.Public().(ed25519.PublicKey), "A"+license[1:])
+	assert.Equal(t, ErrInvalidLicense, err)
+
+	pem, err := PublicKeyPEM(key)
+	assert.NoError(t, err)
+	assert.Contains(t, string(pem), "BEGIN PUBLIC KEY")
+}
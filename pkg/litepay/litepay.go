@@ -9,6 +9,8 @@ const (
 	CANCELED  Status = "canceled"
 	FAILED    Status = "failed"
 	PROCESSED Status = "processed"
+	REFUNDED  Status = "refunded"
+	EXPIRED   Status = "expired"
 	TEST      Status = "test"
 )
 
@@ -0,0 +1,72 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature has expired")
+)
+
+// Sign returns a URL-safe token that carries the payload and its expiration
+// time, signed with HMAC-SHA256. The payload must not contain a "|".
+func Sign(secret, payload string, expires time.Time) string {
+	message := payload + "|" + strconv.FormatInt(expires.Unix(), 10)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(message))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature(secret, message))
+}
+
+// Verify checks the signature and expiration of a token created by Sign
+// and returns the signed payload.
+func Verify(secret, token string) (string, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+
+	message, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	expected, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, signature(secret, string(message))) {
+		return "", ErrInvalidSignature
+	}
+
+	payload, expiresStr, ok := strings.Cut(string(message), "|")
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return "", ErrExpiredSignature
+	}
+
+	return payload, nil
+}
+
+// SignBody returns the hex encoded HMAC-SHA256 of a request body, so the
+// receiver can check that the request was sent with the shared secret.
+func SignBody(secret string, body []byte) string {
+	return hex.EncodeToString(signature(secret, string(body)))
+}
+
+func signature(secret, message string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
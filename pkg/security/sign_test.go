@@ -0,0 +1,42 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "d58ca30c8e5ca96695451fa27af949d9"
+	token := Sign(secret, "cart:file", time.Now().Add(time.Hour))
+
+	payload, err := Verify(secret, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "cart:file", payload)
+
+	cases := []struct {
+		name   string
+		secret string
+		token  string
+		err    error
+	}{
+		{"wrong secret", "other", token, ErrInvalidSignature},
+		{"tampered payload", secret, Sign(secret, "cart:other", time.Now().Add(time.Hour))[:10] + token[10:], ErrInvalidSignature},
+		{"malformed", secret, "token", ErrInvalidSignature},
+		{"expired", secret, Sign(secret, "cart:file", time.Now().Add(-time.Minute)), ErrExpiredSignature},
+	}
+
+	for _, tt := range cases {
+		_, err := Verify(tt.secret, tt.token)
+		assert.Equal(t, tt.err, err, tt.name)
+	}
+}
+
+func TestSignBody(t *testing.T) {
+	body := []byte(`{"cart_id":"cart"}`)
+
+	assert.Equal(t, SignBody("secret", body), SignBody("secret", body))
+	assert.NotEqual(t, SignBody("secret", body), SignBody("other", body))
+	assert.Len(t, SignBody("secret", body), 64)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("object not found")
+	ErrNotSupported = errors.New("not supported by the storage")
+	ErrInvalidKey   = errors.New("invalid object key")
+)
+
+// Storage keeps uploads and digital files. Keys are slash separated paths
+// such as lc_digitals/<name>.<ext>, the local storage maps them to files
+// under its root and the s3 storage to objects of its bucket.
+type Storage interface {
+	// Put stores the content of the reader under the key, size is the
+	// number of bytes to read or -1 when it is unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the object, the caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Stat returns the object without its content.
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object, a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with the prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL returns a temporary link the object can be fetched from directly,
+	// offered for download as name when it is not empty. ErrNotSupported is
+	// returned when the objects are only served through Get.
+	URL(ctx context.Context, key, name string) (string, error)
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// ContentType returns the media type of an object by the extension of its key.
+func ContentType(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// ReadAll returns the content of the object.
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	r, _, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// cleanKey rejects keys that would leave the root of the storage. Backslashes are
+// refused as well, they separate the path on Windows.
+func cleanKey(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") ||
+		strings.Contains(key, "\\") || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
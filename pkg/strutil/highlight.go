@@ -0,0 +1,57 @@
+package strutil
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Markers that wrap the matched words of a search snippet, they match the
+// char(1) and char(2) arguments of the FTS5 snippet function.
+const (
+	MarkStart = "\x01"
+	MarkEnd   = "\x02"
+	Ellipsis  = "…"
+)
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>?`)
+
+// Highlight turns a search snippet cut out of HTML or plain text into safe
+// HTML: tags and tags cut in half are dropped, the text is escaped and the
+// matched words are wrapped in <mark>.
+func Highlight(s string) string {
+	// a snippet cut in the middle of a tag starts with the rest of it
+	if rest, ok := strings.CutPrefix(s, Ellipsis); ok {
+		if end, start := strings.IndexByte(rest, '>'), strings.IndexByte(rest, '<'); end >= 0 && (start < 0 || end < start) {
+			s = Ellipsis + rest[end+1:]
+		}
+	}
+
+	s = tagRegexp.ReplaceAllString(s, " ")
+	s = html.EscapeString(html.UnescapeString(s))
+	s = strings.Join(strings.Fields(s), " ")
+
+	var b strings.Builder
+	open := false
+	for _, r := range s {
+		switch string(r) {
+		case MarkStart:
+			if !open {
+				b.WriteString("<mark>")
+				open = true
+			}
+		case MarkEnd:
+			if open {
+				b.WriteString("</mark>")
+				open = false
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,27 @@
+package strutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{"plain text", "Video \x01plugin\x02 for editors", "Video <mark>plugin</mark> for editors"},
+		{"html", "<p>The <strong>\x01plugin\x02</strong> &amp; more</p>", "The <mark>plugin</mark> &amp; more"},
+		{"cut tag", "…ass=\"x\">best \x01plugin\x02 <a href=\"/x", "…best <mark>plugin</mark>"},
+		{"script", "<script>alert(1)</script> \x01plugin\x02 &lt;b&gt;", "alert(1) <mark>plugin</mark> &lt;b&gt;"},
+		{"unbalanced", "\x02plugin\x01 pack", "plugin<mark> pack</mark>"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Highlight(tt.snippet))
+		})
+	}
+}
@@ -0,0 +1,947 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PDF stamps the mark as a footer on every page of the document.
+//
+// The document is not rewritten: the changed pages, the footer streams and a
+// new cross-reference section are appended as an incremental update, so the
+// original objects stay byte for byte as they were. Encrypted documents and
+// streams compressed with anything but Flate are not supported.
+func PDF(data []byte, mark Mark) ([]byte, error) {
+	doc, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := doc.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	root, ok := doc.trailer["Root"].(pdfRef)
+	if !ok {
+		return nil, ErrInvalidPDF
+	}
+	catalog, ok := doc.resolve(root).(pdfDict)
+	if !ok {
+		return nil, ErrInvalidPDF
+	}
+
+	pages := []pdfPage{}
+	if err := doc.pages(catalog["Pages"], map[pdfName]any{}, map[int]bool{}, &pages); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrInvalidPDF
+	}
+
+	size, ok := intValue(doc.trailer["Size"])
+	if !ok {
+		return nil, ErrInvalidPDF
+	}
+
+	update := &pdfUpdate{next: size}
+	font := update.add(pdfDict{
+		"Type":     pdfName("Font"),
+		"Subtype":  pdfName("Type1"),
+		"BaseFont": pdfName("Helvetica"),
+		"Encoding": pdfName("WinAnsiEncoding"),
+	})
+	// the original content is wrapped in q ... Q, so whatever it leaves in the
+	// graphics state does not move or hide the footer
+	save := update.add(&pdfStream{dict: pdfDict{}, data: []byte("q\n")})
+
+	for _, page := range pages {
+		dict := page.dict.clone()
+
+		resources, _ := doc.resolve(page.inherited["Resources"]).(pdfDict)
+		resources = resources.clone()
+		fonts, _ := doc.resolve(resources["Font"]).(pdfDict)
+		fonts = fonts.clone()
+		fonts["LCWatermark"] = font
+		resources["Font"] = fonts
+		dict["Resources"] = resources
+
+		box := doc.box(page.inherited["CropBox"])
+		if box == nil {
+			box = doc.box(page.inherited["MediaBox"])
+		}
+		if box == nil {
+			box = []float64{0, 0, 612, 792}
+		}
+		footer := fmt.Sprintf("Q q 0.5 g BT /LCWatermark 8 Tf %.2f %.2f Td %s Tj ET Q\n", box[0]+24, box[1]+12, pdfText(mark.String()))
+
+		contents := pdfArray{save}
+		switch value := dict["Contents"].(type) {
+		case pdfArray:
+			contents = append(contents, value...)
+		case pdfRef:
+			if array, ok := doc.resolve(value).(pdfArray); ok {
+				contents = append(contents, array...)
+			} else {
+				contents = append(contents, value)
+			}
+		}
+		contents = append(contents, update.add(&pdfStream{dict: pdfDict{}, data: []byte(footer)}))
+		dict["Contents"] = contents
+
+		update.set(page.ref, dict)
+	}
+
+	return update.write(doc), nil
+}
+
+type (
+	pdfName  string
+	pdfRaw   string
+	pdfArray []any
+	pdfDict  map[pdfName]any
+	pdfRef   struct{ num, gen int }
+)
+
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+func (d pdfDict) clone() pdfDict {
+	out := pdfDict{}
+	for key, value := range d {
+		out[key] = value
+	}
+	return out
+}
+
+type pdfPage struct {
+	ref       pdfRef
+	dict      pdfDict
+	inherited map[pdfName]any
+}
+
+// pdfEntry is an entry of the cross-reference table, kind 1 is an object at
+// an offset of the file and kind 2 an object inside an object stream.
+type pdfEntry struct {
+	kind   int
+	offset int
+	index  int
+	gen    int
+}
+
+type pdfDoc struct {
+	data       []byte
+	xref       map[int]pdfEntry
+	trailer    pdfDict
+	startxref  int
+	xrefStream bool
+	objStms    map[int]*pdfObjStm
+}
+
+// pdfObjStm is a decoded object stream, the objects start at first.
+type pdfObjStm struct {
+	data  []byte
+	first int
+	count int
+}
+
+func openPDF(data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, ErrInvalidPDF
+	}
+
+	doc := &pdfDoc{data: data, xref: map[int]pdfEntry{}, objStms: map[int]*pdfObjStm{}}
+
+	tail := 0
+	if len(data) > 2048 {
+		tail = len(data) - 2048
+	}
+	i := bytes.LastIndex(data[tail:], []byte("startxref"))
+	if i < 0 {
+		return nil, ErrInvalidPDF
+	}
+	l := &pdfLexer{data: data, pos: tail + i + len("startxref")}
+	offset, err := strconv.Atoi(l.token())
+	if err != nil {
+		return nil, ErrInvalidPDF
+	}
+	doc.startxref = offset
+
+	seen := map[int]bool{}
+	for first := true; offset > 0 && !seen[offset]; first = false {
+		seen[offset] = true
+		trailer, isStream, err := doc.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			doc.trailer, doc.xrefStream = trailer, isStream
+		}
+		// hybrid files keep the compressed objects in an additional stream
+		if stm, ok := intValue(trailer["XRefStm"]); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := doc.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+		prev, ok := intValue(trailer["Prev"])
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	if doc.trailer == nil {
+		return nil, ErrInvalidPDF
+	}
+
+	return doc, nil
+}
+
+// readXref reads a cross-reference section, entries of newer sections that
+// were read before take precedence.
+func (d *pdfDoc) readXref(offset int) (pdfDict, bool, error) {
+	if offset >= len(d.data) {
+		return nil, false, ErrInvalidPDF
+	}
+
+	l := &pdfLexer{data: d.data, pos: offset}
+	if l.token() == "xref" {
+		for {
+			token := l.token()
+			if token == "trailer" {
+				trailer, ok := l.object().(pdfDict)
+				if !ok {
+					return nil, false, ErrInvalidPDF
+				}
+				return trailer, false, nil
+			}
+			start, err1 := strconv.Atoi(token)
+			count, err2 := strconv.Atoi(l.token())
+			if err1 != nil || err2 != nil {
+				return nil, false, ErrInvalidPDF
+			}
+			for i := 0; i < count; i++ {
+				offset, err1 := strconv.Atoi(l.token())
+				gen, err2 := strconv.Atoi(l.token())
+				kind := l.token()
+				if err1 != nil || err2 != nil {
+					return nil, false, ErrInvalidPDF
+				}
+				if _, ok := d.xref[start+i]; !ok && kind == "n" {
+					d.xref[start+i] = pdfEntry{kind: 1, offset: offset, gen: gen}
+				}
+			}
+		}
+	}
+
+	_, obj, err := d.readObject(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	stream, ok := obj.(*pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("XRef") {
+		return nil, false, ErrInvalidPDF
+	}
+	data, err := d.decode(stream)
+	if err != nil {
+		return nil, false, err
+	}
+
+	widths, _ := d.resolve(stream.dict["W"]).(pdfArray)
+	if len(widths) != 3 {
+		return nil, false, ErrInvalidPDF
+	}
+	w := make([]int, 3)
+	for i := range w {
+		if w[i], ok = intValue(widths[i]); !ok || w[i] < 0 || w[i] > 8 {
+			return nil, false, ErrInvalidPDF
+		}
+	}
+
+	index, _ := d.resolve(stream.dict["Index"]).(pdfArray)
+	if index == nil {
+		size, _ := intValue(stream.dict["Size"])
+		index = pdfArray{pdfRaw("0"), pdfRaw(strconv.Itoa(size))}
+	}
+
+	field := func(pos, width, def int) int {
+		if width == 0 {
+			return def
+		}
+		value := 0
+		for _, b := range data[pos : pos+width] {
+			value = value<<8 | int(b)
+		}
+		return value
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, ok1 := intValue(index[i])
+		count, ok2 := intValue(index[i+1])
+		if !ok1 || !ok2 {
+			return nil, false, ErrInvalidPDF
+		}
+		for j := 0; j < count; j++ {
+			if pos+w[0]+w[1]+w[2] > len(data) {
+				return nil, false, ErrInvalidPDF
+			}
+			kind := field(pos, w[0], 1)
+			f2 := field(pos+w[0], w[1], 0)
+			f3 := field(pos+w[0]+w[1], w[2], 0)
+			pos += w[0] + w[1] + w[2]
+
+			if _, ok := d.xref[start+j]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				d.xref[start+j] = pdfEntry{kind: 1, offset: f2, gen: f3}
+			case 2:
+				d.xref[start+j] = pdfEntry{kind: 2, offset: f2, index: f3}
+			}
+		}
+	}
+
+	return stream.dict, true, nil
+}
+
+// readObject reads the indirect object that starts at the offset.
+func (d *pdfDoc) readObject(offset int) (int, any, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return 0, nil, ErrInvalidPDF
+	}
+
+	l := &pdfLexer{data: d.data, pos: offset}
+	num, err := strconv.Atoi(l.token())
+	if err != nil {
+		return 0, nil, ErrInvalidPDF
+	}
+	if _, err := strconv.Atoi(l.token()); err != nil || l.token() != "obj" {
+		return 0, nil, ErrInvalidPDF
+	}
+
+	obj := l.object()
+	if l.err != nil {
+		return 0, nil, l.err
+	}
+
+	dict, ok := obj.(pdfDict)
+	if !ok {
+		return num, obj, nil
+	}
+
+	save := l.pos
+	if l.token() != "stream" {
+		l.pos = save
+		return num, obj, nil
+	}
+
+	// the data starts after the end of line that follows the keyword
+	if l.pos < len(d.data) && d.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(d.data) && d.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	length, ok := -1, false
+	if ref, isRef := dict["Length"].(pdfRef); isRef {
+		if entry, found := d.xref[ref.num]; found && entry.kind == 1 && entry.offset != offset {
+			length, ok = intValue(d.resolve(ref))
+		}
+	} else {
+		length, ok = intValue(dict["Length"])
+	}
+	end := start + length
+	if !ok || length < 0 || end > len(d.data) || !bytes.HasPrefix(bytes.TrimLeft(d.data[end:], "\x00\t\n\f\r "), []byte("endstream")) {
+		i := bytes.Index(d.data[start:], []byte("endstream"))
+		if i < 0 {
+			return 0, nil, ErrInvalidPDF
+		}
+		end = start + i
+		for end > start && (d.data[end-1] == '\n' || d.data[end-1] == '\r') {
+			end--
+		}
+	}
+
+	return num, &pdfStream{dict: dict, data: d.data[start:end]}, nil
+}
+
+// object returns the object with the number, a missing object is null.
+func (d *pdfDoc) object(num int) any {
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil
+	}
+
+	if entry.kind == 1 {
+		n, obj, err := d.readObject(entry.offset)
+		if err != nil || n != num {
+			return nil
+		}
+		return obj
+	}
+
+	objStm, ok := d.objStms[entry.offset]
+	if !ok {
+		stream, isStream := d.object(entry.offset).(*pdfStream)
+		if !isStream {
+			return nil
+		}
+		data, err := d.decode(stream)
+		if err != nil {
+			return nil
+		}
+		first, _ := intValue(stream.dict["First"])
+		count, _ := intValue(stream.dict["N"])
+		if first <= 0 || first > len(data) {
+			return nil
+		}
+		objStm = &pdfObjStm{data: data, first: first, count: count}
+		d.objStms[entry.offset] = objStm
+	}
+	if entry.index >= objStm.count {
+		return nil
+	}
+	data, first := objStm.data, objStm.first
+
+	l := &pdfLexer{data: data[:first]}
+	offset := -1
+	for i := 0; i <= entry.index; i++ {
+		n, err1 := strconv.Atoi(l.token())
+		o, err2 := strconv.Atoi(l.token())
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		if i == entry.index && n == num {
+			offset = o
+		}
+	}
+	if offset < 0 || first+offset >= len(data) {
+		return nil
+	}
+
+	l = &pdfLexer{data: data, pos: first + offset}
+	obj := l.object()
+	if l.err != nil {
+		return nil
+	}
+	return obj
+}
+
+// resolve follows references until it reaches a direct object.
+func (d *pdfDoc) resolve(value any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = d.object(ref.num)
+	}
+	return nil
+}
+
+// pages collects the pages of the page tree along with the attributes they
+// inherit from their ancestors.
+func (d *pdfDoc) pages(node any, inherited map[pdfName]any, seen map[int]bool, out *[]pdfPage) error {
+	ref, ok := node.(pdfRef)
+	if !ok || seen[ref.num] {
+		return ErrInvalidPDF
+	}
+	seen[ref.num] = true
+
+	dict, ok := d.resolve(ref).(pdfDict)
+	if !ok {
+		return ErrInvalidPDF
+	}
+
+	attributes := map[pdfName]any{}
+	for key, value := range inherited {
+		attributes[key] = value
+	}
+	for _, key := range []pdfName{"Resources", "MediaBox", "CropBox"} {
+		if value, ok := dict[key]; ok {
+			attributes[key] = value
+		}
+	}
+
+	kids, isNode := d.resolve(dict["Kids"]).(pdfArray)
+	if dict["Type"] == pdfName("Page") || !isNode {
+		*out = append(*out, pdfPage{ref: ref, dict: dict, inherited: attributes})
+		return nil
+	}
+
+	for _, kid := range kids {
+		if err := d.pages(kid, attributes, seen, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// box returns the four numbers of a page rectangle.
+func (d *pdfDoc) box(value any) []float64 {
+	array, ok := d.resolve(value).(pdfArray)
+	if !ok || len(array) != 4 {
+		return nil
+	}
+
+	box := make([]float64, 4)
+	for i, v := range array {
+		raw, ok := d.resolve(v).(pdfRaw)
+		if !ok {
+			return nil
+		}
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return nil
+		}
+		box[i] = f
+	}
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	return box
+}
+
+// decode returns the decoded data of a stream.
+func (d *pdfDoc) decode(stream *pdfStream) ([]byte, error) {
+	filter := d.resolve(stream.dict["Filter"])
+	params := d.resolve(stream.dict["DecodeParms"])
+	if array, ok := filter.(pdfArray); ok {
+		if len(array) > 1 {
+			return nil, ErrUnsupported
+		}
+		filter = nil
+		if len(array) == 1 {
+			filter = d.resolve(array[0])
+		}
+		if array, ok := params.(pdfArray); ok && len(array) > 0 {
+			params = d.resolve(array[0])
+		}
+	}
+
+	switch filter {
+	case nil:
+		return stream.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, ErrUnsupported
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(stream.data))
+	if err != nil {
+		return nil, ErrInvalidPDF
+	}
+	data, err := io.ReadAll(r)
+	if err != nil && len(data) == 0 {
+		return nil, ErrInvalidPDF
+	}
+
+	dict, _ := params.(pdfDict)
+	predictor, _ := intValue(d.resolve(dict["Predictor"]))
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, ErrUnsupported
+		}
+		return data, nil
+	}
+
+	columns, ok := intValue(d.resolve(dict["Columns"]))
+	if !ok {
+		columns = 1
+	}
+	return unpredict(data, columns)
+}
+
+// unpredict reverses the PNG predictors, every row starts with the type of its filter.
+func unpredict(data []byte, columns int) ([]byte, error) {
+	if columns <= 0 {
+		return nil, ErrInvalidPDF
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, columns)
+	for i := 0; i+1+columns <= len(data); i += columns + 1 {
+		kind, row := data[i], append([]byte{}, data[i+1:i+1+columns]...)
+		for j := range row {
+			var left, upperLeft byte
+			if j > 0 {
+				left, upperLeft = row[j-1], prev[j-1]
+			}
+			up := prev[j]
+			switch kind {
+			case 0:
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upperLeft)
+			default:
+				return nil, ErrInvalidPDF
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func intValue(value any) (int, bool) {
+	raw, ok := value.(pdfRaw)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(raw))
+	return n, err == nil
+}
+
+// pdfText returns the text as a literal string, characters outside of
+// printable ASCII are replaced as the standard fonts can not show them.
+func pdfText(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func isSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skip() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a keyword or a number.
+func (l *pdfLexer) token() string {
+	l.skip()
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// object reads a direct object or a reference. Strings, numbers and keywords
+// are kept as they were written, they are only copied to the update.
+func (l *pdfLexer) object() any {
+	l.skip()
+	if l.err != nil || l.pos >= len(l.data) {
+		l.err = ErrInvalidPDF
+		return nil
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			l.skip()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return dict
+			}
+			key, ok := l.object().(pdfName)
+			if !ok {
+				l.err = ErrInvalidPDF
+				return nil
+			}
+			value := l.object()
+			if l.err != nil {
+				return nil
+			}
+			dict[key] = value
+		}
+
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			l.err = ErrInvalidPDF
+			return nil
+		}
+		raw := pdfRaw(l.data[l.pos : l.pos+end+1])
+		l.pos += end + 1
+		return raw
+
+	case c == '(':
+		start, depth := l.pos, 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					l.pos++
+					return pdfRaw(l.data[start:l.pos])
+				}
+			}
+		}
+		l.err = ErrInvalidPDF
+		return nil
+
+	case c == '[':
+		l.pos++
+		array := pdfArray{}
+		for {
+			l.skip()
+			if l.pos >= len(l.data) {
+				l.err = ErrInvalidPDF
+				return nil
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return array
+			}
+			value := l.object()
+			if l.err != nil {
+				return nil
+			}
+			array = append(array, value)
+		}
+
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(l.data[start:l.pos])
+	}
+
+	token := l.token()
+	if token == "" {
+		l.err = ErrInvalidPDF
+		return nil
+	}
+	if token == "null" {
+		return nil
+	}
+
+	// a reference is written as "num gen R"
+	if num, err := strconv.Atoi(token); err == nil {
+		save := l.pos
+		if gen, err := strconv.Atoi(l.token()); err == nil && l.token() == "R" {
+			return pdfRef{num: num, gen: gen}
+		}
+		l.pos = save
+	}
+	return pdfRaw(token)
+}
+
+func writeObject(b *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case pdfRaw:
+		b.WriteString(string(v))
+	case pdfName:
+		b.WriteString("/" + string(v))
+	case pdfRef:
+		fmt.Fprintf(b, "%d %d R", v.num, v.gen)
+	case pdfArray:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeObject(b, item)
+		}
+		b.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, string(key))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, key := range keys {
+			b.WriteString("/" + key + " ")
+			writeObject(b, v[pdfName(key)])
+			b.WriteByte(' ')
+		}
+		b.WriteString(">>")
+	case *pdfStream:
+		dict := v.dict.clone()
+		dict["Length"] = pdfRaw(strconv.Itoa(len(v.data)))
+		writeObject(b, dict)
+		b.WriteString("\nstream\n")
+		b.Write(v.data)
+		b.WriteString("\nendstream")
+	}
+}
+
+type pdfObject struct {
+	ref   pdfRef
+	value any
+}
+
+// pdfUpdate collects the objects of an incremental update.
+type pdfUpdate struct {
+	objects []pdfObject
+	next    int
+}
+
+func (u *pdfUpdate) add(value any) pdfRef {
+	ref := pdfRef{num: u.next}
+	u.next++
+	u.set(ref, value)
+	return ref
+}
+
+func (u *pdfUpdate) set(ref pdfRef, value any) {
+	u.objects = append(u.objects, pdfObject{ref: ref, value: value})
+}
+
+// write appends the objects to the document followed by a cross-reference
+// section of the same kind as the last one of the document.
+func (u *pdfUpdate) write(doc *pdfDoc) []byte {
+	var b bytes.Buffer
+	b.Write(doc.data)
+	if !bytes.HasSuffix(doc.data, []byte("\n")) {
+		b.WriteByte('\n')
+	}
+
+	entries := map[int]pdfEntry{}
+	for _, obj := range u.objects {
+		entries[obj.ref.num] = pdfEntry{kind: 1, offset: b.Len(), gen: obj.ref.gen}
+		fmt.Fprintf(&b, "%d %d obj\n", obj.ref.num, obj.ref.gen)
+		writeObject(&b, obj.value)
+		b.WriteString("\nendobj\n")
+	}
+
+	trailer := pdfDict{
+		"Root": doc.trailer["Root"],
+		"Prev": pdfRaw(strconv.Itoa(doc.startxref)),
+	}
+	for _, key := range []pdfName{"Info", "ID"} {
+		if value, ok := doc.trailer[key]; ok {
+			trailer[key] = value
+		}
+	}
+
+	startxref := b.Len()
+	if doc.xrefStream {
+		num := u.next
+		u.next++
+		entries[num] = pdfEntry{kind: 1, offset: startxref}
+		trailer["Size"] = pdfRaw(strconv.Itoa(u.next))
+
+		index, data := pdfArray{}, []byte{}
+		for _, group := range xrefGroups(entries) {
+			index = append(index, pdfRaw(strconv.Itoa(group[0])), pdfRaw(strconv.Itoa(len(group))))
+			for _, n := range group {
+				e := entries[n]
+				data = append(data, 1, byte(e.offset>>24), byte(e.offset>>16), byte(e.offset>>8), byte(e.offset), byte(e.gen>>8), byte(e.gen))
+			}
+		}
+		trailer["Type"] = pdfName("XRef")
+		trailer["W"] = pdfArray{pdfRaw("1"), pdfRaw("4"), pdfRaw("2")}
+		trailer["Index"] = index
+
+		fmt.Fprintf(&b, "%d 0 obj\n", num)
+		writeObject(&b, &pdfStream{dict: trailer, data: data})
+		b.WriteString("\nendobj\n")
+	} else {
+		trailer["Size"] = pdfRaw(strconv.Itoa(u.next))
+
+		b.WriteString("xref\n")
+		for _, group := range xrefGroups(entries) {
+			fmt.Fprintf(&b, "%d %d\n", group[0], len(group))
+			for _, n := range group {
+				fmt.Fprintf(&b, "%010d %05d n\r\n", entries[n].offset, entries[n].gen)
+			}
+		}
+		b.WriteString("trailer\n")
+		writeObject(&b, trailer)
+		b.WriteByte('\n')
+	}
+
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", startxref)
+	return b.Bytes()
+}
+
+// xrefGroups splits the object numbers into runs of consecutive numbers.
+func xrefGroups(entries map[int]pdfEntry) [][]int {
+	nums := make([]int, 0, len(entries))
+	for n := range entries {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+
+	groups := [][]int{}
+	for i, n := range nums {
+		if i == 0 || n != nums[i-1]+1 {
+			groups = append(groups, []int{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], n)
+	}
+	return groups
+}
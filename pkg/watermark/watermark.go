@@ -0,0 +1,46 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported file type")
+	ErrEncrypted   = errors.New("encrypted pdf")
+	ErrInvalidPDF  = errors.New("invalid pdf")
+)
+
+// Mark identifies the buyer a copy of a file was made for.
+type Mark struct {
+	Email   string
+	OrderID string
+	Issued  time.Time
+}
+
+// String returns the line stamped into the file.
+func (m Mark) String() string {
+	return fmt.Sprintf("Licensed to %s, order %s", m.Email, m.OrderID)
+}
+
+// Supported reports whether files with the extension can be watermarked.
+func Supported(ext string) bool {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "pdf", "zip":
+		return true
+	}
+	return false
+}
+
+// Stamp returns a copy of the file with the mark, ext is the extension of its name.
+func Stamp(ext string, data []byte, mark Mark) ([]byte, error) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "pdf":
+		return PDF(data, mark)
+	case "zip":
+		return ZIP(data, mark)
+	}
+	return nil, ErrUnsupported
+}
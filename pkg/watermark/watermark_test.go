@@ -0,0 +1,189 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/stretchr/testify/assert"
+)
+
+var mark = Mark{Email: "user@example.com", OrderID: "iodz4ibf5h5zmov", Issued: time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)}
+
+func TestSupported(t *testing.T) {
+	assert.True(t, Supported("pdf"))
+	assert.True(t, Supported(".ZIP"))
+	assert.False(t, Supported("epub"))
+	assert.False(t, Supported(""))
+}
+
+func TestPDF(t *testing.T) {
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetFont("Helvetica", "", 12)
+	for i := 0; i < 3; i++ {
+		doc.AddPage()
+		doc.Cell(40, 10, fmt.Sprintf("Page %d", i+1))
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, doc.Output(&buf))
+
+	out, err := PDF(buf.Bytes(), mark)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out, buf.Bytes()))
+	assertStamped(t, out, 3)
+}
+
+func TestPDFXrefStream(t *testing.T) {
+	out, err := PDF(xrefStreamPDF(t), mark)
+	assert.NoError(t, err)
+	assertStamped(t, out, 1)
+
+	// a stamped file can be stamped again
+	out, err = PDF(out, Mark{Email: "other@example.com", OrderID: "x"})
+	assert.NoError(t, err)
+	assertStamped(t, out, 1)
+}
+
+func TestPDFInvalid(t *testing.T) {
+	_, err := PDF([]byte("not a pdf"), mark)
+	assert.Equal(t, ErrInvalidPDF, err)
+
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetProtection(fpdf.CnProtectPrint, "", "owner")
+	doc.AddPage()
+	var buf bytes.Buffer
+	assert.NoError(t, doc.Output(&buf))
+
+	_, err = PDF(buf.Bytes(), mark)
+	assert.Equal(t, ErrEncrypted, err)
+}
+
+func TestZIP(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"book.epub", LicenseName} {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(name))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.SetComment("v2"))
+	assert.NoError(t, w.Close())
+
+	out, err := ZIP(buf.Bytes(), mark)
+	assert.NoError(t, err)
+
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	assert.NoError(t, err)
+	assert.Equal(t, "v2\n"+mark.String(), r.Comment)
+	assert.Len(t, r.File, 3)
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	assert.Equal(t, "book.epub", files["book.epub"])
+	assert.Equal(t, LicenseName, files[LicenseName])
+	assert.Contains(t, files["licence-"+mark.OrderID+".txt"], mark.Email)
+}
+
+func TestStamp(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	assert.NoError(t, w.Close())
+
+	out, err := Stamp(".ZIP", buf.Bytes(), mark)
+	assert.NoError(t, err)
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	assert.NoError(t, err)
+	assert.Equal(t, mark.String(), r.Comment)
+
+	_, err = Stamp("txt", []byte("text"), mark)
+	assert.Equal(t, ErrUnsupported, err)
+}
+
+// assertStamped checks that every page of the document ends with the footer.
+func assertStamped(t *testing.T, data []byte, count int) {
+	t.Helper()
+
+	doc, err := openPDF(data)
+	assert.NoError(t, err)
+	catalog, _ := doc.resolve(doc.trailer["Root"]).(pdfDict)
+	pages := []pdfPage{}
+	assert.NoError(t, doc.pages(catalog["Pages"], map[pdfName]any{}, map[int]bool{}, &pages))
+	assert.Len(t, pages, count)
+
+	for _, page := range pages {
+		contents, _ := page.dict["Contents"].(pdfArray)
+		if !assert.NotEmpty(t, contents) {
+			continue
+		}
+		footer, _ := doc.resolve(contents[len(contents)-1]).(*pdfStream)
+		if !assert.NotNil(t, footer) {
+			continue
+		}
+		assert.Contains(t, string(footer.data), "/LCWatermark")
+
+		resources, _ := doc.resolve(page.dict["Resources"]).(pdfDict)
+		fonts, _ := doc.resolve(resources["Font"]).(pdfDict)
+		assert.Contains(t, fonts, pdfName("LCWatermark"))
+	}
+}
+
+// xrefStreamPDF builds a document that keeps its catalog and page tree in an
+// object stream and its cross-reference table in a predicted xref stream.
+func xrefStreamPDF(t *testing.T) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := map[int]int{}
+	object := func(num int, body string) {
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+
+	content := "BT /F1 12 Tf 72 720 Td (Hello) Tj ET"
+	object(3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>")
+	object(4, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+
+	objects := "<< /Type /Catalog /Pages 2 0 R >> << /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >> >> >> >>"
+	catalog := strings.Index(objects, "<<")
+	pages := strings.Index(objects, ">> <<") + 3
+	header := fmt.Sprintf("1 %d 2 %d ", catalog, pages)
+	object(5, fmt.Sprintf("<< /Type /ObjStm /N 2 /First %d /Length %d >>\nstream\n%s%s\nendstream", len(header), len(header)+len(objects), header, objects))
+
+	// type, offset or object stream, generation or index
+	rows := [][]int{{0, 0, 255}, {2, 5, 0}, {2, 5, 1}, {1, offsets[3], 0}, {1, offsets[4], 0}, {1, offsets[5], 0}, {1, b.Len(), 0}}
+	raw, prev := []byte{}, make([]byte, 4)
+	for _, row := range rows {
+		line := []byte{byte(row[0]), byte(row[1] >> 8), byte(row[1]), byte(row[2])}
+		raw = append(raw, 2)
+		for i := range line {
+			raw = append(raw, line[i]-prev[i])
+		}
+		prev = line
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	_, err := zw.Write(raw)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	startxref := b.Len()
+	object(6, fmt.Sprintf("<< /Type /XRef /Size 7 /W [1 2 1] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Columns 4 /Predictor 12 >> /Length %d >>\nstream\n%s\nendstream", z.Len(), z.String()))
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", startxref)
+
+	return b.Bytes()
+}
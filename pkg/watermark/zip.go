@@ -0,0 +1,63 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+)
+
+// LicenseName is the name of the file added to watermarked archives.
+const LicenseName = "licence.txt"
+
+// ZIP returns a copy of the archive with a licence file and an archive comment
+// that name the buyer. The original entries are copied without recompression.
+func ZIP(data []byte, mark Mark) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	name := LicenseName
+	for _, f := range r.File {
+		if f.Name == name {
+			name = fmt.Sprintf("licence-%s.txt", mark.OrderID)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range r.File {
+		if err := w.Copy(f); err != nil {
+			return nil, err
+		}
+	}
+
+	fw, err := w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: mark.Issued,
+	})
+	if err != nil {
+		return nil, err
+	}
+	license := fmt.Sprintf("This copy is licensed to %s.\r\nOrder: %s\r\nIssued: %s\r\n\r\nIt is personal and must not be shared or redistributed.\r\n",
+		mark.Email, mark.OrderID, mark.Issued.UTC().Format("2006-01-02"))
+	if _, err := fw.Write([]byte(license)); err != nil {
+		return nil, err
+	}
+
+	comment := mark.String()
+	if r.Comment != "" {
+		comment = r.Comment + "\n" + comment
+	}
+	if err := w.SetComment(comment); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
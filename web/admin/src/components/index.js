@@ -6,6 +6,10 @@ export { default as FormTextarea } from "./form/Textarea.vue";
 export { default as FormToggle } from "./form/Toggle.vue";
 export { default as FormUpload } from "./form/Upload.vue";
 
+// category section
+export { default as CategoryAdd } from "./category/Add.vue";
+export { default as CategoryUpdate } from "./category/Update.vue";
+
 // page section
 export { default as PageAdd } from "./page/Add.vue";
 export { default as PageSeo } from "./page/Seo.vue";
@@ -16,6 +20,7 @@ export { default as ProductAdd } from "./product/Add.vue";
 export { default as ProductDigital } from "./product/Digital.vue";
 export { default as ProductSeo } from "./product/Seo.vue";
 export { default as ProductUpdate } from "./product/Update.vue";
+export { default as ProductVariants } from "./product/Variants.vue";
 export { default as ProductView } from "./product/View.vue";
 
 // product section
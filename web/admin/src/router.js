@@ -23,12 +23,30 @@ const router = createRouter({
       meta: { layout: "Main", ico: "cube" },
       component: () => import("@/pages/Products.vue"),
     },
+    {
+      path: "/categories",
+      name: "categories",
+      meta: { layout: "Main", ico: "folder" },
+      component: () => import("@/pages/Categories.vue"),
+    },
     {
       path: "/carts",
       name: "carts",
       meta: { layout: "Main", ico: "cart" },
       component: () => import("@/pages/Carts.vue"),
     },
+    {
+      path: "/customers",
+      name: "customers",
+      meta: { layout: "Main", ico: "user" },
+      component: () => import("@/pages/Customers.vue"),
+    },
+    {
+      path: "/reviews",
+      name: "reviews",
+      meta: { layout: "Main", ico: "star" },
+      component: () => import("@/pages/Reviews.vue"),
+    },
     {
       path: "/pages",
       name: "pages",
@@ -86,6 +104,18 @@ const router = createRouter({
           meta: { ico: "at-symbol", title: "Mail setting" },
           component: () => import('@/pages/settings/mail.vue')
         },
+        {
+          path: 'invoice',
+          name: 'settingsInvoice',
+          meta: { ico: "docs", title: "Invoice" },
+          component: () => import('@/pages/settings/invoice.vue')
+        },
+        {
+          path: 'storage',
+          name: 'settingsStorage',
+          meta: { ico: "server", title: "Storage" },
+          component: () => import('@/pages/settings/storage.vue')
+        },
       ],
     },
 
@@ -97,6 +97,17 @@ export function formatDate(timestamp) {
   } ${date.getFullYear()}, ${date.toLocaleTimeString()}`;
 }
 
+// categoryTree orders the categories so that every category follows its
+// parent, depth is the nesting level used to indent the name.
+export function categoryTree(categories, parentID = "", depth = 0) {
+  let tree = [];
+  for (const item of categories.filter((e) => (e.parent_id || "") === parentID)) {
+    tree.push({ ...item, depth: depth });
+    tree = tree.concat(categoryTree(categories, item.id, depth + 1));
+  }
+  return tree;
+}
+
 function deepEqual(object1, object2) {
   if (object1 == object2) {
     return true;
@@ -26,14 +26,36 @@ const App = {
       cart: JSON.parse(localStorage.getItem('cart')) || ref([]),
       email: localStorage.getItem('email') || ref(''),
       provider: localStorage.getItem('provider') || ref(''),
+      needInvoice: false,
+      billing: JSON.parse(localStorage.getItem('billing')) || { name: '', company: '', address: '', vat_id: '' },
 
       // products
       load: false,
       products: ref([]),
+      total: 0,
+      page: 1,
+      search: new URLSearchParams(window.location.search).get('q') || '',
+      sort: new URLSearchParams(window.location.search).get('sort') || '',
+      variantID: '',
+
+      // reviews
+      reviews: ref([]),
+      reviewsTotal: 0,
+      reviewsPage: 1,
+      reviewProducts: ref([]),
+
+      // categories
+      category: ref(null),
+      subcategories: ref([]),
 
       // pages
       content: ref([]),
 
+      // customer
+      customer: ref(null),
+      purchases: ref([]),
+      signInSent: false,
+
       socialUrl: {
         facebook: 'https://facebook.com/',
         instagram: 'https://instagram.com/',
@@ -72,15 +94,32 @@ const App = {
           this.cart = ref([])
           break
         }
+        const recover = new URLSearchParams(window.location.search).get('recover')
+        if (recover) {
+          this.recoverCart(recover)
+        }
         this.listPayments()
         break
+      case currentPathname.startsWith('/unsubscribe'):
+      case currentPathname.startsWith('/download'):
+        break
+      case currentPathname.startsWith('/review'):
+        this.listReviewProducts(currentPathname.replace('/review/', ''))
+        break
+      case currentPathname.startsWith('/account'):
+        this.getCustomer()
+        break
       case currentPathname.startsWith('/products'):
         this.getProduct(currentPathname.replace('/products/', ''))
         break
+      case currentPathname.startsWith('/categories'):
+        this.getCategory(currentPathname.replace('/categories/', ''))
+        break
       case currentPathname.length > 1:
         this.getPage(currentPathname.substring(1))
         break
       default:
+        this.listCategories('')
         this.listProducts()
         break
     }
@@ -152,8 +191,15 @@ const App = {
         product = this.product
       }
 
+      // editions are chosen on the product page
+      if (product.variants && product !== this.product) {
+        window.location.href = `/products/${product.slug}`
+        return
+      }
+
       if (!this.inCart(product.id)) {
         product.inCart = true
+        const variant = this.selectedVariant()
         const image = product.images
           ? {
             name: product.images[0].name,
@@ -167,7 +213,9 @@ const App = {
               id: product.id,
               name: product.name,
               slug: product.slug,
-              amount: product.amount,
+              amount: variant ? variant.amount : product.price,
+              variant_id: variant ? variant.id : undefined,
+              variant_name: variant ? variant.name : undefined,
               image: image
             }
           })
@@ -175,6 +223,27 @@ const App = {
       }
     },
 
+    async recoverCart(token) {
+      const response = await fetch(`/api/cart/recover/${token}`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.cart = resp.result.items
+        localStorage.setItem('cart', JSON.stringify(this.cart))
+        if (resp.result.email) {
+          this.email = resp.result.email
+          localStorage.setItem('email', this.email)
+        }
+        if (resp.result.provider) {
+          this.provider = resp.result.provider
+          localStorage.setItem('provider', this.provider)
+        }
+      }
+      window.history.replaceState(null, '', '/cart')
+    },
+
     removeCart(id) {
       const index = this.cart.findIndex((item) => item.id === id)
       if (index !== -1) {
@@ -204,6 +273,24 @@ const App = {
       }
     },
 
+    selectedVariant() {
+      if (!this.product || !this.product.variants) {
+        return null
+      }
+      return this.product.variants.find((item) => item.id === this.variantID) || null
+    },
+
+    fromAmount(product) {
+      if (!product.variants) {
+        return product.price
+      }
+      return Math.min(...product.variants.map((item) => item.amount))
+    },
+
+    onSale(product) {
+      return !product.variants && product.price < product.amount
+    },
+
     totalCartAmount() {
       let total = 0
       for (const item of this.cart) {
@@ -221,7 +308,11 @@ const App = {
       var cart = {
         email: this.email,
         provider: this.provider,
-        products: this.cart.map((item) => ({ id: item.id, quantity: 1 }))
+        products: this.cart.map((item) => ({ id: item.id, variant_id: item.variant_id, quantity: 1 }))
+      }
+      if (this.needInvoice) {
+        localStorage.setItem('billing', JSON.stringify(this.billing))
+        cart.billing = this.billing
       }
 
       const response = await fetch(`/cart/payment`, {
@@ -266,19 +357,58 @@ const App = {
     },
 
     // product functions
-    async listProducts() {
-      const response = await fetch(`/api/products`, {
+    async listProducts(category, page = 1) {
+      const params = new URLSearchParams()
+      if (category) {
+        params.set('category', category)
+      }
+      if (this.search) {
+        params.set('q', this.search)
+      }
+      if (this.sort) {
+        const [sort, order] = this.sort.split(':')
+        params.set('sort', sort)
+        params.set('order', order)
+      }
+      if (page > 1) {
+        params.set('page', page)
+      }
+      const query = params.toString() ? `?${params.toString()}` : ''
+      const response = await fetch(`/api/products${query}`, {
         credentials: 'include',
         method: 'GET'
       })
       const resp = await response.json()
       if (resp.success) {
         this.currency = sessionStorage.getItem('currency')
-        this.products = resp.result.products
+        const products = resp.result.products || []
+        this.products = page > 1 ? this.products.concat(products) : products
+        this.total = resp.result.total
+        this.page = page
         this.load = true
       }
     },
 
+    moreProducts() {
+      this.listProducts(this.category ? this.category.slug : '', this.page + 1)
+    },
+
+    searchProducts() {
+      const url = new URL(window.location)
+      if (this.search) {
+        url.searchParams.set('q', this.search)
+      } else {
+        url.searchParams.delete('q')
+      }
+      if (this.sort) {
+        url.searchParams.set('sort', this.sort)
+      } else {
+        url.searchParams.delete('sort')
+      }
+      window.history.replaceState(null, '', url)
+      this.listProducts(this.category ? this.category.slug : '')
+    },
+
     async getProduct(slug) {
       const response = await fetch(`/api/products/${slug}`, {
         credentials: 'include',
@@ -289,7 +419,12 @@ const App = {
         this.currency = sessionStorage.getItem('currency')
         this.product = this.resp.result
         this.product.inCart = this.inCart(this.product.id)
+        if (this.product.variants) {
+          const item = this.cart.find((o) => o.id === this.product.id)
+          this.variantID = item && item.variant_id ? item.variant_id : this.product.variants[0].id
+        }
         this.load = true
+        this.listReviews(this.product.id, 1)
 
         if (this.product.seo.title) {
           document.title = this.product.seo.title
@@ -307,6 +442,103 @@ const App = {
       }
     },
 
+    // review functions
+    async listReviews(productID, page) {
+      const response = await fetch(`/api/products/${productID}/reviews?page=${page}`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.reviews = page > 1 ? this.reviews.concat(resp.result.reviews) : resp.result.reviews
+        this.reviewsTotal = resp.result.total
+        this.reviewsPage = page
+      }
+    },
+
+    async listReviewProducts(token) {
+      const response = await fetch(`/api/reviews/${token}`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.reviewProducts = resp.result.map((item) => ({
+          ...item,
+          error: '',
+          form: {
+            name: item.review ? item.review.name : '',
+            rating: item.review ? item.review.rating : 5,
+            text: item.review ? item.review.text : ''
+          }
+        }))
+      }
+    },
+
+    async addReview(item) {
+      const token = window.location.pathname.replace('/review/', '')
+      const response = await fetch(`/api/reviews/${token}`, {
+        credentials: 'include',
+        method: 'POST',
+        body: JSON.stringify({ product_id: item.product_id, ...item.form }),
+        headers: {
+          'Content-Type': 'application/json'
+        }
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        item.review = resp.result
+        item.error = ''
+      } else {
+        item.error = typeof resp.result === 'string' ? resp.result : resp.message
+      }
+    },
+
+    stars(rating) {
+      const full = Math.round(rating)
+      return '\u2605'.repeat(full) + '\u2606'.repeat(5 - full)
+    },
+
+    // category functions
+    async listCategories(parentID) {
+      const response = await fetch(`/api/categories`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.subcategories = resp.result.filter((item) => item.parent_id === parentID)
+      }
+    },
+
+    async getCategory(slug) {
+      const response = await fetch(`/api/categories/${slug}`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.category = resp.result
+        this.listCategories(this.category.id)
+        this.listProducts(slug)
+
+        if (this.category.seo && this.category.seo.title) {
+          document.title = this.category.seo.title
+          document.querySelector('meta[name="title"]').setAttribute('content', this.category.seo.title)
+          document.querySelector('meta[property="og:title"]').setAttribute('content', this.category.seo.title)
+        }
+        if (this.category.seo && this.category.seo.keywords) {
+          document.querySelector('meta[name="keywords"]').setAttribute('content', this.category.seo.keywords)
+        }
+        if (this.category.seo && this.category.seo.description) {
+          document.querySelector('meta[name="description"]').setAttribute('content', this.category.seo.description)
+          document
+            .querySelector('meta[property="og:description"]')
+            .setAttribute('content', this.category.seo.description)
+        }
+      }
+    },
+
     // page function
     async getPage(slug) {
       const response = await fetch(`/api/pages/${slug}`, {
@@ -334,6 +566,55 @@ const App = {
       }
     },
 
+    // customer functions
+    async getCustomer() {
+      const response = await fetch(`/api/customer`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.customer = resp.result
+        this.getPurchases()
+      }
+    },
+
+    async getPurchases() {
+      const response = await fetch(`/api/customer/purchases`, {
+        credentials: 'include',
+        method: 'GET'
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.purchases = resp.result
+      }
+    },
+
+    async customerSignIn() {
+      localStorage.setItem('email', this.email)
+      const response = await fetch(`/api/customer/sign/in`, {
+        credentials: 'include',
+        method: 'POST',
+        body: JSON.stringify({ email: this.email }),
+        headers: {
+          'Content-Type': 'application/json'
+        }
+      })
+      const resp = await response.json()
+      if (resp.success) {
+        this.signInSent = true
+      }
+    },
+
+    async customerSignOut() {
+      await fetch(`/api/customer/sign/out`, {
+        credentials: 'include',
+        method: 'POST'
+      })
+      this.customer = null
+      this.purchases = []
+    },
+
     showOverlay() {
       this.error = ""
       document.getElementById('overlay').classList.remove('hidden')